@@ -16,10 +16,10 @@ func Initialize(cfg *config.AppConfig) error {
 	e := echo.New()
 	e.Use(echoMiddleware.Recover())
 	e.Use(echoMiddleware.RequestID())
-	e.Use(echoMiddleware.Logger())
+	e.Use(middleware.RequestLogger())
 
 	e.Use(echoMiddleware.CORSWithConfig(echoMiddleware.CORSConfig{
-		AllowMethods: []string{"GET", "POST"},
+		AllowMethods: []string{"GET", "POST", "PUT", "DELETE"},
 		AllowHeaders: []string{"Content-Type", "Authorization", "X-Requested-With"},
 	}))
 
@@ -35,8 +35,10 @@ func Initialize(cfg *config.AppConfig) error {
 
 	apiGroup := e.Group(cfg.Server.RootPath)
 	v1Group := apiGroup.Group("/v1")
-	v1Group.Use(middleware.APIKeyAuth(cfg))
+	// Feed readers subscribe to rendered feeds by URL, with the key in the query
+	v1Group.Use(middleware.APIKeyAuth(cfg, cfg.Server.RootPath+"/v1/render"))
 	v1.SetupRoutes(v1Group, cfg)
+	v1.SetupPublicRoutes(apiGroup.Group("/v1"), cfg)
 
 	logger.GetSugaredLogger().Infof("Starting server on address %s...", cfg.Server.Host)
 	e.Logger.Fatal(e.Start(fmt.Sprintf("%s:%s", cfg.Server.Host, cfg.Server.Port)))
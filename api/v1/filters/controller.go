@@ -0,0 +1,126 @@
+package filters
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/lufeed/feed-parser-api/internal/types"
+)
+
+type controllerImpl struct {
+	service service
+}
+
+func newController(service service) types.Registerer {
+	return controllerImpl{
+		service: service,
+	}
+}
+
+func (c controllerImpl) Register(group *echo.Group) {
+
+	group.GET("", c.listFilters)
+	group.POST("", c.createFilter)
+	group.PUT("/subscriptions", c.attachFilter)
+	group.DELETE("/subscriptions", c.detachFilter)
+	group.GET("/:id", c.getFilter)
+	group.PUT("/:id", c.updateFilter)
+	group.DELETE("/:id", c.deleteFilter)
+}
+
+func (c controllerImpl) listFilters(ctx echo.Context) error {
+	data, err := c.service.listFilters(ctx.Request().Context())
+	if err != nil {
+		return echo.NewHTTPError(data.StatusCode(), err.Error())
+	}
+
+	return ctx.JSON(data.StatusCode(), data)
+}
+
+func (c controllerImpl) createFilter(ctx echo.Context) error {
+	var body filterBody
+	err := ctx.Bind(&body)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	data, err := c.service.createFilter(ctx.Request().Context(), body)
+	if err != nil {
+		return echo.NewHTTPError(data.StatusCode(), err.Error())
+	}
+
+	return ctx.JSON(data.StatusCode(), data)
+}
+
+func (c controllerImpl) getFilter(ctx echo.Context) error {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid filter id")
+	}
+
+	data, err := c.service.getFilter(ctx.Request().Context(), id)
+	if err != nil {
+		return echo.NewHTTPError(data.StatusCode(), err.Error())
+	}
+
+	return ctx.JSON(data.StatusCode(), data)
+}
+
+func (c controllerImpl) updateFilter(ctx echo.Context) error {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid filter id")
+	}
+
+	var body filterBody
+	err = ctx.Bind(&body)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	data, err := c.service.updateFilter(ctx.Request().Context(), id, body)
+	if err != nil {
+		return echo.NewHTTPError(data.StatusCode(), err.Error())
+	}
+
+	return ctx.JSON(data.StatusCode(), data)
+}
+
+func (c controllerImpl) deleteFilter(ctx echo.Context) error {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid filter id")
+	}
+
+	data, err := c.service.deleteFilter(ctx.Request().Context(), id)
+	if err != nil {
+		return echo.NewHTTPError(data.StatusCode(), err.Error())
+	}
+
+	return ctx.JSON(data.StatusCode(), data)
+}
+
+func (c controllerImpl) attachFilter(ctx echo.Context) error {
+	var body subscriptionBody
+	err := ctx.Bind(&body)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	data, err := c.service.attachFilter(ctx.Request().Context(), body)
+	if err != nil {
+		return echo.NewHTTPError(data.StatusCode(), err.Error())
+	}
+
+	return ctx.JSON(data.StatusCode(), data)
+}
+
+func (c controllerImpl) detachFilter(ctx echo.Context) error {
+	data, err := c.service.detachFilter(ctx.Request().Context(), ctx.QueryParam("user_id"), ctx.QueryParam("feed_id"))
+	if err != nil {
+		return echo.NewHTTPError(data.StatusCode(), err.Error())
+	}
+
+	return ctx.JSON(data.StatusCode(), data)
+}
@@ -0,0 +1,187 @@
+package filters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lufeed/feed-parser-api/internal/filter"
+	"github.com/lufeed/feed-parser-api/internal/models"
+	"github.com/lufeed/feed-parser-api/internal/types"
+)
+
+type service interface {
+	listFilters(ctx context.Context) (types.APIResponse, error)
+	createFilter(ctx context.Context, body filterBody) (types.APIResponse, error)
+	getFilter(ctx context.Context, id uuid.UUID) (types.APIResponse, error)
+	updateFilter(ctx context.Context, id uuid.UUID, body filterBody) (types.APIResponse, error)
+	deleteFilter(ctx context.Context, id uuid.UUID) (types.APIResponse, error)
+	attachFilter(ctx context.Context, body subscriptionBody) (types.APIResponse, error)
+	detachFilter(ctx context.Context, userID, feedID string) (types.APIResponse, error)
+}
+
+type serviceImpl struct{}
+
+func newService() service {
+	return serviceImpl{}
+}
+
+func (s serviceImpl) listFilters(ctx context.Context) (types.APIResponse, error) {
+	filters, err := filter.ListFilters()
+	if err != nil {
+		return types.APIResponse{
+			Code: http.StatusInternalServerError,
+		}, err
+	}
+
+	return types.APIResponse{
+		Code:    http.StatusOK,
+		Message: "success",
+		Data:    filters,
+	}, nil
+}
+
+func (s serviceImpl) createFilter(ctx context.Context, body filterBody) (types.APIResponse, error) {
+	id, err := uuid.NewUUID()
+	if err != nil {
+		return types.APIResponse{
+			Code: http.StatusInternalServerError,
+		}, err
+	}
+
+	now := time.Now().UTC()
+	saved := models.SavedFilter{
+		ID:        id,
+		Name:      body.Name,
+		Filter:    body.Filter,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	return s.saveFilter(saved, http.StatusCreated)
+}
+
+func (s serviceImpl) getFilter(ctx context.Context, id uuid.UUID) (types.APIResponse, error) {
+	saved, err := filter.GetFilter(id)
+	if err != nil {
+		return types.APIResponse{
+			Code: filterErrorCode(err),
+		}, err
+	}
+
+	return types.APIResponse{
+		Code:    http.StatusOK,
+		Message: "success",
+		Data:    saved,
+	}, nil
+}
+
+func (s serviceImpl) updateFilter(ctx context.Context, id uuid.UUID, body filterBody) (types.APIResponse, error) {
+	existing, err := filter.GetFilter(id)
+	if err != nil {
+		return types.APIResponse{
+			Code: filterErrorCode(err),
+		}, err
+	}
+
+	existing.Name = body.Name
+	existing.Filter = body.Filter
+	existing.UpdatedAt = time.Now().UTC()
+
+	return s.saveFilter(existing, http.StatusOK)
+}
+
+func (s serviceImpl) deleteFilter(ctx context.Context, id uuid.UUID) (types.APIResponse, error) {
+	err := filter.DeleteFilter(id)
+	if err != nil {
+		return types.APIResponse{
+			Code: filterErrorCode(err),
+		}, err
+	}
+
+	return types.APIResponse{
+		Code:    http.StatusOK,
+		Message: "success",
+	}, nil
+}
+
+func (s serviceImpl) attachFilter(ctx context.Context, body subscriptionBody) (types.APIResponse, error) {
+	if body.UserID == "" || body.FeedID == "" {
+		return types.APIResponse{
+			Code: http.StatusBadRequest,
+		}, fmt.Errorf("user_id and feed_id are required")
+	}
+	filterID, err := uuid.Parse(body.FilterID)
+	if err != nil {
+		return types.APIResponse{
+			Code: http.StatusBadRequest,
+		}, fmt.Errorf("invalid filter_id: %s", body.FilterID)
+	}
+
+	if err := filter.AttachFilter(body.UserID, body.FeedID, filterID); err != nil {
+		return types.APIResponse{
+			Code: filterErrorCode(err),
+		}, err
+	}
+
+	return types.APIResponse{
+		Code:    http.StatusOK,
+		Message: "success",
+		Data:    body,
+	}, nil
+}
+
+func (s serviceImpl) detachFilter(ctx context.Context, userID, feedID string) (types.APIResponse, error) {
+	if userID == "" || feedID == "" {
+		return types.APIResponse{
+			Code: http.StatusBadRequest,
+		}, fmt.Errorf("user_id and feed_id are required")
+	}
+
+	if err := filter.DetachFilter(userID, feedID); err != nil {
+		return types.APIResponse{
+			Code: http.StatusInternalServerError,
+		}, err
+	}
+
+	return types.APIResponse{
+		Code:    http.StatusOK,
+		Message: "success",
+	}, nil
+}
+
+func (s serviceImpl) saveFilter(saved models.SavedFilter, code int) (types.APIResponse, error) {
+	compiled, err := filter.Compile(&saved.Filter)
+	if err != nil {
+		return types.APIResponse{
+			Code: http.StatusBadRequest,
+		}, err
+	}
+	if compiled == nil {
+		return types.APIResponse{
+			Code: http.StatusBadRequest,
+		}, fmt.Errorf("filter has no conditions")
+	}
+
+	if err := filter.SaveFilter(saved); err != nil {
+		return types.APIResponse{
+			Code: http.StatusInternalServerError,
+		}, err
+	}
+
+	return types.APIResponse{
+		Code:    code,
+		Message: "success",
+		Data:    saved,
+	}, nil
+}
+
+func filterErrorCode(err error) int {
+	if errors.Is(err, filter.ErrFilterNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
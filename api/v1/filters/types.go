@@ -0,0 +1,14 @@
+package filters
+
+import "github.com/lufeed/feed-parser-api/internal/models"
+
+type filterBody struct {
+	Name   string            `json:"name"`
+	Filter models.ItemFilter `json:"filter"`
+}
+
+type subscriptionBody struct {
+	UserID   string `json:"user_id"`
+	FeedID   string `json:"feed_id"`
+	FilterID string `json:"filter_id"`
+}
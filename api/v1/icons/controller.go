@@ -0,0 +1,47 @@
+package icons
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lufeed/feed-parser-api/internal/types"
+)
+
+type controllerImpl struct {
+	service service
+}
+
+func newController(service service) types.Registerer {
+	return controllerImpl{
+		service: service,
+	}
+}
+
+func (c controllerImpl) Register(group *echo.Group) {
+
+	group.GET("", c.getIcon)
+}
+
+func (c controllerImpl) getIcon(ctx echo.Context) error {
+	var query iconQuery
+	err := ctx.Bind(&query)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	data, err := c.service.getIcon(ctx.Request().Context(), query.Domain, query.Size)
+	if err != nil {
+		return echo.NewHTTPError(data.StatusCode(), err.Error())
+	}
+
+	icon := data.Data.(renderedIcon)
+	// Letter avatars are replaced once the site gets an icon
+	maxAge := 604800
+	if icon.Generated {
+		maxAge = 86400
+	}
+	ctx.Response().Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(maxAge))
+	ctx.Response().Header().Set("X-Icon-Generated", strconv.FormatBool(icon.Generated))
+	return ctx.Blob(data.StatusCode(), "image/png", icon.Body)
+}
@@ -0,0 +1,66 @@
+package icons
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/lufeed/feed-parser-api/api/v1/status"
+	"github.com/lufeed/feed-parser-api/internal/icons"
+	"github.com/lufeed/feed-parser-api/internal/proxy"
+	"github.com/lufeed/feed-parser-api/internal/types"
+)
+
+type service interface {
+	getIcon(ctx context.Context, domain string, size int) (types.APIResponse, error)
+}
+
+type serviceImpl struct {
+	proxyManager *proxy.Manager
+}
+
+func newService(proxyManager *proxy.Manager) service {
+	return serviceImpl{
+		proxyManager: proxyManager,
+	}
+}
+
+func (s serviceImpl) getIcon(ctx context.Context, domain string, size int) (types.APIResponse, error) {
+	domain, err := icons.NormalizeDomain(domain)
+	if err != nil {
+		return types.APIResponse{
+			Code: http.StatusBadRequest,
+		}, err
+	}
+	if size == 0 {
+		size = icons.DefaultSize
+	}
+	if size < icons.MinSize || size > icons.MaxSize {
+		return types.APIResponse{
+			Code: http.StatusBadRequest,
+		}, fmt.Errorf("size must be between %d and %d", icons.MinSize, icons.MaxSize)
+	}
+
+	cl, proxyID, err := s.proxyManager.Acquire(ctx, "https://"+domain)
+	if err != nil {
+		return types.APIResponse{
+			Code: http.StatusServiceUnavailable,
+		}, err
+	}
+	icon, err := icons.Get(ctx, cl, domain, size)
+	s.proxyManager.ReleaseProxy(proxyID)
+	if err != nil {
+		return types.APIResponse{
+			Code: status.ForError(err, http.StatusInternalServerError),
+		}, err
+	}
+
+	return types.APIResponse{
+		Code:    http.StatusOK,
+		Message: "success",
+		Data: renderedIcon{
+			Body:      icon.PNG,
+			Generated: icon.Generated,
+		},
+	}, nil
+}
@@ -0,0 +1,11 @@
+package icons
+
+type iconQuery struct {
+	Domain string `query:"domain"`
+	Size   int    `query:"size"`
+}
+
+type renderedIcon struct {
+	Body      []byte
+	Generated bool
+}
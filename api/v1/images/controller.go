@@ -0,0 +1,77 @@
+package images
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lufeed/feed-parser-api/internal/images"
+	"github.com/lufeed/feed-parser-api/internal/types"
+)
+
+type controllerImpl struct {
+	service service
+}
+
+func newController(service service) types.Registerer {
+	return controllerImpl{
+		service: service,
+	}
+}
+
+func (c controllerImpl) Register(group *echo.Group) {
+
+	group.POST("/sign", c.signImage)
+}
+
+func (c controllerImpl) signImage(ctx echo.Context) error {
+	var body signBody
+	err := ctx.Bind(&body)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	params := images.Params{URL: body.URL, Width: body.Width, Height: body.Height, Format: body.Format}
+	endpointURL := ctx.Scheme() + "://" + ctx.Request().Host + strings.TrimSuffix(ctx.Request().URL.Path, "/sign")
+
+	data, err := c.service.signImage(ctx.Request().Context(), params, endpointURL)
+	if err != nil {
+		return echo.NewHTTPError(data.StatusCode(), err.Error())
+	}
+
+	return ctx.JSON(data.StatusCode(), data)
+}
+
+type publicControllerImpl struct {
+	service service
+}
+
+func newPublicController(service service) types.Registerer {
+	return publicControllerImpl{
+		service: service,
+	}
+}
+
+func (c publicControllerImpl) Register(group *echo.Group) {
+
+	group.GET("", c.getImage)
+}
+
+func (c publicControllerImpl) getImage(ctx echo.Context) error {
+	var query imageQuery
+	err := ctx.Bind(&query)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	params := images.Params{URL: query.URL, Width: query.Width, Height: query.Height, Format: query.Format}
+	data, err := c.service.getImage(ctx.Request().Context(), params, query.Signature)
+	if err != nil {
+		return echo.NewHTTPError(data.StatusCode(), err.Error())
+	}
+
+	img := data.Data.(renderedImage)
+	// Derivatives never change for a signed URL
+	ctx.Response().Header().Set("Cache-Control", "public, max-age=604800, immutable")
+	return ctx.Blob(data.StatusCode(), img.ContentType, img.Body)
+}
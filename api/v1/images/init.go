@@ -0,0 +1,26 @@
+package images
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/lufeed/feed-parser-api/internal/config"
+	"github.com/lufeed/feed-parser-api/internal/proxy"
+)
+
+// Initialize registers the signing endpoint, which requires an API key.
+func Initialize(group *echo.Group) {
+	pm := proxy.NewManager(config.GetConfig())
+	s := newService(pm, config.GetConfig().Images)
+	c := newController(s)
+
+	c.Register(group)
+}
+
+// InitializePublic registers the image endpoint. Its URLs are signed and end up in
+// pages that cannot send the API key, so it is mounted outside the key middleware.
+func InitializePublic(group *echo.Group) {
+	pm := proxy.NewManager(config.GetConfig())
+	s := newService(pm, config.GetConfig().Images)
+	c := newPublicController(s)
+
+	c.Register(group)
+}
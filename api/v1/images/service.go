@@ -0,0 +1,151 @@
+package images
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/lufeed/feed-parser-api/api/v1/status"
+	"github.com/lufeed/feed-parser-api/internal/cache"
+	"github.com/lufeed/feed-parser-api/internal/config"
+	"github.com/lufeed/feed-parser-api/internal/images"
+	"github.com/lufeed/feed-parser-api/internal/proxy"
+	"github.com/lufeed/feed-parser-api/internal/types"
+)
+
+const (
+	defaultMaxDimension = 2048
+	derivativeCacheTTL  = 7 * 24 * time.Hour
+	// Larger derivatives are served but not cached
+	maxCachedBytes = 5 * 1024 * 1024
+)
+
+type service interface {
+	getImage(ctx context.Context, params images.Params, signature string) (types.APIResponse, error)
+	signImage(ctx context.Context, params images.Params, endpointURL string) (types.APIResponse, error)
+}
+
+type serviceImpl struct {
+	proxyManager *proxy.Manager
+	signingKey   string
+	maxDimension int
+}
+
+func newService(proxyManager *proxy.Manager, cfg config.ImagesConfig) service {
+	maxDimension := cfg.MaxDimension
+	if maxDimension <= 0 {
+		maxDimension = defaultMaxDimension
+	}
+	return serviceImpl{
+		proxyManager: proxyManager,
+		signingKey:   cfg.SigningKey,
+		maxDimension: maxDimension,
+	}
+}
+
+func (s serviceImpl) getImage(ctx context.Context, params images.Params, signature string) (types.APIResponse, error) {
+	if s.signingKey == "" {
+		return types.APIResponse{
+			Code: http.StatusServiceUnavailable,
+		}, fmt.Errorf("image proxy is not configured")
+	}
+	if !images.Verify(s.signingKey, params, signature) {
+		return types.APIResponse{
+			Code: http.StatusForbidden,
+		}, fmt.Errorf("invalid signature")
+	}
+	if err := s.validate(params); err != nil {
+		return types.APIResponse{
+			Code: http.StatusBadRequest,
+		}, err
+	}
+
+	if cached, err := cache.GetCache(params.CacheKey()); err == nil && cached != "" {
+		if contentType, body, ok := strings.Cut(cached, "\n"); ok {
+			return imageResponse(contentType, []byte(body)), nil
+		}
+	}
+
+	cl, proxyID, err := s.proxyManager.Acquire(ctx, params.URL)
+	if err != nil {
+		return types.APIResponse{
+			Code: http.StatusServiceUnavailable,
+		}, err
+	}
+	img, err := images.Download(ctx, cl, params.URL)
+	s.proxyManager.ReleaseProxy(proxyID)
+	if err != nil {
+		return types.APIResponse{
+			Code: status.ForError(err, http.StatusBadGateway),
+		}, err
+	}
+
+	width, height := params.Width, params.Height
+	if width == 0 {
+		width = s.maxDimension
+	}
+	if height == 0 {
+		height = s.maxDimension
+	}
+	body, contentType, err := images.Encode(images.Resize(img, width, height), params.Format)
+	if err != nil {
+		return types.APIResponse{
+			Code: http.StatusInternalServerError,
+		}, err
+	}
+
+	if len(body) <= maxCachedBytes {
+		cache.SetCache(params.CacheKey(), contentType+"\n"+string(body), derivativeCacheTTL)
+	}
+	return imageResponse(contentType, body), nil
+}
+
+func (s serviceImpl) signImage(ctx context.Context, params images.Params, endpointURL string) (types.APIResponse, error) {
+	if s.signingKey == "" {
+		return types.APIResponse{
+			Code: http.StatusServiceUnavailable,
+		}, fmt.Errorf("image proxy is not configured")
+	}
+	if err := s.validate(params); err != nil {
+		return types.APIResponse{
+			Code: http.StatusBadRequest,
+		}, err
+	}
+
+	return types.APIResponse{
+		Code:    http.StatusOK,
+		Message: "success",
+		Data: signedURL{
+			URL: endpointURL + "?" + params.Query(s.signingKey).Encode(),
+		},
+	}, nil
+}
+
+func (s serviceImpl) validate(params images.Params) error {
+	u, err := url.Parse(params.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid image url: %s", params.URL)
+	}
+	if params.Width < 0 || params.Height < 0 || params.Width > s.maxDimension || params.Height > s.maxDimension {
+		return fmt.Errorf("width and height must be between 0 and %d", s.maxDimension)
+	}
+	switch params.Format {
+	case "", images.FormatJPEG, images.FormatPNG:
+		return nil
+	}
+	return fmt.Errorf("unsupported format: %s", params.Format)
+}
+
+func imageResponse(contentType string, body []byte) types.APIResponse {
+	return types.APIResponse{
+		Code:    http.StatusOK,
+		Message: "success",
+		Data: renderedImage{
+			ContentType: contentType,
+			Body:        body,
+		},
+	}
+}
@@ -0,0 +1,25 @@
+package images
+
+type imageQuery struct {
+	URL       string `query:"url"`
+	Width     int    `query:"w"`
+	Height    int    `query:"h"`
+	Format    string `query:"format"`
+	Signature string `query:"sig"`
+}
+
+type signBody struct {
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Format string `json:"format"`
+}
+
+type signedURL struct {
+	URL string `json:"url"`
+}
+
+type renderedImage struct {
+	ContentType string
+	Body        []byte
+}
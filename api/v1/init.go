@@ -2,10 +2,27 @@ package v1
 
 import (
 	"github.com/labstack/echo/v4"
+	"github.com/lufeed/feed-parser-api/api/v1/filters"
+	"github.com/lufeed/feed-parser-api/api/v1/icons"
+	"github.com/lufeed/feed-parser-api/api/v1/images"
+	"github.com/lufeed/feed-parser-api/api/v1/metrics"
 	"github.com/lufeed/feed-parser-api/api/v1/parsing"
+	"github.com/lufeed/feed-parser-api/api/v1/recipes"
+	"github.com/lufeed/feed-parser-api/api/v1/render"
 	"github.com/lufeed/feed-parser-api/internal/config"
 )
 
 func SetupRoutes(group *echo.Group, cfg *config.AppConfig) {
+	filters.Initialize(group.Group("/filters"))
+	icons.Initialize(group.Group("/icons"))
+	images.Initialize(group.Group("/images"))
+	metrics.Initialize(group.Group("/metrics"))
 	parsing.Initialize(group.Group("/parsing"))
+	recipes.Initialize(group.Group("/recipes"))
+	render.Initialize(group.Group("/render"))
+}
+
+// SetupPublicRoutes registers the routes that authenticate requests without the API key.
+func SetupPublicRoutes(group *echo.Group, cfg *config.AppConfig) {
+	images.InitializePublic(group.Group("/images"))
 }
@@ -0,0 +1,30 @@
+package metrics
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/lufeed/feed-parser-api/internal/types"
+)
+
+type controllerImpl struct {
+	service service
+}
+
+func newController(service service) types.Registerer {
+	return controllerImpl{
+		service: service,
+	}
+}
+
+func (c controllerImpl) Register(group *echo.Group) {
+
+	group.GET("", c.getMetrics)
+}
+
+func (c controllerImpl) getMetrics(ctx echo.Context) error {
+	data, err := c.service.getMetrics(ctx.Request().Context())
+	if err != nil {
+		return echo.NewHTTPError(data.StatusCode(), err.Error())
+	}
+
+	return ctx.JSON(data.StatusCode(), data)
+}
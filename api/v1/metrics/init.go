@@ -0,0 +1,15 @@
+package metrics
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/lufeed/feed-parser-api/internal/config"
+	"github.com/lufeed/feed-parser-api/internal/proxy"
+	"github.com/lufeed/feed-parser-api/internal/scheduler"
+)
+
+func Initialize(group *echo.Group) {
+	s := newService(scheduler.Get(), proxy.NewManager(config.GetConfig()))
+	c := newController(s)
+
+	c.Register(group)
+}
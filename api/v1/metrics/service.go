@@ -0,0 +1,38 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/lufeed/feed-parser-api/internal/proxy"
+	"github.com/lufeed/feed-parser-api/internal/scheduler"
+	"github.com/lufeed/feed-parser-api/internal/types"
+)
+
+type service interface {
+	getMetrics(ctx context.Context) (types.APIResponse, error)
+}
+
+type serviceImpl struct {
+	scheduler    *scheduler.Scheduler
+	proxyManager *proxy.Manager
+}
+
+func newService(s *scheduler.Scheduler, pm *proxy.Manager) service {
+	return serviceImpl{
+		scheduler:    s,
+		proxyManager: pm,
+	}
+}
+
+func (s serviceImpl) getMetrics(ctx context.Context) (types.APIResponse, error) {
+	return types.APIResponse{
+		Code:    http.StatusOK,
+		Message: "success",
+		Data: metricsResponse{
+			Scheduler:  s.scheduler.Stats(),
+			Proxies:    s.proxyManager.Stats(),
+			ProxyQueue: s.proxyManager.QueueStats(),
+		},
+	}, nil
+}
@@ -0,0 +1,15 @@
+package metrics
+
+import (
+	"github.com/lufeed/feed-parser-api/internal/proxy"
+	"github.com/lufeed/feed-parser-api/internal/scheduler"
+)
+
+type metricsResponse struct {
+	// Scheduler holds the outbound request queues of this instance
+	Scheduler scheduler.Stats `json:"scheduler"`
+	// Proxies holds the health of the configured proxies
+	Proxies []proxy.Stats `json:"proxies"`
+	// ProxyQueue holds the wait for free proxies
+	ProxyQueue proxy.QueueStats `json:"proxy_queue"`
+}
@@ -2,6 +2,7 @@ package parsing
 
 import (
 	"github.com/labstack/echo/v4"
+	"github.com/lufeed/feed-parser-api/internal/proxy"
 	"github.com/lufeed/feed-parser-api/internal/types"
 	"net/http"
 )
@@ -29,7 +30,12 @@ func (c controllerImpl) parseUrl(ctx echo.Context) error {
 		return ctx.JSON(http.StatusBadRequest, err.Error())
 	}
 
-	data, err := c.service.parseUrl(ctx.Request().Context(), body.URL, body.SendHTML)
+	reqCtx, err := proxy.WithRequestSelection(ctx.Request().Context(), body.Proxy)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	data, err := c.service.parseUrl(reqCtx, body.URL, body.SendHTML)
 	if err != nil {
 		return echo.NewHTTPError(data.StatusCode(), err.Error())
 	}
@@ -38,13 +44,18 @@ func (c controllerImpl) parseUrl(ctx echo.Context) error {
 }
 
 func (c controllerImpl) parseSource(ctx echo.Context) error {
-	var body requestBody
+	var body sourceRequestBody
 	err := ctx.Bind(&body)
 	if err != nil {
 		return ctx.JSON(http.StatusBadRequest, err.Error())
 	}
 
-	data, err := c.service.parseSource(ctx.Request().Context(), body.URL, body.SendHTML)
+	reqCtx, err := proxy.WithRequestSelection(ctx.Request().Context(), body.Proxy)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	data, err := c.service.parseSource(reqCtx, body.URL, body.SendHTML, body.Filter, body.FilterID)
 	if err != nil {
 		return echo.NewHTTPError(data.StatusCode(), err.Error())
 	}
@@ -2,8 +2,12 @@ package parsing
 
 import (
 	"context"
+	"errors"
 	"net/http"
 
+	"github.com/lufeed/feed-parser-api/api/v1/status"
+	"github.com/lufeed/feed-parser-api/internal/filter"
+	"github.com/lufeed/feed-parser-api/internal/models"
 	"github.com/lufeed/feed-parser-api/internal/parser"
 	"github.com/lufeed/feed-parser-api/internal/proxy"
 	"github.com/lufeed/feed-parser-api/internal/types"
@@ -11,7 +15,7 @@ import (
 
 type service interface {
 	parseUrl(ctx context.Context, inputUrl string, sendHTML bool) (types.APIResponse, error)
-	parseSource(ctx context.Context, inputUrl string, sendHTML bool) (types.APIResponse, error)
+	parseSource(ctx context.Context, inputUrl string, sendHTML bool, rules *models.ItemFilter, filterID string) (types.APIResponse, error)
 }
 
 type serviceImpl struct {
@@ -30,7 +34,7 @@ func (s serviceImpl) parseUrl(ctx context.Context, inputUrl string, sendHTML boo
 	source, err := urlParser.Exec(inputUrl, sendHTML, nil)
 	if err != nil {
 		return types.APIResponse{
-			Code: http.StatusBadRequest,
+			Code: status.ForError(err, http.StatusBadRequest),
 		}, err
 	}
 
@@ -41,13 +45,26 @@ func (s serviceImpl) parseUrl(ctx context.Context, inputUrl string, sendHTML boo
 	}, nil
 }
 
-func (s serviceImpl) parseSource(ctx context.Context, inputUrl string, sendHTML bool) (types.APIResponse, error) {
+func (s serviceImpl) parseSource(ctx context.Context, inputUrl string, sendHTML bool, rules *models.ItemFilter, filterID string) (types.APIResponse, error) {
+	itemFilter, err := filter.Resolve(rules, filterID, "", "")
+	if errors.Is(err, filter.ErrFilterNotFound) {
+		return types.APIResponse{
+			Code: http.StatusNotFound,
+		}, err
+	}
+	if err != nil {
+		return types.APIResponse{
+			Code: http.StatusBadRequest,
+		}, err
+	}
+
 	sourceParser := parser.NewSourceParser(ctx, s.proxyManager)
+	sourceParser.SetFilter(itemFilter)
 
 	feeds, err := sourceParser.Exec(inputUrl, sendHTML, nil)
 	if err != nil {
 		return types.APIResponse{
-			Code: http.StatusInternalServerError,
+			Code: status.ForError(err, http.StatusInternalServerError),
 		}, err
 	}
 
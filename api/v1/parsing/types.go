@@ -1,6 +1,21 @@
 package parsing
 
+import (
+	"github.com/lufeed/feed-parser-api/internal/models"
+	"github.com/lufeed/feed-parser-api/internal/proxy"
+)
+
 type requestBody struct {
 	URL      string `json:"url" binding:"required"`
 	SendHTML bool   `json:"send_html"`
+	// Proxy overrides how proxies are picked for the request
+	Proxy *proxy.Selection `json:"proxy"`
+}
+
+type sourceRequestBody struct {
+	URL      string             `json:"url" binding:"required"`
+	SendHTML bool               `json:"send_html"`
+	Filter   *models.ItemFilter `json:"filter"`
+	FilterID string             `json:"filter_id"`
+	Proxy    *proxy.Selection   `json:"proxy"`
 }
@@ -0,0 +1,116 @@
+package recipes
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/lufeed/feed-parser-api/internal/types"
+)
+
+type controllerImpl struct {
+	service service
+}
+
+func newController(service service) types.Registerer {
+	return controllerImpl{
+		service: service,
+	}
+}
+
+func (c controllerImpl) Register(group *echo.Group) {
+
+	group.GET("", c.listRecipes)
+	group.POST("", c.createRecipe)
+	group.POST("/dry-run", c.dryRun)
+	group.GET("/:id", c.getRecipe)
+	group.PUT("/:id", c.updateRecipe)
+	group.DELETE("/:id", c.deleteRecipe)
+}
+
+func (c controllerImpl) listRecipes(ctx echo.Context) error {
+	data, err := c.service.listRecipes(ctx.Request().Context())
+	if err != nil {
+		return echo.NewHTTPError(data.StatusCode(), err.Error())
+	}
+
+	return ctx.JSON(data.StatusCode(), data)
+}
+
+func (c controllerImpl) createRecipe(ctx echo.Context) error {
+	var body recipeBody
+	err := ctx.Bind(&body)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	data, err := c.service.createRecipe(ctx.Request().Context(), body)
+	if err != nil {
+		return echo.NewHTTPError(data.StatusCode(), err.Error())
+	}
+
+	return ctx.JSON(data.StatusCode(), data)
+}
+
+func (c controllerImpl) getRecipe(ctx echo.Context) error {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid recipe id")
+	}
+
+	data, err := c.service.getRecipe(ctx.Request().Context(), id)
+	if err != nil {
+		return echo.NewHTTPError(data.StatusCode(), err.Error())
+	}
+
+	return ctx.JSON(data.StatusCode(), data)
+}
+
+func (c controllerImpl) updateRecipe(ctx echo.Context) error {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid recipe id")
+	}
+
+	var body recipeBody
+	err = ctx.Bind(&body)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	data, err := c.service.updateRecipe(ctx.Request().Context(), id, body)
+	if err != nil {
+		return echo.NewHTTPError(data.StatusCode(), err.Error())
+	}
+
+	return ctx.JSON(data.StatusCode(), data)
+}
+
+func (c controllerImpl) deleteRecipe(ctx echo.Context) error {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid recipe id")
+	}
+
+	data, err := c.service.deleteRecipe(ctx.Request().Context(), id)
+	if err != nil {
+		return echo.NewHTTPError(data.StatusCode(), err.Error())
+	}
+
+	return ctx.JSON(data.StatusCode(), data)
+}
+
+func (c controllerImpl) dryRun(ctx echo.Context) error {
+	var body recipeBody
+	err := ctx.Bind(&body)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	data, err := c.service.dryRun(ctx.Request().Context(), body)
+	if err != nil {
+		return echo.NewHTTPError(data.StatusCode(), err.Error())
+	}
+
+	return ctx.JSON(data.StatusCode(), data)
+}
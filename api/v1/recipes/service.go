@@ -0,0 +1,196 @@
+package recipes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lufeed/feed-parser-api/api/v1/status"
+	"github.com/lufeed/feed-parser-api/internal/models"
+	"github.com/lufeed/feed-parser-api/internal/proxy"
+	"github.com/lufeed/feed-parser-api/internal/scrape"
+	"github.com/lufeed/feed-parser-api/internal/types"
+)
+
+type service interface {
+	listRecipes(ctx context.Context) (types.APIResponse, error)
+	createRecipe(ctx context.Context, body recipeBody) (types.APIResponse, error)
+	getRecipe(ctx context.Context, id uuid.UUID) (types.APIResponse, error)
+	updateRecipe(ctx context.Context, id uuid.UUID, body recipeBody) (types.APIResponse, error)
+	deleteRecipe(ctx context.Context, id uuid.UUID) (types.APIResponse, error)
+	dryRun(ctx context.Context, body recipeBody) (types.APIResponse, error)
+}
+
+type serviceImpl struct {
+	proxyManager *proxy.Manager
+}
+
+func newService(proxyManager *proxy.Manager) service {
+	return serviceImpl{
+		proxyManager: proxyManager,
+	}
+}
+
+func (s serviceImpl) listRecipes(ctx context.Context) (types.APIResponse, error) {
+	recipes, err := scrape.ListRecipes()
+	if err != nil {
+		return types.APIResponse{
+			Code: http.StatusInternalServerError,
+		}, err
+	}
+
+	return types.APIResponse{
+		Code:    http.StatusOK,
+		Message: "success",
+		Data:    recipes,
+	}, nil
+}
+
+func (s serviceImpl) createRecipe(ctx context.Context, body recipeBody) (types.APIResponse, error) {
+	id, err := uuid.NewUUID()
+	if err != nil {
+		return types.APIResponse{
+			Code: http.StatusInternalServerError,
+		}, err
+	}
+
+	now := time.Now().UTC()
+	recipe := body.toRecipe()
+	recipe.ID = id
+	recipe.CreatedAt = now
+	recipe.UpdatedAt = now
+
+	return s.saveRecipe(recipe, http.StatusCreated)
+}
+
+func (s serviceImpl) getRecipe(ctx context.Context, id uuid.UUID) (types.APIResponse, error) {
+	recipe, err := scrape.GetRecipe(id)
+	if err != nil {
+		return types.APIResponse{
+			Code: recipeErrorCode(err),
+		}, err
+	}
+
+	return types.APIResponse{
+		Code:    http.StatusOK,
+		Message: "success",
+		Data:    recipe,
+	}, nil
+}
+
+func (s serviceImpl) updateRecipe(ctx context.Context, id uuid.UUID, body recipeBody) (types.APIResponse, error) {
+	existing, err := scrape.GetRecipe(id)
+	if err != nil {
+		return types.APIResponse{
+			Code: recipeErrorCode(err),
+		}, err
+	}
+
+	recipe := body.toRecipe()
+	recipe.ID = existing.ID
+	recipe.CreatedAt = existing.CreatedAt
+	recipe.UpdatedAt = time.Now().UTC()
+	if err := scrape.Validate(recipe); err != nil {
+		return types.APIResponse{
+			Code: http.StatusBadRequest,
+		}, err
+	}
+
+	if recipe.ListURL != existing.ListURL {
+		// Release the old URL so it no longer resolves to this recipe
+		if err := scrape.DeleteRecipe(existing.ID); err != nil {
+			return types.APIResponse{
+				Code: http.StatusInternalServerError,
+			}, err
+		}
+	}
+
+	return s.saveRecipe(recipe, http.StatusOK)
+}
+
+func (s serviceImpl) deleteRecipe(ctx context.Context, id uuid.UUID) (types.APIResponse, error) {
+	err := scrape.DeleteRecipe(id)
+	if err != nil {
+		return types.APIResponse{
+			Code: recipeErrorCode(err),
+		}, err
+	}
+
+	return types.APIResponse{
+		Code:    http.StatusOK,
+		Message: "success",
+	}, nil
+}
+
+func (s serviceImpl) dryRun(ctx context.Context, body recipeBody) (types.APIResponse, error) {
+	recipe := body.toRecipe()
+	if err := scrape.Validate(recipe); err != nil {
+		return types.APIResponse{
+			Code: http.StatusBadRequest,
+		}, err
+	}
+
+	cl, proxyID, err := s.proxyManager.Acquire(ctx, recipe.ListURL)
+	if err != nil {
+		return types.APIResponse{
+			Code: http.StatusServiceUnavailable,
+		}, err
+	}
+	defer s.proxyManager.ReleaseProxy(proxyID)
+
+	items, err := scrape.NewExtractor(cl, recipe).Exec()
+	if err != nil {
+		return types.APIResponse{
+			Code: status.ForError(err, http.StatusUnprocessableEntity),
+		}, err
+	}
+
+	return types.APIResponse{
+		Code:    http.StatusOK,
+		Message: "success",
+		Data:    items,
+	}, nil
+}
+
+func (s serviceImpl) saveRecipe(recipe models.ScrapeRecipe, code int) (types.APIResponse, error) {
+	if err := scrape.Validate(recipe); err != nil {
+		return types.APIResponse{
+			Code: http.StatusBadRequest,
+		}, err
+	}
+
+	if err := scrape.SaveRecipe(recipe); err != nil {
+		return types.APIResponse{
+			Code: http.StatusInternalServerError,
+		}, err
+	}
+
+	return types.APIResponse{
+		Code:    code,
+		Message: "success",
+		Data:    recipe,
+	}, nil
+}
+
+func (b recipeBody) toRecipe() models.ScrapeRecipe {
+	return models.ScrapeRecipe{
+		Name:            b.Name,
+		ListURL:         b.ListURL,
+		ItemSelector:    b.ItemSelector,
+		TitleSelector:   b.TitleSelector,
+		LinkSelector:    b.LinkSelector,
+		DateSelector:    b.DateSelector,
+		DateFormat:      b.DateFormat,
+		ImageSelector:   b.ImageSelector,
+		SummarySelector: b.SummarySelector,
+	}
+}
+
+func recipeErrorCode(err error) int {
+	if errors.Is(err, scrape.ErrRecipeNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
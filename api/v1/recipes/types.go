@@ -0,0 +1,13 @@
+package recipes
+
+type recipeBody struct {
+	Name            string `json:"name"`
+	ListURL         string `json:"list_url"`
+	ItemSelector    string `json:"item_selector"`
+	TitleSelector   string `json:"title_selector"`
+	LinkSelector    string `json:"link_selector"`
+	DateSelector    string `json:"date_selector"`
+	DateFormat      string `json:"date_format"`
+	ImageSelector   string `json:"image_selector"`
+	SummarySelector string `json:"summary_selector"`
+}
@@ -0,0 +1,56 @@
+package render
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lufeed/feed-parser-api/internal/syndication"
+	"github.com/lufeed/feed-parser-api/internal/types"
+)
+
+type controllerImpl struct {
+	service service
+}
+
+func newController(service service) types.Registerer {
+	return controllerImpl{
+		service: service,
+	}
+}
+
+func (c controllerImpl) Register(group *echo.Group) {
+
+	group.GET("", c.render)
+}
+
+func (c controllerImpl) render(ctx echo.Context) error {
+	var query renderQuery
+	err := ctx.Bind(&query)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	format := query.Format
+	if format == "" {
+		format = syndication.FormatFromAccept(ctx.Request().Header.Get("Accept"))
+	}
+
+	data, err := c.service.render(ctx.Request().Context(), query.URLs, format, query.SendHTML, selfURL(ctx))
+	if err != nil {
+		return echo.NewHTTPError(data.StatusCode(), err.Error())
+	}
+
+	doc := data.Data.(renderedDocument)
+	return ctx.Blob(data.StatusCode(), doc.ContentType, doc.Body)
+}
+
+// selfURL is the address the document was requested from, without the API key.
+func selfURL(ctx echo.Context) string {
+	u := *ctx.Request().URL
+	query := u.Query()
+	query.Del("api_key")
+	u.RawQuery = query.Encode()
+	u.Scheme = ctx.Scheme()
+	u.Host = ctx.Request().Host
+	return u.String()
+}
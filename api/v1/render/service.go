@@ -0,0 +1,112 @@
+package render
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/lufeed/feed-parser-api/api/v1/status"
+	"github.com/lufeed/feed-parser-api/internal/logger"
+	"github.com/lufeed/feed-parser-api/internal/parser"
+	"github.com/lufeed/feed-parser-api/internal/proxy"
+	"github.com/lufeed/feed-parser-api/internal/syndication"
+	"github.com/lufeed/feed-parser-api/internal/types"
+)
+
+const maxSources = 10
+
+type service interface {
+	render(ctx context.Context, urls []string, format string, sendHTML bool, selfURL string) (types.APIResponse, error)
+}
+
+type serviceImpl struct {
+	proxyManager *proxy.Manager
+}
+
+func newService(proxyManager *proxy.Manager) service {
+	return serviceImpl{
+		proxyManager: proxyManager,
+	}
+}
+
+func (s serviceImpl) render(ctx context.Context, urls []string, format string, sendHTML bool, selfURL string) (types.APIResponse, error) {
+	if len(urls) == 0 {
+		return types.APIResponse{
+			Code: http.StatusBadRequest,
+		}, fmt.Errorf("at least one url is required")
+	}
+	if len(urls) > maxSources {
+		return types.APIResponse{
+			Code: http.StatusBadRequest,
+		}, fmt.Errorf("at most %d sources can be merged", maxSources)
+	}
+	if format != syndication.FormatRSS && format != syndication.FormatAtom && format != syndication.FormatJSON {
+		return types.APIResponse{
+			Code: http.StatusBadRequest,
+		}, fmt.Errorf("unsupported format: %s", format)
+	}
+
+	channel := syndication.Channel{SelfURL: selfURL}
+	var items []syndication.Item
+	var names []string
+	var lastErr error
+
+	for _, sourceURL := range urls {
+		sourceParser := parser.NewSourceParser(ctx, s.proxyManager)
+		feeds, err := sourceParser.Exec(sourceURL, sendHTML, nil)
+		if err != nil {
+			logger.GetSugaredLogger().Warnf("Cannot render source %s: %s", sourceURL, err.Error())
+			lastErr = err
+			continue
+		}
+
+		source := sourceParser.Source()
+		names = append(names, source.Name)
+		for _, f := range feeds {
+			item := syndication.Item{Feed: f}
+			if len(urls) > 1 {
+				item.SourceName = source.Name
+				item.SourceURL = source.FeedURL
+			}
+			items = append(items, item)
+		}
+
+		if len(urls) == 1 {
+			channel.Title = source.Name
+			channel.Description = source.Description
+			channel.HomeURL = source.HomeURL
+			if source.ImageURL != nil {
+				channel.ImageURL = *source.ImageURL
+			}
+		}
+	}
+
+	if len(names) == 0 {
+		return types.APIResponse{
+			Code: status.ForError(lastErr, http.StatusBadGateway),
+		}, lastErr
+	}
+
+	if len(urls) > 1 {
+		channel.Title = strings.Join(names, ", ")
+		channel.Description = fmt.Sprintf("Merged feed of %d sources", len(names))
+		channel.HomeURL = selfURL
+	}
+
+	body, err := syndication.Write(format, channel, syndication.SortByDate(items))
+	if err != nil {
+		return types.APIResponse{
+			Code: http.StatusInternalServerError,
+		}, err
+	}
+
+	return types.APIResponse{
+		Code:    http.StatusOK,
+		Message: "success",
+		Data: renderedDocument{
+			ContentType: syndication.ContentType(format),
+			Body:        body,
+		},
+	}, nil
+}
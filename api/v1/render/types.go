@@ -0,0 +1,12 @@
+package render
+
+type renderQuery struct {
+	URLs     []string `query:"url"`
+	Format   string   `query:"format"`
+	SendHTML bool     `query:"send_html"`
+}
+
+type renderedDocument struct {
+	ContentType string
+	Body        []byte
+}
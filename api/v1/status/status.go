@@ -0,0 +1,32 @@
+package status
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/lufeed/feed-parser-api/internal/breaker"
+	"github.com/lufeed/feed-parser-api/internal/proxy"
+	"github.com/lufeed/feed-parser-api/internal/robots"
+	"github.com/lufeed/feed-parser-api/internal/ssrf"
+)
+
+// ForError returns the status answering an error of an outbound fetch: 400 for
+// blocked URLs, 403 for pages robots.txt disallows, 503 for unavailable hosts, sites
+// whose crawl delay is not over and when no proxy is free. Other errors get fallback.
+func ForError(err error, fallback int) int {
+	var blocked *ssrf.BlockedError
+	if errors.As(err, &blocked) {
+		return http.StatusBadRequest
+	}
+	var disallowed *robots.DisallowedError
+	if errors.As(err, &disallowed) {
+		return http.StatusForbidden
+	}
+	var unavailable *breaker.HostUnavailableError
+	var early *robots.TooEarlyError
+	var noProxy *proxy.UnavailableError
+	if errors.As(err, &unavailable) || errors.As(err, &early) || errors.As(err, &noProxy) {
+		return http.StatusServiceUnavailable
+	}
+	return fallback
+}
@@ -6,9 +6,11 @@ import (
 
 	"github.com/lufeed/feed-parser-api/internal/cache"
 	"github.com/lufeed/feed-parser-api/internal/config"
+	"github.com/lufeed/feed-parser-api/internal/filter"
 	"github.com/lufeed/feed-parser-api/internal/logger"
 	"github.com/lufeed/feed-parser-api/internal/models"
 	"github.com/lufeed/feed-parser-api/internal/parser"
+	"github.com/lufeed/feed-parser-api/internal/placeholder"
 	"github.com/lufeed/feed-parser-api/internal/proxy"
 	"go.uber.org/zap"
 )
@@ -44,13 +46,22 @@ type parseSourceRequest struct {
 	FeedID   string `json:"feed_id"`
 	FeedName string `json:"feed_name"`
 	UserID   string `json:"user_id"`
+	// Filter and FilterID override the filter attached to the subscription
+	Filter   *models.ItemFilter `json:"filter"`
+	FilterID string             `json:"filter_id"`
+	// Tenant selects the placeholder policy, the default one when empty
+	Tenant string `json:"tenant"`
+	// Proxy overrides how proxies are picked for the request
+	Proxy *proxy.Selection `json:"proxy"`
 }
 
 type parseURLRequest struct {
-	RequestID string `json:"request_id"`
-	URL       string `json:"url"`
-	SendHTML  bool   `json:"send_html"`
-	UserID    string `json:"user_id"`
+	RequestID string           `json:"request_id"`
+	URL       string           `json:"url"`
+	SendHTML  bool             `json:"send_html"`
+	UserID    string           `json:"user_id"`
+	Tenant    string           `json:"tenant"`
+	Proxy     *proxy.Selection `json:"proxy"`
 }
 
 func listenSourceRequests(ctx context.Context, pm *proxy.Manager) {
@@ -61,7 +72,18 @@ func listenSourceRequests(ctx context.Context, pm *proxy.Manager) {
 			logger.GetSugaredLogger().Errorf("Invalid parse_source_request: %v", err)
 			continue
 		}
-		sp := parser.NewSourceParser(ctx, pm)
+		itemFilter, err := filter.Resolve(req.Filter, req.FilterID, req.UserID, req.FeedID)
+		if err != nil {
+			logger.GetSugaredLogger().Errorf("Invalid filter for %s: %v", req.URL, err)
+			continue
+		}
+		reqCtx, err := proxy.WithRequestSelection(placeholder.WithPolicy(ctx, placeholder.ForTenant(req.Tenant)), req.Proxy)
+		if err != nil {
+			logger.GetSugaredLogger().Errorf("Invalid proxy selection for %s: %v", req.URL, err)
+			continue
+		}
+		sp := parser.NewSourceParser(reqCtx, pm)
+		sp.SetFilter(itemFilter)
 		sp.Exec(req.URL, req.SendHTML, func(item models.Feed) {
 			item.FeedID = req.FeedID
 			item.FeedName = req.FeedName
@@ -83,7 +105,12 @@ func listenURLRequests(ctx context.Context, pm *proxy.Manager) {
 			logger.GetSugaredLogger().Errorf("Invalid parse_url_request: %v", err)
 			continue
 		}
-		up := parser.NewURLParser(ctx, pm)
+		reqCtx, err := proxy.WithRequestSelection(placeholder.WithPolicy(ctx, placeholder.ForTenant(req.Tenant)), req.Proxy)
+		if err != nil {
+			logger.GetSugaredLogger().Errorf("Invalid proxy selection for %s: %v", req.URL, err)
+			continue
+		}
+		up := parser.NewURLParser(reqCtx, pm)
 		up.Exec(req.URL, req.SendHTML, func(source models.Source) {
 			source.UserID = req.UserID
 			source.RequestID = req.RequestID
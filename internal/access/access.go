@@ -0,0 +1,275 @@
+package access
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/lufeed/feed-parser-api/internal/config"
+	"github.com/lufeed/feed-parser-api/internal/logger"
+	"github.com/lufeed/feed-parser-api/internal/models"
+)
+
+const (
+	defaultTruncationRatio = 0.5
+	defaultMinFeedLength   = 600
+	defaultLoginPattern    = `(?i)/(login|log-in|signin|sign-in|auth|account/login|sso)\b`
+	defaultPaywallPattern  = `(?i)/(subscribe|subscription|paywall|premium|register|offers?)\b`
+)
+
+// Signals are the hints about access restrictions gathered while fetching an item page.
+type Signals struct {
+	// StatusCode of the page response, FinalURL is the URL after redirects
+	StatusCode int
+	FinalURL   string
+	// AccessibleForFree is the schema.org isAccessibleForFree of the article, if declared
+	AccessibleForFree *bool
+	// PaywalledSelectors are the hasPart.cssSelector of parts that are not free
+	PaywalledSelectors []string
+	// Markup holds script sources, inline scripts and class names, where paywall
+	// providers leave their fingerprints
+	Markup string
+	// PageTextLength is the number of characters of article text on the page
+	PageTextLength int
+}
+
+// Fingerprint recognizes a paywall provider by a pattern in the page markup.
+type Fingerprint struct {
+	Name    string
+	Pattern *regexp.Regexp
+	Access  string
+}
+
+// Result is the access classification of an item and what it is based on.
+type Result struct {
+	Access  string
+	Reasons []string
+}
+
+// Classifier turns Signals into an access classification.
+type Classifier struct {
+	truncationRatio float64
+	minFeedLength   int
+	loginURL        *regexp.Regexp
+	paywallURL      *regexp.Regexp
+	fingerprints    []Fingerprint
+	domains         map[string]string
+}
+
+// Paywall providers. The access of restricted articles is taken from the provider;
+// metering providers on an article served in full mean the article is metered.
+var builtinFingerprints = []struct {
+	name, pattern, access string
+}{
+	{"piano", `tinypass\.com|piano\.io|tp\.experience|\btp\.push\(`, models.AccessMetered},
+	{"poool", `poool\.fr|poool-widget`, models.AccessMetered},
+	{"zephr", `zephr|blaize\.io`, models.AccessMetered},
+	{"laterpay", `laterpay\.net`, models.AccessMetered},
+	{"pelcro", `pelcro\.com`, models.AccessHardPaywall},
+	{"memberful", `memberful\.com`, models.AccessHardPaywall},
+	{"steady", `steadyhq\.com`, models.AccessHardPaywall},
+	{"substack", `paywall-jump|substack-paywall`, models.AccessHardPaywall},
+	{"medium", `meteredContent|meter-content|"isLockedPreviewOnly":\s*true`, models.AccessMetered},
+	{"wordpress-membership", `pmpro_content_message|mepr-unauthorized|rcp_restricted|swpm-restricted`, models.AccessLoginRequired},
+	{"generic", `\b(paywall|piano-offer|subscriber-only|premium-content|article-locked|regwall)\b`, models.AccessHardPaywall},
+}
+
+var (
+	classifier     *Classifier
+	classifierOnce sync.Once
+)
+
+// GetClassifier returns the classifier built from the configuration, or nil when
+// access classification is disabled.
+func GetClassifier() *Classifier {
+	classifierOnce.Do(func() {
+		cfg := config.GetConfig()
+		if cfg == nil || !cfg.Access.Enabled {
+			return
+		}
+		c, err := NewClassifier(cfg.Access)
+		if err != nil {
+			logger.GetSugaredLogger().Errorf("Access classification disabled: %s", err.Error())
+			return
+		}
+		classifier = c
+	})
+	return classifier
+}
+
+// NewClassifier builds a classifier from the built-in fingerprints and the configured
+// rules. Configured fingerprints replace built-in ones with the same name.
+func NewClassifier(cfg config.AccessConfig) (*Classifier, error) {
+	c := &Classifier{
+		truncationRatio: cfg.TruncationRatio,
+		minFeedLength:   cfg.MinFeedLength,
+		domains:         make(map[string]string),
+	}
+	if c.truncationRatio <= 0 || c.truncationRatio >= 1 {
+		c.truncationRatio = defaultTruncationRatio
+	}
+	if c.minFeedLength <= 0 {
+		c.minFeedLength = defaultMinFeedLength
+	}
+
+	var err error
+	loginPattern, paywallPattern := cfg.LoginURLPattern, cfg.PaywallURLPattern
+	if loginPattern == "" {
+		loginPattern = defaultLoginPattern
+	}
+	if paywallPattern == "" {
+		paywallPattern = defaultPaywallPattern
+	}
+	if c.loginURL, err = regexp.Compile(loginPattern); err != nil {
+		return nil, fmt.Errorf("invalid login_url_pattern: %w", err)
+	}
+	if c.paywallURL, err = regexp.Compile(paywallPattern); err != nil {
+		return nil, fmt.Errorf("invalid paywall_url_pattern: %w", err)
+	}
+
+	configured := make(map[string]bool)
+	for _, fp := range cfg.Fingerprints {
+		if !IsValid(fp.Access) {
+			return nil, fmt.Errorf("invalid access %q of fingerprint %s", fp.Access, fp.Name)
+		}
+		pattern, err := regexp.Compile("(?i)" + fp.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern of fingerprint %s: %w", fp.Name, err)
+		}
+		c.fingerprints = append(c.fingerprints, Fingerprint{Name: fp.Name, Pattern: pattern, Access: fp.Access})
+		configured[fp.Name] = true
+	}
+	for _, fp := range builtinFingerprints {
+		if !configured[fp.name] {
+			c.fingerprints = append(c.fingerprints, Fingerprint{Name: fp.name, Pattern: regexp.MustCompile("(?i)" + fp.pattern), Access: fp.access})
+		}
+	}
+
+	for _, d := range cfg.Domains {
+		if !IsValid(d.Access) {
+			return nil, fmt.Errorf("invalid access %q of domain %s", d.Access, d.Domain)
+		}
+		c.domains[strings.ToLower(strings.TrimPrefix(d.Domain, "www."))] = d.Access
+	}
+	return c, nil
+}
+
+// IsValid reports whether access is one of the access classifications.
+func IsValid(access string) bool {
+	switch access {
+	case models.AccessFree, models.AccessMetered, models.AccessHardPaywall, models.AccessLoginRequired:
+		return true
+	}
+	return false
+}
+
+// IsRestrictedStatus reports whether a page answering with statusCode from finalURL
+// is behind a login rather than unavailable, so its item is kept and classified.
+func (c *Classifier) IsRestrictedStatus(statusCode int, finalURL string) bool {
+	if statusCode == 401 {
+		return true
+	}
+	return statusCode == 403 && c.loginURL.MatchString(urlPath(finalURL))
+}
+
+// Classify derives the access of an item page. itemURL selects domain overrides,
+// feedText is the article text the feed carries, to detect truncated pages.
+func (c *Classifier) Classify(itemURL string, s Signals, feedText string) Result {
+	if access, ok := c.domainAccess(itemURL); ok {
+		return Result{Access: access, Reasons: []string{"domain"}}
+	}
+
+	finalPath := urlPath(s.FinalURL)
+	switch {
+	case s.StatusCode == 401:
+		return Result{Access: models.AccessLoginRequired, Reasons: []string{"status:401"}}
+	case s.StatusCode == 403 && c.loginURL.MatchString(finalPath):
+		return Result{Access: models.AccessLoginRequired, Reasons: []string{"status:403", "login_redirect"}}
+	case finalPath != urlPath(itemURL) && c.loginURL.MatchString(finalPath):
+		return Result{Access: models.AccessLoginRequired, Reasons: []string{"login_redirect"}}
+	case finalPath != urlPath(itemURL) && c.paywallURL.MatchString(finalPath):
+		return Result{Access: models.AccessHardPaywall, Reasons: []string{"paywall_redirect"}}
+	}
+
+	var reasons []string
+	restricted := false
+	if s.AccessibleForFree != nil && !*s.AccessibleForFree {
+		restricted = true
+		reasons = append(reasons, "schema:isAccessibleForFree")
+	}
+	if len(s.PaywalledSelectors) > 0 {
+		restricted = true
+		reasons = append(reasons, "schema:hasPart")
+	}
+	if feedLength := utf8.RuneCountInString(strings.TrimSpace(feedText)); feedLength >= c.minFeedLength &&
+		float64(s.PageTextLength) < c.truncationRatio*float64(feedLength) {
+		restricted = true
+		reasons = append(reasons, "truncated")
+	}
+
+	// The strictest provider found decides the access of restricted articles
+	access := ""
+	metered := false
+	for _, fp := range c.fingerprints {
+		if fp.Pattern.MatchString(s.Markup) {
+			reasons = append(reasons, "fingerprint:"+fp.Name)
+			if severity(fp.Access) > severity(access) {
+				access = fp.Access
+			}
+			metered = metered || fp.Access == models.AccessMetered
+		}
+	}
+
+	switch {
+	case restricted && access == "":
+		return Result{Access: models.AccessHardPaywall, Reasons: reasons}
+	case restricted:
+		return Result{Access: access, Reasons: reasons}
+	case metered:
+		return Result{Access: models.AccessMetered, Reasons: reasons}
+	}
+	return Result{Access: models.AccessFree, Reasons: reasons}
+}
+
+func (c *Classifier) domainAccess(itemURL string) (string, bool) {
+	u, err := url.Parse(itemURL)
+	if err != nil {
+		return "", false
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	// Overrides of a domain apply to its subdomains as well
+	for host != "" {
+		if access, ok := c.domains[host]; ok {
+			return access, true
+		}
+		_, parent, found := strings.Cut(host, ".")
+		if !found {
+			break
+		}
+		host = parent
+	}
+	return "", false
+}
+
+func severity(access string) int {
+	switch access {
+	case models.AccessMetered:
+		return 1
+	case models.AccessLoginRequired:
+		return 2
+	case models.AccessHardPaywall:
+		return 3
+	}
+	return 0
+}
+
+func urlPath(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Path
+}
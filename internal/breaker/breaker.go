@@ -0,0 +1,193 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lufeed/feed-parser-api/internal/cache"
+	"github.com/lufeed/feed-parser-api/internal/config"
+	"github.com/lufeed/feed-parser-api/internal/logger"
+	"github.com/lufeed/feed-parser-api/internal/scheduler"
+	"github.com/lufeed/feed-parser-api/internal/ssrf"
+)
+
+// States of a host circuit
+const (
+	StateClosed   = "closed"
+	StateOpen     = "open"
+	StateHalfOpen = "half-open"
+)
+
+const (
+	defaultFailureThreshold = 5
+	defaultCooldown         = 30 * time.Second
+	defaultMaxCooldown      = 10 * time.Minute
+	// probeLease is how long the probe of a half-open circuit may take before another
+	// request may probe
+	probeLease = time.Minute
+)
+
+var (
+	breaker     *Breaker
+	breakerOnce sync.Once
+)
+
+// HostUnavailableError is returned without contacting a host whose circuit is open.
+type HostUnavailableError struct {
+	Host    string
+	RetryAt time.Time
+}
+
+func (e *HostUnavailableError) Error() string {
+	return fmt.Sprintf("host %s is unavailable, retrying after %s", e.Host, e.RetryAt.UTC().Format(time.RFC3339))
+}
+
+// Breaker keeps a circuit per host. A circuit opens after a number of consecutive
+// failures and lets one probe through once its cooldown is over: a successful probe
+// closes it, a failed one opens it again for twice as long.
+type Breaker struct {
+	store            store
+	failureThreshold int64
+	cooldown         time.Duration
+	maxCooldown      time.Duration
+}
+
+// Get returns the breaker configured by the breaker section of the configuration. Its
+// circuits are kept in the cache, so that all processes share them, or in memory when
+// there is no cache.
+func Get() *Breaker {
+	breakerOnce.Do(func() {
+		cfg := config.GetConfig()
+		if cfg == nil {
+			cfg = &config.AppConfig{}
+		}
+		var s store = newMemoryStore()
+		if cache.Available() {
+			s = redisStore{}
+		}
+		breaker = New(cfg.Breaker, s)
+	})
+	return breaker
+}
+
+// New builds a breaker keeping its circuits in s, unset settings take the defaults.
+func New(cfg config.BreakerConfig, s store) *Breaker {
+	b := &Breaker{
+		store:            s,
+		failureThreshold: int64(cfg.FailureThreshold),
+		cooldown:         time.Duration(cfg.Cooldown) * time.Second,
+		maxCooldown:      time.Duration(cfg.MaxCooldown) * time.Second,
+	}
+	if b.failureThreshold <= 0 {
+		b.failureThreshold = defaultFailureThreshold
+	}
+	if b.cooldown <= 0 {
+		b.cooldown = defaultCooldown
+	}
+	if b.maxCooldown < b.cooldown {
+		b.maxCooldown = max(defaultMaxCooldown, b.cooldown)
+	}
+	return b
+}
+
+// Allow decides whether a request to hostname may go out. The returned function
+// records its outcome and must be called once the request is done. Cache failures let
+// requests through.
+func (b *Breaker) Allow(hostname string) (func(success bool), error) {
+	name := normalizeHost(hostname)
+	st, err := b.store.load(name)
+	if err != nil {
+		logger.GetSugaredLogger().Debugf("Cannot load circuit of %s: %s", name, err.Error())
+		return func(bool) {}, nil
+	}
+
+	now := time.Now()
+	switch st.state(now) {
+	case StateOpen:
+		return nil, &HostUnavailableError{Host: name, RetryAt: st.openUntil}
+	case StateHalfOpen:
+		probe, err := b.store.probe(name, st.openUntil, probeLease)
+		if err == nil && !probe {
+			// Another request is probing the host
+			return nil, &HostUnavailableError{Host: name, RetryAt: now.Add(probeLease)}
+		}
+	}
+
+	return func(success bool) {
+		if err := b.record(name, st, success); err != nil {
+			logger.GetSugaredLogger().Debugf("Cannot record circuit of %s: %s", name, err.Error())
+		}
+	}, nil
+}
+
+func (b *Breaker) record(name string, st state, success bool) error {
+	if success {
+		if st.failures > 0 || !st.openUntil.IsZero() {
+			return b.store.reset(name)
+		}
+		return nil
+	}
+
+	failures, err := b.store.failure(name)
+	if err != nil {
+		return err
+	}
+	// A failed probe opens the circuit again right away
+	if failures < b.failureThreshold && st.openUntil.IsZero() {
+		return nil
+	}
+	cooldown := b.cooldown << min(st.trips, 16)
+	if cooldown > b.maxCooldown || cooldown <= 0 {
+		cooldown = b.maxCooldown
+	}
+	until := time.Now().Add(cooldown)
+	logger.GetSugaredLogger().Warnf("Circuit of %s opened after %d failures, until %s", name, failures, until.Format(time.RFC3339))
+	return b.store.trip(name, until, cooldown+b.maxCooldown)
+}
+
+// IsFailure reports whether a transport error counts against the host: connection
+// failures and timeouts do. Requests the caller canceled, the SSRF guard blocked or
+// that timed out waiting in the scheduler say nothing about the host, and neither do
+// proxies that cannot be reached.
+func IsFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var unavailable *HostUnavailableError
+	if errors.As(err, &unavailable) {
+		return false
+	}
+	var wait *scheduler.WaitError
+	if errors.As(err, &wait) {
+		return false
+	}
+	// net/http wraps the errors of the connection to a proxy this way
+	var op *net.OpError
+	if errors.As(err, &op) && op.Op == "proxyconnect" {
+		return false
+	}
+	var blocked *ssrf.BlockedError
+	return !errors.As(err, &blocked)
+}
+
+// IsFailureStatus reports whether a response status means the host is down rather than
+// the page unavailable.
+func IsFailureStatus(statusCode int) bool {
+	switch statusCode {
+	case 502, 503, 504, 520, 521, 522, 523, 524:
+		return true
+	}
+	return false
+}
+
+func normalizeHost(hostname string) string {
+	return strings.TrimPrefix(strings.ToLower(strings.TrimSpace(hostname)), "www.")
+}
@@ -0,0 +1,170 @@
+package breaker
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lufeed/feed-parser-api/internal/cache"
+)
+
+const (
+	keyPrefix      = "breaker:"
+	failuresField  = "failures"
+	openUntilField = "open_until"
+	tripsField     = "trips"
+)
+
+// state is the circuit of a host.
+type state struct {
+	// failures counts the consecutive failed requests
+	failures  int64
+	openUntil time.Time
+	// trips counts how often the circuit opened without closing in between
+	trips int64
+}
+
+func (s state) state(now time.Time) string {
+	switch {
+	case s.openUntil.IsZero():
+		return StateClosed
+	case now.Before(s.openUntil):
+		return StateOpen
+	}
+	return StateHalfOpen
+}
+
+type store interface {
+	load(host string) (state, error)
+	// failure counts a failed request and returns the consecutive failures
+	failure(host string) (int64, error)
+	// trip opens the circuit until the given time and forgets it after ttl
+	trip(host string, until time.Time, ttl time.Duration) error
+	reset(host string) error
+	// probe reports whether the caller is the one request probing the half-open
+	// circuit that was open until openUntil
+	probe(host string, openUntil time.Time, lease time.Duration) (bool, error)
+}
+
+// redisStore keeps circuits in a cache hash per host, shared by all processes.
+type redisStore struct{}
+
+func (redisStore) load(host string) (state, error) {
+	values, err := cache.GetHash(keyPrefix + host)
+	if err != nil {
+		return state{}, err
+	}
+	var st state
+	st.failures, _ = strconv.ParseInt(values[failuresField], 10, 64)
+	st.trips, _ = strconv.ParseInt(values[tripsField], 10, 64)
+	if ms, _ := strconv.ParseInt(values[openUntilField], 10, 64); ms > 0 {
+		st.openUntil = time.UnixMilli(ms)
+	}
+	return st, nil
+}
+
+func (redisStore) failure(host string) (int64, error) {
+	failures, err := cache.IncrHashField(keyPrefix+host, failuresField, 1)
+	if err != nil {
+		return 0, err
+	}
+	if failures == 1 {
+		// Occasional failures are forgotten
+		err = cache.Expire(keyPrefix+host, time.Hour)
+	}
+	return failures, err
+}
+
+func (redisStore) trip(host string, until time.Time, ttl time.Duration) error {
+	key := keyPrefix + host
+	if err := cache.SetHashField(key, openUntilField, until.UnixMilli()); err != nil {
+		return err
+	}
+	if err := cache.SetHashField(key, failuresField, 0); err != nil {
+		return err
+	}
+	if _, err := cache.IncrHashField(key, tripsField, 1); err != nil {
+		return err
+	}
+	return cache.Expire(key, ttl)
+}
+
+func (redisStore) reset(host string) error {
+	return cache.DeleteCache(keyPrefix + host)
+}
+
+func (redisStore) probe(host string, openUntil time.Time, lease time.Duration) (bool, error) {
+	count, err := cache.Incr(fmt.Sprintf("%s%s:probe:%d", keyPrefix, host, openUntil.UnixMilli()), lease)
+	return count == 1, err
+}
+
+// memoryStore keeps circuits in the process, when there is no cache.
+type memoryStore struct {
+	mu       sync.Mutex
+	circuits map[string]*state
+	probes   map[string]heldProbe
+}
+
+// heldProbe is the lease of the request probing a circuit that was open until openUntil
+type heldProbe struct {
+	openUntil time.Time
+	until     time.Time
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{circuits: make(map[string]*state), probes: make(map[string]heldProbe)}
+}
+
+func (m *memoryStore) load(host string) (state, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if st, ok := m.circuits[host]; ok {
+		return *st, nil
+	}
+	return state{}, nil
+}
+
+func (m *memoryStore) failure(host string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st, ok := m.circuits[host]
+	if !ok {
+		st = &state{}
+		m.circuits[host] = st
+	}
+	st.failures++
+	return st.failures, nil
+}
+
+func (m *memoryStore) trip(host string, until time.Time, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st, ok := m.circuits[host]
+	if !ok {
+		st = &state{}
+		m.circuits[host] = st
+	}
+	st.failures = 0
+	st.openUntil = until
+	st.trips++
+	return nil
+}
+
+func (m *memoryStore) reset(host string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.circuits, host)
+	delete(m.probes, host)
+	return nil
+}
+
+func (m *memoryStore) probe(host string, openUntil time.Time, lease time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if held, ok := m.probes[host]; ok && held.openUntil.Equal(openUntil) && time.Now().Before(held.until) {
+		return false, nil
+	}
+	m.probes[host] = heldProbe{openUntil: openUntil, until: time.Now().Add(lease)}
+	return true, nil
+}
@@ -0,0 +1,40 @@
+package breaker
+
+import (
+	"net/http"
+)
+
+// Transport fails requests to hosts whose circuit is open with a HostUnavailableError
+// and records the outcome of the others.
+type Transport struct {
+	base    http.RoundTripper
+	breaker *Breaker
+}
+
+// NewTransport wraps base with the shared breaker.
+func NewTransport(base http.RoundTripper) *Transport {
+	return &Transport{base: base, breaker: Get()}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	done, err := t.breaker.Allow(req.URL.Hostname())
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	switch {
+	case err != nil:
+		if IsFailure(err) {
+			done(false)
+		}
+	case IsFailureStatus(resp.StatusCode):
+		done(false)
+	default:
+		done(true)
+	}
+	return resp, err
+}
@@ -31,6 +31,11 @@ func Initialize(cfg *config.AppConfig) error {
 	return nil
 }
 
+// Available reports whether Initialize connected the cache
+func Available() bool {
+	return client != nil
+}
+
 // SetCache sets a value in Redis cache with an expiration time
 func SetCache(key string, value interface{}, expiration time.Duration) error {
 	err := client.Set(ctx, key, value, expiration).Err()
@@ -60,3 +65,58 @@ func Publish(key string, data []byte) error {
 func Subscribe(key string) *redis.PubSub {
 	return client.Subscribe(ctx, key)
 }
+
+// SetHashField sets a field of a Redis hash
+func SetHashField(key string, field string, value interface{}) error {
+	return client.HSet(ctx, key, field, value).Err()
+}
+
+// GetHashField retrieves a field of a Redis hash
+func GetHashField(key string, field string) (string, error) {
+	return client.HGet(ctx, key, field).Result()
+}
+
+// GetHash retrieves all fields of a Redis hash
+func GetHash(key string) (map[string]string, error) {
+	return client.HGetAll(ctx, key).Result()
+}
+
+func DeleteHashField(key string, field string) error {
+	return client.HDel(ctx, key, field).Err()
+}
+
+// IncrHashFields increments several fields of a Redis hash by the given amount
+func IncrHashFields(key string, fields []string, by int64) error {
+	pipe := client.Pipeline()
+	for _, field := range fields {
+		pipe.HIncrBy(ctx, key, field, by)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// IncrHashField increments a field of a Redis hash and returns its new value
+func IncrHashField(key string, field string, by int64) (int64, error) {
+	return client.HIncrBy(ctx, key, field, by).Result()
+}
+
+// Expire sets a key to expire after expiration
+func Expire(key string, expiration time.Duration) error {
+	return client.Expire(ctx, key, expiration).Err()
+}
+
+// Incr increments a counter and sets it to expire after expiration
+func Incr(key string, expiration time.Duration) (int64, error) {
+	pipe := client.Pipeline()
+	incr := pipe.Incr(ctx, key)
+	pipe.Expire(ctx, key, expiration)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+// GetHashFields retrieves several fields of a Redis hash, missing fields are nil
+func GetHashFields(key string, fields ...string) ([]interface{}, error) {
+	return client.HMGet(ctx, key, fields...).Result()
+}
@@ -8,6 +8,19 @@ type AppConfig struct {
 	Log      LogConfig      `mapstructure:"log" json:"log" yaml:"log"`
 	Auth     AuthConfig     `mapstructure:"auth" json:"auth" yaml:"auth"`
 	Proxy    ProxyConfig    `mapstructure:"proxy" json:"proxy" yaml:"proxy"`
+	Sitemap  SitemapConfig  `mapstructure:"sitemap" json:"sitemap" yaml:"sitemap"`
+	Summary  SummaryConfig  `mapstructure:"summary" json:"summary" yaml:"summary"`
+	Topics   TopicsConfig   `mapstructure:"topics" json:"topics" yaml:"topics"`
+	Images   ImagesConfig   `mapstructure:"images" json:"images" yaml:"images"`
+
+	Placeholders PlaceholdersConfig `mapstructure:"placeholders" json:"placeholders" yaml:"placeholders"`
+	Access       AccessConfig       `mapstructure:"access" json:"access" yaml:"access"`
+	AMP          AMPConfig          `mapstructure:"amp" json:"amp" yaml:"amp"`
+	Robots       RobotsConfig       `mapstructure:"robots" json:"robots" yaml:"robots"`
+	Scheduler    SchedulerConfig    `mapstructure:"scheduler" json:"scheduler" yaml:"scheduler"`
+	Breaker      BreakerConfig      `mapstructure:"breaker" json:"breaker" yaml:"breaker"`
+	Fetch        FetchConfig        `mapstructure:"fetch" json:"fetch" yaml:"fetch"`
+	SSRF         SSRFConfig         `mapstructure:"ssrf" json:"ssrf" yaml:"ssrf"`
 }
 
 type ServiceConfig struct {
@@ -47,10 +60,201 @@ type AuthConfig struct {
 }
 
 type ProxyConfig struct {
-	Proxies []Proxy `mapstructure:"proxies" json:"proxies" yaml:"proxies"`
+	Proxies []Proxy           `mapstructure:"proxies" json:"proxies" yaml:"proxies"`
+	Health  ProxyHealthConfig `mapstructure:"health" json:"health" yaml:"health"`
+	// Strategy picks among the free proxies: weighted (default), round_robin,
+	// least_recent, least_loaded, least_latency or sticky
+	Strategy string `mapstructure:"strategy" json:"strategy" yaml:"strategy"`
+	// StickyTTL is how long, in seconds, the sticky strategy keeps a host on a proxy
+	StickyTTL int           `mapstructure:"sticky_ttl" json:"sticky_ttl" yaml:"sticky_ttl"`
+	Domains   []ProxyDomain `mapstructure:"domains" json:"domains" yaml:"domains"`
+	// Direct is when requests may go out without a proxy: no_proxies (default) when
+	// none are configured, overflow also when all are busy, or never
+	Direct string `mapstructure:"direct" json:"direct" yaml:"direct"`
+	// AcquireTimeout is how long, in seconds, a request waits for a free proxy
+	AcquireTimeout int `mapstructure:"acquire_timeout" json:"acquire_timeout" yaml:"acquire_timeout"`
+}
+
+// ProxyDomain sets how proxies are picked for a domain and its subdomains.
+type ProxyDomain struct {
+	Domain   string `mapstructure:"domain" json:"domain" yaml:"domain"`
+	Strategy string `mapstructure:"strategy" json:"strategy" yaml:"strategy"`
+	// Labels the proxies must carry, such as a country or a group
+	Labels map[string]string `mapstructure:"labels" json:"labels" yaml:"labels"`
 }
 
 type Proxy struct {
 	ID  int    `mapstructure:"id" json:"id" yaml:"id"`
 	URL string `mapstructure:"url" json:"url" yaml:"url"`
+	// Type is http, https or socks5, the scheme of URL by default
+	Type string `mapstructure:"type" json:"type" yaml:"type"`
+	// Username and Password can also be read from an environment variable or a file,
+	// which take precedence
+	Username     string `mapstructure:"username" json:"username" yaml:"username"`
+	UsernameEnv  string `mapstructure:"username_env" json:"username_env" yaml:"username_env"`
+	UsernameFile string `mapstructure:"username_file" json:"username_file" yaml:"username_file"`
+	Password     string `mapstructure:"password" json:"password" yaml:"password"`
+	PasswordEnv  string `mapstructure:"password_env" json:"password_env" yaml:"password_env"`
+	PasswordFile string `mapstructure:"password_file" json:"password_file" yaml:"password_file"`
+	// SessionTTL is how long, in seconds, the {session} placeholder of the username
+	// keeps its value
+	SessionTTL int `mapstructure:"session_ttl" json:"session_ttl" yaml:"session_ttl"`
+	// MaxConcurrency is the number of requests the proxy serves at once, 1 by default
+	MaxConcurrency int `mapstructure:"max_concurrency" json:"max_concurrency" yaml:"max_concurrency"`
+	// Labels describe the proxy, such as its country or provider, and fill the
+	// placeholders of the username named after them
+	Labels map[string]string `mapstructure:"labels" json:"labels" yaml:"labels"`
+	// ExitIP is the address the proxy is expected to reach sites from
+	ExitIP string `mapstructure:"exit_ip" json:"exit_ip" yaml:"exit_ip"`
+}
+
+type ProxyHealthConfig struct {
+	// CheckURL answers with the caller's IP address, as text or as JSON with an ip or
+	// origin field. Proxies are only probed when it is set
+	CheckURL string `mapstructure:"check_url" json:"check_url" yaml:"check_url"`
+	// Interval, Timeout, Cooldown and MaxCooldown are in seconds
+	Interval         int `mapstructure:"interval" json:"interval" yaml:"interval"`
+	Timeout          int `mapstructure:"timeout" json:"timeout" yaml:"timeout"`
+	FailureThreshold int `mapstructure:"failure_threshold" json:"failure_threshold" yaml:"failure_threshold"`
+	Cooldown         int `mapstructure:"cooldown" json:"cooldown" yaml:"cooldown"`
+	MaxCooldown      int `mapstructure:"max_cooldown" json:"max_cooldown" yaml:"max_cooldown"`
+}
+
+type SitemapConfig struct {
+	MaxDepth    int `mapstructure:"max_depth" json:"max_depth" yaml:"max_depth"`
+	MaxEntries  int `mapstructure:"max_entries" json:"max_entries" yaml:"max_entries"`
+	MaxChildren int `mapstructure:"max_children" json:"max_children" yaml:"max_children"`
+	MaxSitemaps int `mapstructure:"max_sitemaps" json:"max_sitemaps" yaml:"max_sitemaps"`
+}
+
+type SummaryConfig struct {
+	Enabled      bool `mapstructure:"enabled" json:"enabled" yaml:"enabled"`
+	MaxSentences int  `mapstructure:"max_sentences" json:"max_sentences" yaml:"max_sentences"`
+	MaxChars     int  `mapstructure:"max_chars" json:"max_chars" yaml:"max_chars"`
+}
+
+type TopicsConfig struct {
+	Enabled      bool   `mapstructure:"enabled" json:"enabled" yaml:"enabled"`
+	TaxonomyFile string `mapstructure:"taxonomy_file" json:"taxonomy_file" yaml:"taxonomy_file"`
+	MaxKeywords  int    `mapstructure:"max_keywords" json:"max_keywords" yaml:"max_keywords"`
+	MaxTopics    int    `mapstructure:"max_topics" json:"max_topics" yaml:"max_topics"`
+}
+
+type ImagesConfig struct {
+	SigningKey   string `mapstructure:"signing_key" json:"signing_key" yaml:"signing_key"`
+	MaxDimension int    `mapstructure:"max_dimension" json:"max_dimension" yaml:"max_dimension"`
+	MaxPixels    int    `mapstructure:"max_pixels" json:"max_pixels" yaml:"max_pixels"`
+}
+
+type PlaceholdersConfig struct {
+	PlaceholderPolicy `mapstructure:",squash" yaml:",inline"`
+	Tenants           []PlaceholderTenant `mapstructure:"tenants" json:"tenants" yaml:"tenants"`
+}
+
+type PlaceholderTenant struct {
+	Name              string   `mapstructure:"name" json:"name" yaml:"name"`
+	APIKeys           []string `mapstructure:"api_keys" json:"api_keys" yaml:"api_keys"`
+	PlaceholderPolicy `mapstructure:",squash" yaml:",inline"`
+}
+
+type PlaceholderPolicy struct {
+	Mode        string `mapstructure:"mode" json:"mode" yaml:"mode"`
+	ItemImage   string `mapstructure:"item_image" json:"item_image" yaml:"item_image"`
+	SourceImage string `mapstructure:"source_image" json:"source_image" yaml:"source_image"`
+	SourceIcon  string `mapstructure:"source_icon" json:"source_icon" yaml:"source_icon"`
+}
+
+type AccessConfig struct {
+	Enabled           bool                `mapstructure:"enabled" json:"enabled" yaml:"enabled"`
+	TruncationRatio   float64             `mapstructure:"truncation_ratio" json:"truncation_ratio" yaml:"truncation_ratio"`
+	MinFeedLength     int                 `mapstructure:"min_feed_length" json:"min_feed_length" yaml:"min_feed_length"`
+	LoginURLPattern   string              `mapstructure:"login_url_pattern" json:"login_url_pattern" yaml:"login_url_pattern"`
+	PaywallURLPattern string              `mapstructure:"paywall_url_pattern" json:"paywall_url_pattern" yaml:"paywall_url_pattern"`
+	Fingerprints      []AccessFingerprint `mapstructure:"fingerprints" json:"fingerprints" yaml:"fingerprints"`
+	Domains           []AccessDomain      `mapstructure:"domains" json:"domains" yaml:"domains"`
+}
+
+// AccessDomain fixes the access of a domain. Domains are listed rather than mapped
+// because configuration keys cannot contain dots.
+type AccessDomain struct {
+	Domain string `mapstructure:"domain" json:"domain" yaml:"domain"`
+	Access string `mapstructure:"access" json:"access" yaml:"access"`
+}
+
+type AccessFingerprint struct {
+	Name    string `mapstructure:"name" json:"name" yaml:"name"`
+	Pattern string `mapstructure:"pattern" json:"pattern" yaml:"pattern"`
+	Access  string `mapstructure:"access" json:"access" yaml:"access"`
+}
+
+type AMPConfig struct {
+	MaxPageBytes        int64    `mapstructure:"max_page_bytes" json:"max_page_bytes" yaml:"max_page_bytes"`
+	FetchOnBlocked      bool     `mapstructure:"fetch_on_blocked" json:"fetch_on_blocked" yaml:"fetch_on_blocked"`
+	FallbackURLPatterns []string `mapstructure:"fallback_url_patterns" json:"fallback_url_patterns" yaml:"fallback_url_patterns"`
+}
+
+type RobotsConfig struct {
+	Mode  string `mapstructure:"mode" json:"mode" yaml:"mode"`
+	Agent string `mapstructure:"agent" json:"agent" yaml:"agent"`
+	// MaxCrawlDelay caps the Crawl-delay of sites, in seconds
+	MaxCrawlDelay int              `mapstructure:"max_crawl_delay" json:"max_crawl_delay" yaml:"max_crawl_delay"`
+	Overrides     []RobotsOverride `mapstructure:"overrides" json:"overrides" yaml:"overrides"`
+}
+
+type RobotsOverride struct {
+	Domain string `mapstructure:"domain" json:"domain" yaml:"domain"`
+	Mode   string `mapstructure:"mode" json:"mode" yaml:"mode"`
+}
+
+type SchedulerConfig struct {
+	MaxInFlight int `mapstructure:"max_in_flight" json:"max_in_flight" yaml:"max_in_flight"`
+	// HostRate is in requests per second
+	HostRate        float64 `mapstructure:"host_rate" json:"host_rate" yaml:"host_rate"`
+	HostBurst       int     `mapstructure:"host_burst" json:"host_burst" yaml:"host_burst"`
+	HostConcurrency int     `mapstructure:"host_concurrency" json:"host_concurrency" yaml:"host_concurrency"`
+	// Shared coordinates host rates between instances through the cache
+	Shared bool                `mapstructure:"shared" json:"shared" yaml:"shared"`
+	Hosts  []SchedulerOverride `mapstructure:"hosts" json:"hosts" yaml:"hosts"`
+}
+
+type SchedulerOverride struct {
+	Host        string  `mapstructure:"host" json:"host" yaml:"host"`
+	Rate        float64 `mapstructure:"rate" json:"rate" yaml:"rate"`
+	Burst       int     `mapstructure:"burst" json:"burst" yaml:"burst"`
+	Concurrency int     `mapstructure:"concurrency" json:"concurrency" yaml:"concurrency"`
+}
+
+type BreakerConfig struct {
+	FailureThreshold int `mapstructure:"failure_threshold" json:"failure_threshold" yaml:"failure_threshold"`
+	// Cooldown and MaxCooldown are in seconds
+	Cooldown    int `mapstructure:"cooldown" json:"cooldown" yaml:"cooldown"`
+	MaxCooldown int `mapstructure:"max_cooldown" json:"max_cooldown" yaml:"max_cooldown"`
+}
+
+// FetchConfig holds the retry policies and body limits of the outbound requests by
+// what they fetch.
+type FetchConfig struct {
+	Feed  FetchPolicy `mapstructure:"feed" json:"feed" yaml:"feed"`
+	Page  FetchPolicy `mapstructure:"page" json:"page" yaml:"page"`
+	Image FetchPolicy `mapstructure:"image" json:"image" yaml:"image"`
+}
+
+type FetchPolicy struct {
+	// MaxBytes limits the decompressed body, longer bodies are truncated
+	MaxBytes    int64 `mapstructure:"max_bytes" json:"max_bytes" yaml:"max_bytes"`
+	MaxAttempts int   `mapstructure:"max_attempts" json:"max_attempts" yaml:"max_attempts"`
+	// BaseDelay and MaxDelay are in seconds
+	BaseDelay     float64 `mapstructure:"base_delay" json:"base_delay" yaml:"base_delay"`
+	MaxDelay      float64 `mapstructure:"max_delay" json:"max_delay" yaml:"max_delay"`
+	RetryStatuses []int   `mapstructure:"retry_statuses" json:"retry_statuses" yaml:"retry_statuses"`
+	RetryErrors   *bool   `mapstructure:"retry_errors" json:"retry_errors" yaml:"retry_errors"`
+}
+
+// SSRFConfig adjusts which destinations outbound requests may reach. Internal
+// addresses are blocked unless allowed, denied ones are blocked even when public.
+type SSRFConfig struct {
+	AllowCIDRs []string `mapstructure:"allow_cidrs" json:"allow_cidrs" yaml:"allow_cidrs"`
+	DenyCIDRs  []string `mapstructure:"deny_cidrs" json:"deny_cidrs" yaml:"deny_cidrs"`
+	Schemes    []string `mapstructure:"schemes" json:"schemes" yaml:"schemes"`
+	Ports      []int    `mapstructure:"ports" json:"ports" yaml:"ports"`
 }
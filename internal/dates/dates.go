@@ -0,0 +1,234 @@
+package dates
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Layouts tried, in order, after the input has been normalized to English month names.
+var layouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC822Z,
+	time.RFC822,
+	time.RFC850,
+	time.ANSIC,
+	time.UnixDate,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"Mon, 2 Jan 2006 15:04 -0700",
+	"Mon, 2 Jan 2006 15:04 MST",
+	"Mon, 2 Jan 2006 15:04:05",
+	"Mon, 2 Jan 2006",
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05",
+	"2 Jan 2006 15:04",
+	"2 Jan 2006",
+	"2 January 2006 15:04",
+	"2 January 2006",
+	"Jan 2, 2006 15:04:05",
+	"Jan 2, 2006 3:04 PM",
+	"Jan 2, 2006",
+	"January 2, 2006 15:04",
+	"January 2, 2006 3:04 PM",
+	"January 2, 2006",
+	"2006-01-02T15:04:05-0700",
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02 15:04:05",
+	"2006/01/02 15:04",
+	"2006/01/02",
+	"02.01.2006 15:04:05",
+	"02.01.2006 15:04",
+	"02.01.2006",
+	"2.1.2006",
+}
+
+// Numeric dates with dashes are written day first in Europe and month first in the
+// US. They are only read when one of the first two fields is over 12, so that the
+// order is certain.
+var (
+	dayFirstLayouts   = []string{"02-01-2006 15:04", "02-01-2006"}
+	monthFirstLayouts = []string{"01-02-2006 15:04", "01-02-2006"}
+)
+
+// Localized month names (full and abbreviated) mapped to the English abbreviation
+// understood by the layouts above.
+var monthNames = map[string]string{
+	// German
+	"januar": "Jan", "jänner": "Jan", "februar": "Feb", "märz": "Mar", "maerz": "Mar", "mär": "Mar",
+	"april": "Apr", "mai": "May", "juni": "Jun", "juli": "Jul", "august": "Aug",
+	"september": "Sep", "oktober": "Oct", "okt": "Oct", "november": "Nov", "dezember": "Dec", "dez": "Dec",
+	// French
+	"janvier": "Jan", "janv": "Jan", "février": "Feb", "fevrier": "Feb", "févr": "Feb", "fevr": "Feb",
+	"mars": "Mar", "avril": "Apr", "avr": "Apr", "juin": "Jun", "juillet": "Jul", "juil": "Jul",
+	"août": "Aug", "aout": "Aug", "septembre": "Sep", "sept": "Sep", "octobre": "Oct",
+	"novembre": "Nov", "décembre": "Dec", "decembre": "Dec", "déc": "Dec",
+	// Spanish
+	"enero": "Jan", "ene": "Jan", "febrero": "Feb", "marzo": "Mar", "abril": "Apr", "abr": "Apr",
+	"mayo": "May", "junio": "Jun", "julio": "Jul", "agosto": "Aug", "ago": "Aug",
+	"septiembre": "Sep", "setiembre": "Sep", "octubre": "Oct", "noviembre": "Nov",
+	"diciembre": "Dec", "dic": "Dec",
+	// Italian
+	"gennaio": "Jan", "gen": "Jan", "febbraio": "Feb", "aprile": "Apr", "maggio": "May", "mag": "May",
+	"giugno": "Jun", "giu": "Jun", "luglio": "Jul", "lug": "Jul", "settembre": "Sep", "set": "Sep",
+	"ottobre": "Oct", "ott": "Oct", "dicembre": "Dec",
+	// Portuguese
+	"janeiro": "Jan", "fevereiro": "Feb", "fev": "Feb", "março": "Mar", "marco": "Mar",
+	"maio": "May", "junho": "Jun", "julho": "Jul", "setembro": "Sep", "outubro": "Oct", "out": "Oct",
+	"novembro": "Nov", "dezembro": "Dec",
+	// Dutch
+	"januari": "Jan", "februari": "Feb", "maart": "Mar", "mrt": "Mar", "mei": "May",
+	"augustus": "Aug",
+	// Turkish
+	"ocak": "Jan", "şubat": "Feb", "subat": "Feb", "mart": "Mar", "nisan": "Apr", "mayıs": "May",
+	"mayis": "May", "haziran": "Jun", "temmuz": "Jul", "ağustos": "Aug", "agustos": "Aug",
+	"eylül": "Sep", "eylul": "Sep", "ekim": "Oct", "kasım": "Nov", "kasim": "Nov", "aralık": "Dec", "aralik": "Dec",
+}
+
+// Localized weekday names are dropped before parsing; the weekday is redundant with the date.
+var weekdayNames = map[string]bool{
+	"montag": true, "dienstag": true, "mittwoch": true, "donnerstag": true, "freitag": true, "samstag": true, "sonntag": true,
+	"lundi": true, "mardi": true, "mercredi": true, "jeudi": true, "vendredi": true, "samedi": true, "dimanche": true,
+	"lunes": true, "martes": true, "miércoles": true, "miercoles": true, "jueves": true, "viernes": true, "sábado": true, "sabado": true, "domingo": true,
+	"lunedì": true, "martedì": true, "mercoledì": true, "giovedì": true, "venerdì": true,
+	"segunda-feira": true, "terça-feira": true, "quarta-feira": true, "quinta-feira": true, "sexta-feira": true,
+	"maandag": true, "dinsdag": true, "woensdag": true, "donderdag": true, "vrijdag": true, "zaterdag": true, "zondag": true,
+	"pazartesi": true, "salı": true, "çarşamba": true, "perşembe": true, "cuma": true, "cumartesi": true, "pazar": true,
+	"mon": true, "tue": true, "wed": true, "thu": true, "fri": true, "sat": true, "sun": true,
+	"monday": true, "tuesday": true, "wednesday": true, "thursday": true, "friday": true, "saturday": true, "sunday": true,
+}
+
+var (
+	wordRegex       = regexp.MustCompile(`\p{L}[\p{L}\-]*\.?`)
+	ordinalRegex    = regexp.MustCompile(`(\d+)(st|nd|rd|th)\b`)
+	spaceRegex      = regexp.MustCompile(`\s+`)
+	unixStampRegex  = regexp.MustCompile(`^\d{10}(\d{3})?$`)
+	fillerWordRegex = regexp.MustCompile(`(?i)\b(de|del|di|den|um|a las|at|le)\b`)
+	dashedDateRegex = regexp.MustCompile(`^(\d{2})-(\d{2})-\d{4}\b`)
+)
+
+// Parse attempts to parse a date string found in a feed or on a page. It understands
+// the usual RFC formats, a range of common numeric layouts, unix timestamps and month
+// names in several European languages.
+func Parse(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+
+	if unixStampRegex.MatchString(value) {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err == nil {
+			if len(value) == 13 {
+				return time.UnixMilli(n).UTC(), true
+			}
+			return time.Unix(n, 0).UTC(), true
+		}
+	}
+
+	if t, ok := parseLayouts(value); ok {
+		return t, true
+	}
+
+	normalized := normalize(value)
+	if normalized != value {
+		if t, ok := parseLayouts(normalized); ok {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// Clamp returns fallback when t lies further in the future than tolerance allows.
+func Clamp(t time.Time, fallback time.Time, tolerance time.Duration) time.Time {
+	if t.After(time.Now().Add(tolerance)) {
+		return fallback
+	}
+	return t
+}
+
+func parseLayouts(value string) (time.Time, bool) {
+	for _, layout := range slices.Concat(layouts, dashedLayouts(value)) {
+		t, err := time.Parse(layout, value)
+		if err == nil && t.Year() > 1970 {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// dashedLayouts returns the layouts of value when it is a numeric date with dashes
+// whose field order can be told, none otherwise.
+func dashedLayouts(value string) []string {
+	m := dashedDateRegex.FindStringSubmatch(value)
+	if m == nil {
+		return nil
+	}
+	first, _ := strconv.Atoi(m[1])
+	second, _ := strconv.Atoi(m[2])
+	switch {
+	case first > 12 && second <= 12:
+		return dayFirstLayouts
+	case second > 12 && first <= 12:
+		return monthFirstLayouts
+	default:
+		return nil
+	}
+}
+
+// normalize rewrites localized month names to English, drops weekday names, ordinal
+// suffixes and filler words so the value can be matched against the English layouts.
+func normalize(value string) string {
+	value = ordinalRegex.ReplaceAllString(value, "$1")
+	value = fillerWordRegex.ReplaceAllString(value, " ")
+
+	value = wordRegex.ReplaceAllStringFunc(value, func(word string) string {
+		key := strings.ToLower(strings.TrimSuffix(word, "."))
+		if weekdayNames[key] {
+			return ""
+		}
+		if month, ok := monthNames[key]; ok {
+			return month
+		}
+		if len(key) >= 3 {
+			if month, ok := englishMonth(key); ok {
+				return month
+			}
+		}
+		return word
+	})
+
+	value = strings.ReplaceAll(value, " ,", ",")
+	value = strings.Trim(value, " ,")
+	value = spaceRegex.ReplaceAllString(value, " ")
+
+	// "2 Jan. 2006" and "2. Jan 2006" style separators
+	value = strings.ReplaceAll(value, ". ", " ")
+	return strings.TrimSpace(value)
+}
+
+func englishMonth(key string) (string, bool) {
+	months := []string{"january", "february", "march", "april", "may", "june", "july", "august", "september", "october", "november", "december"}
+	for _, m := range months {
+		if strings.HasPrefix(m, key) {
+			return strings.ToUpper(m[:1]) + m[1:3], true
+		}
+	}
+	return "", false
+}
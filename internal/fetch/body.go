@@ -0,0 +1,210 @@
+package fetch
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// acceptEncoding lists the content codings the body can be decoded from
+const acceptEncoding = "gzip, deflate, br, zstd"
+
+const (
+	// maxCompressionRatio is the most a body may grow when decoded, once it is larger
+	// than bombThreshold
+	maxCompressionRatio = 100
+	bombThreshold       = 1024 * 1024
+	// sniffBytes is how much of the body content type sniffing looks at
+	sniffBytes = 512
+)
+
+// ErrDecompressionBomb is returned when a body grows far more than real content does
+// when it is decoded.
+var ErrDecompressionBomb = errors.New("response body expands too much when decompressed")
+
+// ContentTypeError is returned when a request expecting HTML gets something else.
+type ContentTypeError struct {
+	URL         string
+	ContentType string
+}
+
+func (e *ContentTypeError) Error() string {
+	return fmt.Sprintf("unexpected content type %s: %s", e.ContentType, e.URL)
+}
+
+// decodeBody undoes the content codings of a response, in the reverse order they were
+// applied. Decoders are created on the first read, so empty bodies need no header.
+func decodeBody(body io.Reader, contentEncoding string) (io.Reader, bool, error) {
+	var codings []string
+	for _, coding := range strings.Split(contentEncoding, ",") {
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "" && coding != "identity" {
+			codings = append(codings, coding)
+		}
+	}
+	if len(codings) == 0 {
+		return body, false, nil
+	}
+
+	guard := &bombGuard{compressed: &countingReader{r: body}}
+	reader := io.Reader(guard.compressed)
+	for i := len(codings) - 1; i >= 0; i-- {
+		switch codings[i] {
+		case "gzip", "x-gzip", "deflate", "br", "zstd":
+			decoder := &lazyDecoder{r: reader, coding: codings[i]}
+			guard.decoders = append(guard.decoders, decoder)
+			reader = decoder
+		default:
+			return nil, false, fmt.Errorf("unsupported content encoding %q", codings[i])
+		}
+	}
+	guard.r = reader
+	return guard, true, nil
+}
+
+type lazyDecoder struct {
+	r       io.Reader
+	coding  string
+	decoded io.Reader
+}
+
+func (d *lazyDecoder) Read(p []byte) (int, error) {
+	if d.decoded == nil {
+		decoded, err := newDecoder(d.r, d.coding)
+		if err != nil {
+			return 0, err
+		}
+		d.decoded = decoded
+	}
+	return d.decoded.Read(p)
+}
+
+func (d *lazyDecoder) Close() error {
+	if closer, ok := d.decoded.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func newDecoder(r io.Reader, coding string) (io.Reader, error) {
+	switch coding {
+	case "gzip", "x-gzip":
+		return gzip.NewReader(r)
+	case "deflate":
+		// Meant to be zlib, but some servers send raw deflate
+		br := bufio.NewReader(r)
+		header, _ := br.Peek(2)
+		if len(header) == 2 && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+			return zlib.NewReader(br)
+		}
+		return flate.NewReader(br), nil
+	case "br":
+		return brotli.NewReader(r), nil
+	case "zstd":
+		decoder, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxWindow(64<<20))
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	}
+	return nil, fmt.Errorf("unsupported content encoding %q", coding)
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// bombGuard fails once the decoded body outgrows the compressed one by more than
+// maxCompressionRatio. Closing it releases the decoders.
+type bombGuard struct {
+	r          io.Reader
+	compressed *countingReader
+	decoders   []*lazyDecoder
+	decoded    int64
+}
+
+func (g *bombGuard) Read(p []byte) (int, error) {
+	n, err := g.r.Read(p)
+	g.decoded += int64(n)
+	if g.decoded > bombThreshold && g.decoded > maxCompressionRatio*max(g.compressed.n, 1) {
+		return n, ErrDecompressionBomb
+	}
+	return n, err
+}
+
+func (g *bombGuard) Close() error {
+	for _, d := range g.decoders {
+		d.Close()
+	}
+	return nil
+}
+
+// limitedReader ends the body after max bytes and records whether there was more.
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+	truncated bool
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.remaining <= 0 {
+		if !l.truncated {
+			var probe [1]byte
+			n, _ := io.ReadFull(l.r, probe[:])
+			l.truncated = n > 0
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > l.remaining {
+		p = p[:l.remaining]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	return n, err
+}
+
+// checkHTML sniffs the beginning of body and fails unless it is HTML. The declared
+// content type is trusted unless the content says otherwise, generic types are decided
+// by the content alone.
+func checkHTML(body *bufio.Reader, contentType, pageURL string) error {
+	head, _ := body.Peek(sniffBytes)
+	sniffed := http.DetectContentType(head)
+	declared, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		declared = ""
+	}
+
+	switch declared {
+	case "text/html", "application/xhtml+xml":
+		if len(head) == 0 || strings.HasPrefix(sniffed, "text/") {
+			return nil
+		}
+	case "", "text/plain", "application/octet-stream", "binary/octet-stream":
+		if strings.HasPrefix(sniffed, "text/html") {
+			return nil
+		}
+	}
+
+	reported := declared
+	if reported == "" || !strings.HasPrefix(sniffed, "text/") {
+		reported, _, _ = mime.ParseMediaType(sniffed)
+	}
+	return &ContentTypeError{URL: pageURL, ContentType: reported}
+}
@@ -0,0 +1,333 @@
+package fetch
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lufeed/feed-parser-api/internal/breaker"
+	"github.com/lufeed/feed-parser-api/internal/browser"
+	"github.com/lufeed/feed-parser-api/internal/logger"
+	"github.com/lufeed/feed-parser-api/internal/proxy"
+	"github.com/lufeed/feed-parser-api/internal/robots"
+	"go.uber.org/zap"
+)
+
+// NoRedirects as Request.MaxRedirects returns redirect responses as they are.
+const NoRedirects = -1
+
+// Client sends outbound requests with browser headers, retries them according to
+// their policy and traces every attempt.
+type Client struct {
+	cl      *http.Client
+	proxies *proxy.Manager
+}
+
+// New returns a client taking a proxy of pm for every attempt, so that retries go out
+// through another proxy. The proxy is released when the response body is closed.
+func New(pm *proxy.Manager) *Client {
+	return &Client{proxies: pm}
+}
+
+// With returns a client sending every attempt through cl, whose proxy the caller holds.
+func With(cl *http.Client) *Client {
+	return &Client{cl: cl}
+}
+
+// Request describes an outbound request.
+type Request struct {
+	URL string
+	// Method defaults to GET
+	Method string
+	Kind   Kind
+	// Header is set over the browser headers
+	Header http.Header
+	// Policy replaces the retry policy of Kind when its MaxAttempts is set
+	Policy Policy
+	// MaxBytes replaces the body limit of Kind when set. Longer bodies are truncated
+	MaxBytes int64
+	// MaxRedirects defaults to the limit of the HTTP client, see NoRedirects
+	MaxRedirects int
+	// RequireHTML fails successful responses whose content is not HTML with a
+	// ContentTypeError
+	RequireHTML bool
+}
+
+// Response is the response to the last attempt of a request. Its body must be closed.
+type Response struct {
+	StatusCode int
+	Status     string
+	Header     http.Header
+	// ContentLength is the declared length of the body as sent, -1 when unknown or
+	// when the body was compressed
+	ContentLength int64
+	// URL is the URL that answered, after redirects
+	URL   string
+	Body  io.ReadCloser
+	Trace Trace
+
+	body *body
+}
+
+// Truncated reports whether the body was cut at the body limit. It is known once the
+// body has been read to its end.
+func (r *Response) Truncated() bool {
+	return r.body.limited.truncated
+}
+
+// Trace records the attempts of a request.
+type Trace struct {
+	ID       string    `json:"id"`
+	URL      string    `json:"url"`
+	Attempts []Attempt `json:"attempts"`
+}
+
+// Attempt is one try of a request. Wait is the time waited before the next one.
+type Attempt struct {
+	ProxyID    int           `json:"proxy_id"`
+	StatusCode int           `json:"status_code,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	Duration   time.Duration `json:"duration"`
+	FirstByte  time.Duration `json:"first_byte"`
+	ConnReused bool          `json:"conn_reused"`
+	Wait       time.Duration `json:"wait,omitempty"`
+}
+
+// Do sends r, retrying it as its policy allows. Responses of any status are returned,
+// with their body decompressed and limited. Errors are those of the last attempt or of
+// ctx.
+func (c *Client) Do(ctx context.Context, r Request) (*Response, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	method := r.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	policy, maxBytes := settings(r.Kind)
+	if r.Policy.MaxAttempts > 0 {
+		policy = r.Policy
+	}
+	if r.MaxBytes > 0 {
+		maxBytes = r.MaxBytes
+	}
+	log := logger.GetSugaredLogger()
+	trace := Trace{ID: uuid.NewString(), URL: r.URL}
+	attempts := policy.attempts()
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, r.URL, nil)
+		if err != nil {
+			return nil, err
+		}
+		// The browser profile changes between attempts
+		for k, v := range browser.GetBrowserHeaders() {
+			req.Header.Set(k, v)
+		}
+		// Set explicitly, the body is decoded here rather than by the transport
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		for k, v := range r.Header {
+			req.Header[http.CanonicalHeaderKey(k)] = v
+		}
+
+		hc, proxyID, err := c.client(ctx, r.URL)
+		if err != nil {
+			return nil, err
+		}
+		a := Attempt{ProxyID: proxyID}
+		start := time.Now()
+		req = req.WithContext(httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+			GotConn:              func(info httptrace.GotConnInfo) { a.ConnReused = info.Reused },
+			GotFirstResponseByte: func() { a.FirstByte = time.Since(start) },
+		}))
+		resp, err := withRedirects(hc, r.MaxRedirects).Do(req)
+		a.Duration = time.Since(start)
+		last := attempt+1 >= attempts
+
+		if err != nil {
+			c.release(proxyID)
+			a.Error = err.Error()
+			var early *robots.TooEarlyError
+			switch {
+			case errors.As(err, &early) && c.proxies != nil && !last &&
+				(policy.MaxDelay <= 0 || time.Until(early.RetryAt) <= policy.MaxDelay):
+				// The crawl delay of the site is waited out without holding a proxy
+				a.Wait = time.Until(early.RetryAt)
+			case last || !policy.RetryErrors || !retryable(ctx, err):
+				trace.Attempts = append(trace.Attempts, a)
+				log.With(zap.String("trace", trace.ID)).Debugf("Fetch of %s failed after %d attempts: %s", r.URL, len(trace.Attempts), err.Error())
+				return nil, err
+			default:
+				a.Wait = policy.backoff(attempt)
+			}
+		} else {
+			a.StatusCode = resp.StatusCode
+			retry := !last && policy.retriesStatus(resp.StatusCode)
+			if retry {
+				a.Wait = policy.backoff(attempt)
+				if after, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+					if policy.MaxDelay > 0 && after > policy.MaxDelay {
+						log.Debugf("Not retrying %s, Retry-After of %v is too long", r.URL, after)
+						retry = false
+					} else {
+						a.Wait = after
+					}
+				}
+			}
+			if !retry {
+				trace.Attempts = append(trace.Attempts, a)
+				log.With(zap.String("trace", trace.ID)).Debugf("Fetched %s with status %d after %d attempts in %v", r.URL, resp.StatusCode, len(trace.Attempts), a.Duration)
+				return c.response(resp, r, maxBytes, proxyID, trace)
+			}
+			resp.Body.Close()
+			c.release(proxyID)
+		}
+
+		trace.Attempts = append(trace.Attempts, a)
+		reason := a.Error
+		if reason == "" {
+			reason = fmt.Sprintf("status %d", a.StatusCode)
+		}
+		log.With(zap.String("trace", trace.ID)).Warnf("Fetch of %s failed (attempt %d/%d), retrying after %v: %s", r.URL, attempt+1, attempts, a.Wait, reason)
+		if err := sleep(ctx, a.Wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// client returns the HTTP client of an attempt to targetURL and the proxy it holds.
+func (c *Client) client(ctx context.Context, targetURL string) (*http.Client, int, error) {
+	if c.proxies == nil {
+		return c.cl, 0, nil
+	}
+	return c.proxies.Acquire(ctx, targetURL)
+}
+
+func (c *Client) release(proxyID int) {
+	if c.proxies != nil {
+		c.proxies.ReleaseProxy(proxyID)
+	}
+}
+
+// response decodes and limits the body of resp and checks its content type.
+func (c *Client) response(resp *http.Response, r Request, maxBytes int64, proxyID int, trace Trace) (*Response, error) {
+	var once sync.Once
+	b := &body{raw: resp.Body, release: func() {
+		once.Do(func() { c.release(proxyID) })
+	}}
+
+	decoded, compressed, err := decodeBody(resp.Body, resp.Header.Get("Content-Encoding"))
+	if err != nil {
+		b.Close()
+		return nil, err
+	}
+	if closer, ok := decoded.(io.Closer); ok {
+		b.decoder = closer
+	}
+	if maxBytes <= 0 {
+		maxBytes = math.MaxInt64
+	}
+	b.limited = &limitedReader{r: decoded, remaining: maxBytes}
+	b.reader = b.limited
+
+	contentLength := resp.ContentLength
+	if compressed {
+		contentLength = -1
+	}
+	finalURL := resp.Request.URL.String()
+
+	if r.RequireHTML && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		buffered := bufio.NewReaderSize(b.limited, sniffBytes)
+		if err := checkHTML(buffered, resp.Header.Get("Content-Type"), finalURL); err != nil {
+			b.Close()
+			return nil, err
+		}
+		b.reader = buffered
+	}
+
+	return &Response{
+		StatusCode:    resp.StatusCode,
+		Status:        resp.Status,
+		Header:        resp.Header,
+		ContentLength: contentLength,
+		URL:           finalURL,
+		Body:          b,
+		Trace:         trace,
+		body:          b,
+	}, nil
+}
+
+// body reads the decoded and limited response body, closing it releases the decoders
+// and the proxy of the request.
+type body struct {
+	raw     io.Closer
+	decoder io.Closer
+	limited *limitedReader
+	reader  io.Reader
+	release func()
+}
+
+func (b *body) Read(p []byte) (int, error) {
+	return b.reader.Read(p)
+}
+
+func (b *body) Close() error {
+	if b.decoder != nil {
+		b.decoder.Close()
+	}
+	err := b.raw.Close()
+	b.release()
+	return err
+}
+
+// withRedirects returns cl following at most maxRedirects redirects.
+func withRedirects(cl *http.Client, maxRedirects int) *http.Client {
+	if maxRedirects == 0 {
+		return cl
+	}
+	limited := *cl
+	limited.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if maxRedirects < 0 {
+			return http.ErrUseLastResponse
+		}
+		if len(via) > maxRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxRedirects)
+		}
+		return nil
+	}
+	return &limited
+}
+
+// retryable reports whether another attempt may get past err: robots.txt rules, open
+// circuits and canceled requests do not change between attempts. Crawl delays are only
+// waited out by clients taking their own proxies.
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || !breaker.IsFailure(err) {
+		return false
+	}
+	var disallowed *robots.DisallowedError
+	var early *robots.TooEarlyError
+	return !errors.As(err, &disallowed) && !errors.As(err, &early)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,174 @@
+package fetch
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lufeed/feed-parser-api/internal/config"
+)
+
+// Policy decides which failures of a request are retried and how long to wait before
+// the next attempt.
+type Policy struct {
+	// MaxAttempts counts the first attempt, 1 disables retries
+	MaxAttempts int
+	// BaseDelay doubles after every attempt, with up to half of it added as jitter
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff. A Retry-After asking for more is not waited for, the
+	// response is returned as it is
+	MaxDelay time.Duration
+	// RetryStatuses are the response statuses worth another attempt
+	RetryStatuses []int
+	// RetryErrors retries connection failures and timeouts
+	RetryErrors bool
+}
+
+// NoRetry makes a single attempt.
+var NoRetry = Policy{MaxAttempts: 1}
+
+// Kind is what a request fetches. It picks the retry policy and the body limit.
+type Kind string
+
+const (
+	KindFeed  Kind = "feed"
+	KindPage  Kind = "page"
+	KindImage Kind = "image"
+)
+
+type kindSettings struct {
+	policy   Policy
+	maxBytes int64
+}
+
+var defaultSettings = map[Kind]kindSettings{
+	KindFeed: {
+		policy: Policy{
+			MaxAttempts:   3,
+			BaseDelay:     2 * time.Second,
+			MaxDelay:      30 * time.Second,
+			RetryStatuses: []int{http.StatusTooManyRequests, http.StatusServiceUnavailable},
+			RetryErrors:   true,
+		},
+		maxBytes: 10 * 1024 * 1024,
+	},
+	KindPage: {
+		policy: Policy{
+			MaxAttempts:   3,
+			BaseDelay:     2 * time.Second,
+			MaxDelay:      30 * time.Second,
+			RetryStatuses: []int{http.StatusTooManyRequests, http.StatusServiceUnavailable},
+			RetryErrors:   true,
+		},
+		maxBytes: 5 * 1024 * 1024,
+	},
+	KindImage: {
+		policy: Policy{
+			MaxAttempts:   2,
+			BaseDelay:     500 * time.Millisecond,
+			MaxDelay:      5 * time.Second,
+			RetryStatuses: []int{http.StatusTooManyRequests, http.StatusServiceUnavailable},
+			RetryErrors:   true,
+		},
+		maxBytes: 10 * 1024 * 1024,
+	},
+}
+
+// settings returns the retry policy and body limit of kind, the defaults overridden
+// by the fetch section of the configuration. Requests of no kind are neither retried
+// nor limited.
+func settings(kind Kind) (Policy, int64) {
+	s, ok := defaultSettings[kind]
+	if !ok {
+		return NoRetry, 0
+	}
+	cfg := config.GetConfig()
+	if cfg == nil {
+		return s.policy, s.maxBytes
+	}
+
+	var fp config.FetchPolicy
+	switch kind {
+	case KindFeed:
+		fp = cfg.Fetch.Feed
+	case KindPage:
+		fp = cfg.Fetch.Page
+	case KindImage:
+		fp = cfg.Fetch.Image
+	}
+	p := s.policy
+	if fp.MaxAttempts > 0 {
+		p.MaxAttempts = fp.MaxAttempts
+	}
+	if fp.BaseDelay > 0 {
+		p.BaseDelay = seconds(fp.BaseDelay)
+	}
+	if fp.MaxDelay > 0 {
+		p.MaxDelay = seconds(fp.MaxDelay)
+	}
+	if len(fp.RetryStatuses) > 0 {
+		p.RetryStatuses = fp.RetryStatuses
+	}
+	if fp.RetryErrors != nil {
+		p.RetryErrors = *fp.RetryErrors
+	}
+	maxBytes := s.maxBytes
+	if fp.MaxBytes > 0 {
+		maxBytes = fp.MaxBytes
+	}
+	return p, maxBytes
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+func (p Policy) attempts() int {
+	return max(p.MaxAttempts, 1)
+}
+
+func (p Policy) retriesStatus(statusCode int) bool {
+	return slices.Contains(p.RetryStatuses, statusCode)
+}
+
+// backoff is the wait before the attempt following attempt, counted from 0.
+func (p Policy) backoff(attempt int) time.Duration {
+	if p.BaseDelay <= 0 {
+		return 0
+	}
+	delay := p.BaseDelay << min(attempt, 16)
+	if half := int64(delay) / 2; half > 0 {
+		delay += time.Duration(rand.Int63n(half))
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay
+}
+
+// parseRetryAfter reads a Retry-After header, given either in seconds or as an HTTP
+// date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	// Out of range values come back clamped
+	if secs, err := strconv.ParseInt(value, 10, 64); err == nil || errors.Is(err, strconv.ErrRange) {
+		if secs < 0 {
+			return 0, false
+		}
+		// Longer than a Duration holds is as good as forever
+		return time.Duration(min(secs, int64(math.MaxInt64/time.Second))) * time.Second, true
+	}
+	at, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	return max(at.Sub(now), 0), true
+}
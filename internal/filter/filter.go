@@ -0,0 +1,256 @@
+package filter
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/lufeed/feed-parser-api/internal/access"
+	"github.com/lufeed/feed-parser-api/internal/models"
+	"github.com/mmcdole/gofeed"
+)
+
+var tagRegex = regexp.MustCompile(`<[^>]*>`)
+
+// Filter is a compiled models.ItemFilter. Items are checked in two steps: Prefilter
+// uses only what the feed itself provides and runs before the item page is fetched,
+// Match runs on the enriched item and decides the conditions Prefilter had to leave
+// open.
+type Filter struct {
+	rules        models.ItemFilter
+	include      []string
+	exclude      []string
+	titleRegex   *regexp.Regexp
+	contentRegex *regexp.Regexp
+}
+
+// Compile validates the filter rules. A nil or empty filter compiles to nil, which
+// accepts every item.
+func Compile(rules *models.ItemFilter) (*Filter, error) {
+	if rules == nil || isEmpty(*rules) {
+		return nil, nil
+	}
+
+	f := &Filter{
+		rules:   *rules,
+		include: lowerAll(rules.IncludeKeywords),
+		exclude: lowerAll(rules.ExcludeKeywords),
+	}
+
+	var err error
+	if rules.TitleRegex != "" {
+		f.titleRegex, err = regexp.Compile(rules.TitleRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid title_regex: %w", err)
+		}
+	}
+	if rules.ContentRegex != "" {
+		f.contentRegex, err = regexp.Compile(rules.ContentRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid content_regex: %w", err)
+		}
+	}
+	if rules.MinLength < 0 {
+		return nil, fmt.Errorf("min_length must not be negative")
+	}
+	for _, a := range rules.Access {
+		if !access.IsValid(a) {
+			return nil, fmt.Errorf("invalid access: %s", a)
+		}
+	}
+	return f, nil
+}
+
+// NeedsContent reports whether Match has to see the page text of the items.
+func (f *Filter) NeedsContent() bool {
+	if f == nil {
+		return false
+	}
+	return len(f.include) > 0 || len(f.exclude) > 0 || f.contentRegex != nil || f.rules.MinLength > 0
+}
+
+// Prefilter reports whether the feed item may still pass the filter. It only rejects
+// items that cannot pass whatever the item page contains.
+func (f *Filter) Prefilter(item *gofeed.Item) bool {
+	if f == nil {
+		return true
+	}
+
+	title := strings.TrimSpace(item.Title)
+	text := FeedText(item)
+
+	if title != "" && f.titleRegex != nil && !f.titleRegex.MatchString(title) {
+		return false
+	}
+	if containsAny(strings.ToLower(title+" "+text), f.exclude) {
+		return false
+	}
+	if len(f.rules.Authors) > 0 && !f.matchAuthors(item) {
+		return false
+	}
+	if len(f.rules.Categories) > 0 && !f.matchCategories(item) {
+		return false
+	}
+	// The page may add an image, but it cannot remove the one the feed provides
+	if f.rules.HasImage != nil && !*f.rules.HasImage && feedHasImage(item) {
+		return false
+	}
+	return true
+}
+
+// Match decides whether the enriched item is kept. pageText is the text extracted from
+// the item page and may be empty when it is not known.
+func (f *Filter) Match(item *gofeed.Item, feed models.Feed, hasImage bool, pageText string) bool {
+	if f == nil {
+		return true
+	}
+	if !f.Prefilter(item) {
+		return false
+	}
+
+	title := feed.Title
+	text := strings.Join([]string{FeedText(item), html.UnescapeString(feed.Description), pageText}, " ")
+	lowered := strings.ToLower(title + " " + text)
+
+	if f.titleRegex != nil && !f.titleRegex.MatchString(title) {
+		return false
+	}
+	if f.contentRegex != nil && !f.contentRegex.MatchString(text) {
+		return false
+	}
+	if len(f.include) > 0 && !containsAny(lowered, f.include) {
+		return false
+	}
+	if containsAny(lowered, f.exclude) {
+		return false
+	}
+	if f.rules.MinLength > 0 {
+		length := utf8.RuneCountInString(strings.TrimSpace(pageText))
+		if feedLength := utf8.RuneCountInString(FeedText(item)); feedLength > length {
+			length = feedLength
+		}
+		if length < f.rules.MinLength {
+			return false
+		}
+	}
+	if f.rules.HasImage != nil && *f.rules.HasImage != hasImage {
+		return false
+	}
+	// Items are only classified when access classification is enabled, unclassified
+	// items never match an access rule
+	if len(f.rules.Access) > 0 && !slices.Contains(f.rules.Access, feed.Access) {
+		return false
+	}
+	return true
+}
+
+func (f *Filter) matchAuthors(item *gofeed.Item) bool {
+	var names []string
+	for _, a := range item.Authors {
+		if a != nil {
+			names = append(names, strings.ToLower(a.Name), strings.ToLower(a.Email))
+		}
+	}
+	if item.DublinCoreExt != nil {
+		for _, c := range item.DublinCoreExt.Creator {
+			names = append(names, strings.ToLower(c))
+		}
+	}
+	for _, want := range f.rules.Authors {
+		want = strings.ToLower(strings.TrimSpace(want))
+		for _, name := range names {
+			if want != "" && strings.Contains(name, want) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (f *Filter) matchCategories(item *gofeed.Item) bool {
+	for _, want := range f.rules.Categories {
+		for _, c := range item.Categories {
+			if strings.EqualFold(strings.TrimSpace(c), strings.TrimSpace(want)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// FeedText is the plain text the feed carries for the item.
+func FeedText(item *gofeed.Item) string {
+	content := item.Content
+	if len(item.Description) > len(content) {
+		content = item.Description
+	}
+	return strings.TrimSpace(html.UnescapeString(tagRegex.ReplaceAllString(content, " ")))
+}
+
+func feedHasImage(item *gofeed.Item) bool {
+	if item.Image != nil && item.Image.URL != "" {
+		return true
+	}
+	for _, e := range item.Enclosures {
+		if e != nil && strings.HasPrefix(e.Type, "image/") {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAny(text string, keywords []string) bool {
+	for _, k := range keywords {
+		if k != "" && strings.Contains(text, k) {
+			return true
+		}
+	}
+	return false
+}
+
+func lowerAll(values []string) []string {
+	var lowered []string
+	for _, v := range values {
+		if v = strings.ToLower(strings.TrimSpace(v)); v != "" {
+			lowered = append(lowered, v)
+		}
+	}
+	return lowered
+}
+
+func isEmpty(rules models.ItemFilter) bool {
+	return len(rules.IncludeKeywords) == 0 && len(rules.ExcludeKeywords) == 0 &&
+		rules.TitleRegex == "" && rules.ContentRegex == "" &&
+		len(rules.Authors) == 0 && len(rules.Categories) == 0 &&
+		rules.MinLength == 0 && rules.HasImage == nil && len(rules.Access) == 0
+}
+
+// Resolve returns the filter that applies to a parse request. Inline rules take
+// precedence over a saved filter referenced by id, which takes precedence over the
+// filter attached to the user's subscription.
+func Resolve(rules *models.ItemFilter, filterID, userID, feedID string) (*Filter, error) {
+	if rules != nil && !isEmpty(*rules) {
+		return Compile(rules)
+	}
+	if filterID != "" {
+		id, err := uuid.Parse(filterID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter_id: %s", filterID)
+		}
+		saved, err := GetFilter(id)
+		if err != nil {
+			return nil, err
+		}
+		return Compile(&saved.Filter)
+	}
+	if userID != "" && feedID != "" {
+		if saved, ok := GetSubscriptionFilter(userID, feedID); ok {
+			return Compile(&saved.Filter)
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,105 @@
+package filter
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/lufeed/feed-parser-api/internal/cache"
+	"github.com/lufeed/feed-parser-api/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	filtersKey             = "item_filters"
+	subscriptionFiltersKey = "subscription_filters"
+)
+
+var ErrFilterNotFound = errors.New("filter not found")
+
+func SaveFilter(filter models.SavedFilter) error {
+	b, err := json.Marshal(filter)
+	if err != nil {
+		return err
+	}
+	return cache.SetHashField(filtersKey, filter.ID.String(), b)
+}
+
+func GetFilter(id uuid.UUID) (models.SavedFilter, error) {
+	var filter models.SavedFilter
+	data, err := cache.GetHashField(filtersKey, id.String())
+	if errors.Is(err, redis.Nil) {
+		return filter, ErrFilterNotFound
+	}
+	if err != nil {
+		return filter, err
+	}
+	err = json.Unmarshal([]byte(data), &filter)
+	return filter, err
+}
+
+func ListFilters() ([]models.SavedFilter, error) {
+	all, err := cache.GetHash(filtersKey)
+	if err != nil {
+		return nil, err
+	}
+
+	filters := make([]models.SavedFilter, 0, len(all))
+	for _, data := range all {
+		var filter models.SavedFilter
+		if err := json.Unmarshal([]byte(data), &filter); err != nil {
+			continue
+		}
+		filters = append(filters, filter)
+	}
+	sort.Slice(filters, func(i, j int) bool {
+		return filters[i].CreatedAt.Before(filters[j].CreatedAt)
+	})
+	return filters, nil
+}
+
+// DeleteFilter removes the filter. Subscriptions it was attached to are detached
+// lazily, when they are next looked up.
+func DeleteFilter(id uuid.UUID) error {
+	if _, err := GetFilter(id); err != nil {
+		return err
+	}
+	return cache.DeleteHashField(filtersKey, id.String())
+}
+
+// AttachFilter makes the saved filter apply to every parse of the user's subscription.
+func AttachFilter(userID, feedID string, filterID uuid.UUID) error {
+	if _, err := GetFilter(filterID); err != nil {
+		return err
+	}
+	return cache.SetHashField(subscriptionFiltersKey, subscriptionKey(userID, feedID), filterID.String())
+}
+
+func DetachFilter(userID, feedID string) error {
+	return cache.DeleteHashField(subscriptionFiltersKey, subscriptionKey(userID, feedID))
+}
+
+// GetSubscriptionFilter returns the filter attached to the user's subscription.
+func GetSubscriptionFilter(userID, feedID string) (models.SavedFilter, bool) {
+	id, err := cache.GetHashField(subscriptionFiltersKey, subscriptionKey(userID, feedID))
+	if err != nil || id == "" {
+		return models.SavedFilter{}, false
+	}
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return models.SavedFilter{}, false
+	}
+	filter, err := GetFilter(parsedID)
+	if errors.Is(err, ErrFilterNotFound) {
+		DetachFilter(userID, feedID)
+	}
+	if err != nil {
+		return models.SavedFilter{}, false
+	}
+	return filter, true
+}
+
+func subscriptionKey(userID, feedID string) string {
+	return userID + ":" + feedID
+}
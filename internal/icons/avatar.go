@@ -0,0 +1,146 @@
+package icons
+
+import (
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/draw"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/lufeed/feed-parser-api/internal/opengraph"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Letter height relative to the avatar size
+const letterScale = 0.6
+
+var (
+	avatarFont     *opentype.Font
+	avatarFontOnce sync.Once
+	// Background colors for sites that declare no theme color, picked by domain
+	avatarPalette = []color.NRGBA{
+		{0xe5, 0x39, 0x35, 0xff}, {0xd8, 0x1b, 0x60, 0xff}, {0x8e, 0x24, 0xaa, 0xff},
+		{0x5e, 0x35, 0xb1, 0xff}, {0x39, 0x49, 0xab, 0xff}, {0x1e, 0x88, 0xe5, 0xff},
+		{0x00, 0x89, 0x7b, 0xff}, {0x43, 0xa0, 0x47, 0xff}, {0xf4, 0x51, 0x1e, 0xff},
+		{0x6d, 0x4c, 0x41, 0xff}, {0x54, 0x6e, 0x7a, 0xff}, {0x00, 0x83, 0x8f, 0xff},
+	}
+)
+
+// LetterAvatar draws letter centered on a background square of size x size pixels.
+// The letter is white or black, whichever contrasts more with the background.
+func LetterAvatar(letter rune, background color.NRGBA, size int) image.Image {
+	dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+
+	avatarFontOnce.Do(func() {
+		avatarFont, _ = opentype.Parse(gobold.TTF)
+	})
+	if avatarFont == nil {
+		return dst
+	}
+	face, err := opentype.NewFace(avatarFont, &opentype.FaceOptions{
+		Size:    float64(size) * letterScale,
+		DPI:     72,
+		Hinting: font.HintingFull,
+	})
+	if err != nil {
+		return dst
+	}
+	defer face.Close()
+
+	foreground := color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	if luminance(background) > 0.6 {
+		foreground = color.NRGBA{R: 0x21, G: 0x21, B: 0x21, A: 0xff}
+	}
+
+	// Center the ink of the glyph rather than its advance box
+	d := &font.Drawer{Dst: dst, Src: image.NewUniform(foreground), Face: face}
+	bounds, _ := d.BoundString(string(letter))
+	width := (bounds.Max.X - bounds.Min.X).Round()
+	height := (bounds.Max.Y - bounds.Min.Y).Round()
+	d.Dot = fixed.P((size-width)/2-bounds.Min.X.Round(), (size-height)/2-bounds.Min.Y.Round())
+	d.DrawString(string(letter))
+	return dst
+}
+
+// avatarLetter picks the first letter or digit of the site name, or of the domain
+// without its www prefix. Letters the font cannot draw fall back to the domain.
+func avatarLetter(name, domain string) rune {
+	for i, s := range []string{name, strings.TrimPrefix(domain, "www.")} {
+		for _, r := range s {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				r = unicode.ToUpper(r)
+				if r <= unicode.MaxLatin1 || i == 1 {
+					return r
+				}
+				break
+			}
+		}
+	}
+	return '?'
+}
+
+// avatarColor returns the theme color of the site, its mask icon color, or a palette
+// color derived from the domain.
+func avatarColor(site opengraph.SiteIcons, domain string) color.NRGBA {
+	for _, value := range []string{site.ThemeColor, site.MaskColor} {
+		if c, ok := parseColor(value); ok {
+			return c
+		}
+	}
+	h := fnv.New32a()
+	h.Write([]byte(domain))
+	return avatarPalette[h.Sum32()%uint32(len(avatarPalette))]
+}
+
+// parseColor reads CSS hex and rgb() colors. Transparency is dropped.
+func parseColor(value string) (color.NRGBA, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if strings.HasPrefix(value, "#") {
+		hex := value[1:]
+		switch len(hex) {
+		case 3, 4:
+			hex = fmt.Sprintf("%c%c%c%c%c%c", hex[0], hex[0], hex[1], hex[1], hex[2], hex[2])
+		case 6, 8:
+			hex = hex[:6]
+		default:
+			return color.NRGBA{}, false
+		}
+		v, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil {
+			return color.NRGBA{}, false
+		}
+		return color.NRGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}, true
+	}
+
+	if inner, ok := strings.CutPrefix(value, "rgb"); ok {
+		inner = strings.TrimPrefix(inner, "a")
+		inner = strings.TrimSuffix(strings.TrimPrefix(inner, "("), ")")
+		parts := strings.FieldsFunc(inner, func(r rune) bool { return r == ',' || r == ' ' || r == '/' })
+		if len(parts) < 3 {
+			return color.NRGBA{}, false
+		}
+		var rgb [3]uint8
+		for i := 0; i < 3; i++ {
+			v, err := strconv.Atoi(parts[i])
+			if err != nil || v < 0 || v > 255 {
+				return color.NRGBA{}, false
+			}
+			rgb[i] = uint8(v)
+		}
+		return color.NRGBA{R: rgb[0], G: rgb[1], B: rgb[2], A: 0xff}, true
+	}
+	return color.NRGBA{}, false
+}
+
+// luminance is the relative luminance of c, between 0 and 1.
+func luminance(c color.NRGBA) float64 {
+	return (0.2126*float64(c.R) + 0.7152*float64(c.G) + 0.0722*float64(c.B)) / 255
+}
@@ -0,0 +1,165 @@
+package icons
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/lufeed/feed-parser-api/internal/cache"
+	"github.com/lufeed/feed-parser-api/internal/images"
+	"github.com/lufeed/feed-parser-api/internal/logger"
+	"github.com/lufeed/feed-parser-api/internal/opengraph"
+	"go.uber.org/zap"
+	"golang.org/x/image/draw"
+)
+
+// Sizes of rendered icons, in pixels
+const (
+	DefaultSize = 64
+	MinSize     = 16
+	MaxSize     = 512
+)
+
+const (
+	cachePrefix = "icon:"
+	cacheTTL    = 7 * 24 * time.Hour
+	// Sites without an icon are checked again sooner
+	avatarCacheTTL = 24 * time.Hour
+	// Icons downloaded before falling back to a letter avatar
+	maxDownloads = 6
+)
+
+var (
+	ErrInvalidDomain = errors.New("invalid domain")
+	domainRegex      = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z0-9-]{2,63}$`)
+)
+
+// Icon is a square PNG icon of a site.
+type Icon struct {
+	PNG []byte `json:"png"`
+	// Source is the URL of the icon the PNG was rendered from, empty for letter avatars
+	Source string `json:"source,omitempty"`
+	// Generated is set when the site has no usable icon and a letter avatar was drawn
+	Generated bool `json:"generated"`
+}
+
+// NormalizeDomain accepts a host name or a URL and returns its lower-cased host.
+func NormalizeDomain(input string) (string, error) {
+	input = strings.ToLower(strings.TrimSpace(input))
+	if strings.Contains(input, "://") {
+		u, err := url.Parse(input)
+		if err != nil {
+			return "", ErrInvalidDomain
+		}
+		input = u.Host
+	}
+	input, _, _ = strings.Cut(input, "/")
+	if host, _, err := net.SplitHostPort(input); err == nil {
+		input = host
+	}
+	input = strings.TrimSuffix(input, ".")
+	if !domainRegex.MatchString(input) {
+		return "", ErrInvalidDomain
+	}
+	return input, nil
+}
+
+// Get returns the icon of domain at size x size pixels. The best raster icon the home
+// page or its web app manifest declares is used, otherwise a letter avatar in the
+// theme color of the site. Results are cached.
+func Get(ctx context.Context, cl *http.Client, domain string, size int) (Icon, error) {
+	key := fmt.Sprintf("%s%s:%d", cachePrefix, domain, size)
+	if cached, err := cache.GetCache(key); err == nil && cached != "" {
+		var icon Icon
+		if json.Unmarshal([]byte(cached), &icon) == nil && len(icon.PNG) > 0 {
+			return icon, nil
+		}
+	}
+
+	homeURL := "https://" + domain
+	extractor := opengraph.NewExtractor(cl, homeURL, homeURL, true)
+	extractor.SetContext(ctx)
+	site, err := extractor.ExecIcons()
+	if err != nil {
+		// Sites without a readable home page may still serve the usual paths
+		logger.GetSugaredLogger().With(zap.String("domain", domain)).Debugf("Cannot read home page for icons: %s", err.Error())
+		site.Icons = []opengraph.IconCandidate{
+			{URL: homeURL + "/apple-touch-icon.png"},
+			{URL: homeURL + "/favicon.ico"},
+		}
+	}
+
+	var icon Icon
+	img, source := download(ctx, cl, site.Icons)
+	if img != nil {
+		icon = Icon{Source: source}
+		icon.PNG, _, err = images.Encode(square(img, size), images.FormatPNG)
+	} else {
+		icon = Icon{Generated: true}
+		icon.PNG, _, err = images.Encode(LetterAvatar(avatarLetter(site.Name, domain), avatarColor(site, domain), size), images.FormatPNG)
+	}
+	if err != nil {
+		return Icon{}, err
+	}
+
+	ttl := cacheTTL
+	if icon.Generated {
+		ttl = avatarCacheTTL
+	}
+	b, _ := json.Marshal(icon)
+	cache.SetCache(key, b, ttl)
+	return icon, nil
+}
+
+// download decodes the first candidate that serves a raster image. ICO containers
+// are decoded to their largest frame.
+func download(ctx context.Context, cl *http.Client, candidates []opengraph.IconCandidate) (image.Image, string) {
+	attempts := 0
+	for _, c := range candidates {
+		// SVG icons cannot be rasterized
+		if c.IsVector() {
+			continue
+		}
+		if attempts == maxDownloads {
+			break
+		}
+		attempts++
+
+		img, err := images.Download(ctx, cl, c.URL)
+		if err != nil {
+			logger.GetSugaredLogger().With(zap.String("url", c.URL)).Debugf("Icon is not usable: %s", err.Error())
+			continue
+		}
+		if b := img.Bounds(); b.Dx() > 0 && b.Dy() > 0 {
+			return img, c.URL
+		}
+	}
+	return nil, ""
+}
+
+// square scales img to fit into size x size, up or down, and centers it on a
+// transparent canvas.
+func square(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	scale := math.Min(float64(size)/float64(bounds.Dx()), float64(size)/float64(bounds.Dy()))
+	w := max(1, int(math.Round(float64(bounds.Dx())*scale)))
+	h := max(1, int(math.Round(float64(bounds.Dy())*scale)))
+
+	dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+	target := image.Rect((size-w)/2, (size-h)/2, (size-w)/2+w, (size-h)/2+h)
+	if w == bounds.Dx() && h == bounds.Dy() {
+		draw.Draw(dst, target, img, bounds.Min, draw.Over)
+		return dst
+	}
+	draw.CatmullRom.Scale(dst, target, img, bounds, draw.Over, nil)
+	return dst
+}
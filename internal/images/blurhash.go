@@ -0,0 +1,271 @@
+package images
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/lufeed/feed-parser-api/internal/cache"
+	"github.com/lufeed/feed-parser-api/internal/config"
+	"github.com/lufeed/feed-parser-api/internal/fetch"
+	"golang.org/x/image/draw"
+)
+
+const (
+	// Full downloads are limited, larger images get no blurhash or color
+	maxImageBytes = 10 * 1024 * 1024
+	// Decoding is limited too, a small file may declare a huge image
+	defaultMaxPixels = 40_000_000
+	// Blurhash and dominant color only need a thumbnail
+	sampleSize         = 32
+	previewCachePrefix = "image_preview:"
+	blurhashX          = 4
+	blurhashY          = 3
+	base83Chars        = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+)
+
+// Preview holds the placeholder data clients show while an image loads.
+type Preview struct {
+	Blurhash      string `json:"blurhash"`
+	DominantColor string `json:"dominant_color"`
+}
+
+// GetPreview downloads the image and computes its blurhash and dominant color.
+// Results are cached.
+func GetPreview(ctx context.Context, cl *http.Client, imageURL string) (Preview, error) {
+	var preview Preview
+	if cached, err := cache.GetCache(previewCachePrefix + imageURL); err == nil && cached != "" {
+		if json.Unmarshal([]byte(cached), &preview) == nil {
+			return preview, nil
+		}
+	}
+
+	img, err := Download(ctx, cl, imageURL)
+	if err != nil {
+		return Preview{}, err
+	}
+
+	sample := Resize(img, sampleSize, sampleSize)
+	preview = Preview{
+		Blurhash:      Blurhash(sample, blurhashX, blurhashY),
+		DominantColor: DominantColor(sample),
+	}
+	b, _ := json.Marshal(preview)
+	cache.SetCache(previewCachePrefix+imageURL, b, probeCacheTTL)
+	return preview, nil
+}
+
+// Download fetches and decodes an image in any of the supported formats.
+func Download(ctx context.Context, cl *http.Client, imageURL string) (image.Image, error) {
+	resp, err := fetch.With(cl).Do(ctx, fetch.Request{
+		URL:      imageURL,
+		Header:   http.Header{"Accept": {"image/webp,image/png,image/jpeg,image/*;q=0.8"}},
+		Kind:     fetch.KindImage,
+		MaxBytes: maxImageBytes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("image returned status %d: %s", resp.StatusCode, imageURL)
+	}
+	if resp.ContentLength > maxImageBytes {
+		return nil, fmt.Errorf("image too large (%d bytes): %s", resp.ContentLength, imageURL)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	// The header tells the size before the pixels are allocated
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode image %s: %w", imageURL, err)
+	}
+	if TooLarge(cfg.Width, cfg.Height) {
+		return nil, fmt.Errorf("image too large (%dx%d pixels): %s", cfg.Width, cfg.Height, imageURL)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode image %s: %w", imageURL, err)
+	}
+	return img, nil
+}
+
+// TooLarge reports whether an image of width x height has more pixels than may be
+// decoded.
+func TooLarge(width, height int) bool {
+	maxPixels := defaultMaxPixels
+	if cfg := config.GetConfig(); cfg != nil && cfg.Images.MaxPixels > 0 {
+		maxPixels = cfg.Images.MaxPixels
+	}
+	return int64(width)*int64(height) > int64(maxPixels)
+}
+
+// Resize scales img to fit into maxWidth x maxHeight, keeping its aspect ratio.
+// Images that already fit are returned unchanged.
+func Resize(img image.Image, maxWidth, maxHeight int) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w == 0 || h == 0 || (w <= maxWidth && h <= maxHeight) {
+		return img
+	}
+
+	scale := math.Min(float64(maxWidth)/float64(w), float64(maxHeight)/float64(h))
+	dw := max(1, int(math.Round(float64(w)*scale)))
+	dh := max(1, int(math.Round(float64(h)*scale)))
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Over, nil)
+	return dst
+}
+
+// Blurhash encodes img with the given number of horizontal and vertical components,
+// following https://github.com/woltapp/blurhash.
+func Blurhash(img image.Image, xComponents, yComponents int) string {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w == 0 || h == 0 {
+		return ""
+	}
+
+	// Linear RGB of every pixel, computed once
+	pixels := make([][3]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			r, g, b, _ := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			pixels[y*w+x] = [3]float64{srgbToLinear(r >> 8), srgbToLinear(g >> 8), srgbToLinear(b >> 8)}
+		}
+	}
+
+	factors := make([][3]float64, 0, xComponents*yComponents)
+	for j := 0; j < yComponents; j++ {
+		for i := 0; i < xComponents; i++ {
+			normalisation := 2.0
+			if i == 0 && j == 0 {
+				normalisation = 1
+			}
+			var factor [3]float64
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					basis := normalisation *
+						math.Cos(math.Pi*float64(i)*float64(x)/float64(w)) *
+						math.Cos(math.Pi*float64(j)*float64(y)/float64(h))
+					p := pixels[y*w+x]
+					factor[0] += basis * p[0]
+					factor[1] += basis * p[1]
+					factor[2] += basis * p[2]
+				}
+			}
+			scale := 1 / float64(w*h)
+			factors = append(factors, [3]float64{factor[0] * scale, factor[1] * scale, factor[2] * scale})
+		}
+	}
+
+	var hash strings.Builder
+	hash.WriteString(encode83((xComponents-1)+(yComponents-1)*9, 1))
+
+	dc, ac := factors[0], factors[1:]
+	maximumValue := 1.0
+	if len(ac) > 0 {
+		actualMaximum := 0.0
+		for _, f := range ac {
+			actualMaximum = math.Max(actualMaximum, math.Max(math.Abs(f[0]), math.Max(math.Abs(f[1]), math.Abs(f[2]))))
+		}
+		quantisedMaximum := clamp(int(math.Floor(actualMaximum*166-0.5)), 0, 82)
+		maximumValue = float64(quantisedMaximum+1) / 166
+		hash.WriteString(encode83(quantisedMaximum, 1))
+	} else {
+		hash.WriteString(encode83(0, 1))
+	}
+
+	hash.WriteString(encode83(linearToSRGB(dc[0])<<16+linearToSRGB(dc[1])<<8+linearToSRGB(dc[2]), 4))
+	for _, f := range ac {
+		quant := func(v float64) int {
+			return clamp(int(math.Floor(signPow(v/maximumValue, 0.5)*9+9.5)), 0, 18)
+		}
+		hash.WriteString(encode83(quant(f[0])*19*19+quant(f[1])*19+quant(f[2]), 2))
+	}
+	return hash.String()
+}
+
+// DominantColor returns the average color of the most common color bucket of img as
+// a hex string. Transparent pixels are ignored.
+func DominantColor(img image.Image) string {
+	type bucket struct {
+		count   int
+		r, g, b int
+	}
+	buckets := make(map[int]*bucket)
+	var best *bucket
+
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			if a < 0x8000 {
+				continue
+			}
+			r8, g8, b8 := int(r>>8), int(g>>8), int(b>>8)
+			key := (r8>>4)<<8 | (g8>>4)<<4 | b8>>4
+			bk, ok := buckets[key]
+			if !ok {
+				bk = &bucket{}
+				buckets[key] = bk
+			}
+			bk.count++
+			bk.r += r8
+			bk.g += g8
+			bk.b += b8
+			if best == nil || bk.count > best.count {
+				best = bk
+			}
+		}
+	}
+	if best == nil {
+		return ""
+	}
+	return fmt.Sprintf("#%02x%02x%02x", best.r/best.count, best.g/best.count, best.b/best.count)
+}
+
+func encode83(value, length int) string {
+	result := make([]byte, length)
+	for i := 1; i <= length; i++ {
+		digit := (value / int(math.Pow(83, float64(length-i)))) % 83
+		result[i-1] = base83Chars[digit]
+	}
+	return string(result)
+}
+
+func srgbToLinear(value uint32) float64 {
+	v := float64(value) / 255
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(value float64) int {
+	v := math.Max(0, math.Min(1, value))
+	if v <= 0.0031308 {
+		return int(v*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
+
+func signPow(value, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(value), exp), value)
+}
+
+func clamp(v, lo, hi int) int {
+	return max(lo, min(hi, v))
+}
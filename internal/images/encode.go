@@ -0,0 +1,64 @@
+package images
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+)
+
+// Output formats of derivatives
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+)
+
+const jpegQuality = 82
+
+// Encode writes img in the given format. Without a format, images with transparency
+// become PNG and all others JPEG.
+func Encode(img image.Image, format string) ([]byte, string, error) {
+	if format == "" {
+		format = FormatJPEG
+		if hasTransparency(img) {
+			format = FormatPNG
+		}
+	}
+
+	var buf bytes.Buffer
+	switch format {
+	case FormatJPEG:
+		if hasTransparency(img) {
+			img = flatten(img)
+		}
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "image/jpeg", nil
+	case FormatPNG:
+		encoder := png.Encoder{CompressionLevel: png.BestSpeed}
+		if err := encoder.Encode(&buf, img); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "image/png", nil
+	}
+	return nil, "", fmt.Errorf("unsupported format: %s", format)
+}
+
+func hasTransparency(img image.Image) bool {
+	if opaque, ok := img.(interface{ Opaque() bool }); ok {
+		return !opaque.Opaque()
+	}
+	return false
+}
+
+// flatten draws img on a white background, JPEG has no transparency.
+func flatten(img image.Image) image.Image {
+	dst := image.NewRGBA(img.Bounds())
+	draw.Draw(dst, dst.Bounds(), &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), img, img.Bounds().Min, draw.Over)
+	return dst
+}
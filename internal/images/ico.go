@@ -0,0 +1,230 @@
+package images
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"sort"
+)
+
+const (
+	icoHeader = "\x00\x00\x01\x00"
+	// maxICOSide bounds the frames decoded, the directory caps them at 256
+	maxICOSide = 1024
+	// maxICOAttempts is how many frames are tried before giving up, each may well
+	// declare the largest size allowed
+	maxICOAttempts = 4
+)
+
+func init() {
+	image.RegisterFormat("ico", icoHeader, DecodeICO, DecodeICOConfig)
+}
+
+type icoEntry struct {
+	width, height int
+	bitCount      int
+	offset, size  int
+}
+
+// DecodeICO decodes the largest frame of an ICO container, or the next largest ones
+// when it is broken. Frames may be PNG images or device-independent bitmaps.
+func DecodeICO(r io.Reader) (image.Image, error) {
+	b, err := io.ReadAll(io.LimitReader(r, maxImageBytes))
+	if err != nil {
+		return nil, err
+	}
+	entries, err := icoEntries(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, entry := range entries[:min(len(entries), maxICOAttempts)] {
+		data := b[entry.offset : entry.offset+entry.size]
+		var img image.Image
+		if bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")) {
+			// The directory caps sizes at 256, the PNG header may still declare more
+			var cfg image.Config
+			cfg, err = png.DecodeConfig(bytes.NewReader(data))
+			if err == nil && (cfg.Width > maxICOSide || cfg.Height > maxICOSide || TooLarge(cfg.Width, cfg.Height)) {
+				err = fmt.Errorf("ico: frame too large (%dx%d pixels)", cfg.Width, cfg.Height)
+			}
+			if err == nil {
+				img, err = png.Decode(bytes.NewReader(data))
+			}
+		} else {
+			img, err = decodeDIB(data)
+		}
+		if err == nil {
+			return img, nil
+		}
+		lastErr = err
+	}
+	return nil, fmt.Errorf("no decodable ico frame: %w", lastErr)
+}
+
+// DecodeICOConfig returns the size of the largest frame of an ICO container.
+func DecodeICOConfig(r io.Reader) (image.Config, error) {
+	header := make([]byte, 6+16*64)
+	n, err := io.ReadFull(r, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return image.Config{}, err
+	}
+	count := 0
+	if n >= 6 {
+		count = int(binary.LittleEndian.Uint16(header[4:6]))
+	}
+	best := image.Config{ColorModel: color.NRGBAModel}
+	for i := 0; i < count && 6+16*(i+1) <= n; i++ {
+		w, h := icoDimension(header[6+16*i]), icoDimension(header[6+16*i+1])
+		if w*h > best.Width*best.Height {
+			best.Width, best.Height = w, h
+		}
+	}
+	if best.Width == 0 {
+		return best, errors.New("ico: no frames")
+	}
+	return best, nil
+}
+
+// icoEntries returns the frames of the container, largest and deepest first.
+func icoEntries(b []byte) ([]icoEntry, error) {
+	if len(b) < 6 || string(b[:4]) != icoHeader {
+		return nil, errors.New("ico: invalid header")
+	}
+	count := int(binary.LittleEndian.Uint16(b[4:6]))
+
+	var entries []icoEntry
+	for i := 0; i < count; i++ {
+		start := 6 + 16*i
+		if start+16 > len(b) {
+			break
+		}
+		e := b[start : start+16]
+		entry := icoEntry{
+			width:    icoDimension(e[0]),
+			height:   icoDimension(e[1]),
+			bitCount: int(binary.LittleEndian.Uint16(e[6:8])),
+			size:     int(binary.LittleEndian.Uint32(e[8:12])),
+			offset:   int(binary.LittleEndian.Uint32(e[12:16])),
+		}
+		if entry.offset <= 0 || entry.size <= 0 || entry.offset+entry.size > len(b) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("ico: no frames")
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		ai, aj := entries[i].width*entries[i].height, entries[j].width*entries[j].height
+		if ai != aj {
+			return ai > aj
+		}
+		return entries[i].bitCount > entries[j].bitCount
+	})
+	return entries, nil
+}
+
+func icoDimension(v byte) int {
+	if v == 0 {
+		return 256
+	}
+	return int(v)
+}
+
+// decodeDIB decodes a bitmap stored in an ICO frame: a BITMAPINFOHEADER, an optional
+// palette, the bottom-up color rows and a 1-bit transparency mask.
+func decodeDIB(data []byte) (image.Image, error) {
+	if len(data) < 40 {
+		return nil, errors.New("ico: short bitmap header")
+	}
+	headerSize := int(binary.LittleEndian.Uint32(data[0:4]))
+	width := int(int32(binary.LittleEndian.Uint32(data[4:8])))
+	// The height covers the color rows and the mask rows
+	height := int(int32(binary.LittleEndian.Uint32(data[8:12]))) / 2
+	bitCount := int(binary.LittleEndian.Uint16(data[14:16]))
+	compression := binary.LittleEndian.Uint32(data[16:20])
+	colorsUsed := int(binary.LittleEndian.Uint32(data[32:36]))
+
+	if width <= 0 || height <= 0 || width > maxICOSide || height > maxICOSide {
+		return nil, fmt.Errorf("ico: unsupported bitmap size %dx%d", width, height)
+	}
+	if compression != 0 {
+		return nil, errors.New("ico: compressed bitmaps are not supported")
+	}
+
+	var palette []color.NRGBA
+	offset := headerSize
+	if bitCount <= 8 {
+		if colorsUsed == 0 {
+			colorsUsed = 1 << bitCount
+		}
+		if offset+4*colorsUsed > len(data) {
+			return nil, errors.New("ico: short palette")
+		}
+		for i := 0; i < colorsUsed; i++ {
+			p := data[offset+4*i:]
+			palette = append(palette, color.NRGBA{R: p[2], G: p[1], B: p[0], A: 255})
+		}
+		offset += 4 * colorsUsed
+	}
+
+	stride := ((width*bitCount + 31) / 32) * 4
+	maskStride := ((width + 31) / 32) * 4
+	maskOffset := offset + stride*height
+	if maskOffset > len(data) {
+		return nil, errors.New("ico: short bitmap data")
+	}
+	hasMask := maskOffset+maskStride*height <= len(data)
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	anyAlpha := false
+	for y := 0; y < height; y++ {
+		row := data[offset+(height-1-y)*stride:]
+		for x := 0; x < width; x++ {
+			var c color.NRGBA
+			switch bitCount {
+			case 32:
+				c = color.NRGBA{R: row[4*x+2], G: row[4*x+1], B: row[4*x], A: row[4*x+3]}
+				if c.A != 0 {
+					anyAlpha = true
+				}
+			case 24:
+				c = color.NRGBA{R: row[3*x+2], G: row[3*x+1], B: row[3*x], A: 255}
+			case 8, 4, 1:
+				bitOffset := x * bitCount
+				index := int(row[bitOffset/8]>>(8-bitCount-bitOffset%8)) & (1<<bitCount - 1)
+				if index < len(palette) {
+					c = palette[index]
+				}
+			default:
+				return nil, fmt.Errorf("ico: unsupported bit count %d", bitCount)
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+
+	// 32-bit frames carry alpha, the mask is only needed when the alpha channel is unused
+	if hasMask && (bitCount != 32 || !anyAlpha) {
+		for y := 0; y < height; y++ {
+			row := data[maskOffset+(height-1-y)*maskStride:]
+			for x := 0; x < width; x++ {
+				c := img.NRGBAAt(x, y)
+				if row[x/8]&(0x80>>(x%8)) != 0 {
+					c.A = 0
+				} else {
+					c.A = 255
+				}
+				img.SetNRGBA(x, y, c)
+			}
+		}
+	}
+	return img, nil
+}
@@ -0,0 +1,299 @@
+package images
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"slices"
+	"strings"
+	"testing"
+)
+
+var (
+	red   = color.NRGBA{R: 255, A: 255}
+	green = color.NRGBA{G: 255, A: 255}
+	blue  = color.NRGBA{B: 255, A: 255}
+	white = color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+	clear = color.NRGBA{}
+)
+
+// frame is an ICO frame, encoded at offset, or after the previous frame when 0.
+type frame struct {
+	width, height int
+	bitCount      int
+	data          []byte
+	offset        int
+}
+
+// newImage returns a width x height image whose pixels are colors row by row.
+func newImage(width, height int, colors ...color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for i, c := range colors {
+		img.SetNRGBA(i%width, i/width, c)
+	}
+	return img
+}
+
+// encodeDIB encodes img as the bitmap of an ICO frame with bitCount bits per pixel,
+// indexing palette for 8 bits and less. Transparent pixels are set in the AND mask.
+func encodeDIB(img *image.NRGBA, bitCount int, palette []color.NRGBA) []byte {
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	header := make([]byte, 40)
+	binary.LittleEndian.PutUint32(header[0:4], 40)
+	binary.LittleEndian.PutUint32(header[4:8], uint32(width))
+	binary.LittleEndian.PutUint32(header[8:12], uint32(2*height))
+	binary.LittleEndian.PutUint16(header[12:14], 1)
+	binary.LittleEndian.PutUint16(header[14:16], uint16(bitCount))
+	binary.LittleEndian.PutUint32(header[32:36], uint32(len(palette)))
+
+	b := header
+	for _, c := range palette {
+		b = append(b, c.B, c.G, c.R, 0)
+	}
+
+	stride := ((width*bitCount + 31) / 32) * 4
+	// Rows are stored bottom-up
+	for y := height - 1; y >= 0; y-- {
+		row := make([]byte, stride)
+		for x := 0; x < width; x++ {
+			c := img.NRGBAAt(x, y)
+			switch bitCount {
+			case 32:
+				copy(row[4*x:], []byte{c.B, c.G, c.R, c.A})
+			case 24:
+				copy(row[3*x:], []byte{c.B, c.G, c.R})
+			default:
+				index := max(slices.Index(palette, c), 0)
+				bitOffset := x * bitCount
+				row[bitOffset/8] |= byte(index << (8 - bitCount - bitOffset%8))
+			}
+		}
+		b = append(b, row...)
+	}
+
+	maskStride := ((width + 31) / 32) * 4
+	for y := height - 1; y >= 0; y-- {
+		row := make([]byte, maskStride)
+		for x := 0; x < width; x++ {
+			if img.NRGBAAt(x, y).A == 0 {
+				row[x/8] |= 0x80 >> (x % 8)
+			}
+		}
+		b = append(b, row...)
+	}
+	return b
+}
+
+// dibFrame returns img encoded as a bitmap frame.
+func dibFrame(img *image.NRGBA, bitCount int, palette []color.NRGBA) frame {
+	return frame{
+		width:    img.Bounds().Dx(),
+		height:   img.Bounds().Dy(),
+		bitCount: bitCount,
+		data:     encodeDIB(img, bitCount, palette),
+	}
+}
+
+// pngFrame returns img encoded as a PNG frame.
+func pngFrame(t testing.TB, img *image.NRGBA) frame {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return frame{width: img.Bounds().Dx(), height: img.Bounds().Dy(), bitCount: 32, data: buf.Bytes()}
+}
+
+// encodeICO returns an ICO container of frames.
+func encodeICO(frames ...frame) []byte {
+	b := []byte(icoHeader)
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(frames)))
+	next := 6 + 16*len(frames)
+	var data []byte
+	for _, f := range frames {
+		offset := f.offset
+		if offset == 0 {
+			offset = next + len(data)
+			data = append(data, f.data...)
+		}
+		b = append(b, byte(f.width), byte(f.height), 0, 0)
+		b = binary.LittleEndian.AppendUint16(b, 1)
+		b = binary.LittleEndian.AppendUint16(b, uint16(f.bitCount))
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(f.data)))
+		b = binary.LittleEndian.AppendUint32(b, uint32(offset))
+	}
+	return append(b, data...)
+}
+
+// withoutAlpha clears the alpha bytes of a 32-bit bitmap, as in frames relying on the
+// AND mask.
+func withoutAlpha(f frame) frame {
+	data := slices.Clone(f.data)
+	for i := 0; i < f.width*f.height; i++ {
+		data[40+4*i+3] = 0
+	}
+	f.data = data
+	return f
+}
+
+// truncated cuts the bitmap of f after n bytes.
+func truncated(f frame, n int) frame {
+	f.data = f.data[:n]
+	return f
+}
+
+// setHeader overwrites the 32-bit field of the bitmap header of f at offset.
+func setHeader(f frame, offset int, value uint32) frame {
+	f.data = slices.Clone(f.data)
+	binary.LittleEndian.PutUint32(f.data[offset:], value)
+	return f
+}
+
+func TestDecodeICO(t *testing.T) {
+	twoColors := newImage(4, 2,
+		white, red, white, clear,
+		red, white, red, white,
+	)
+	// Widths not filling a byte or a 4-byte row exercise the padding
+	threeColors := newImage(3, 3,
+		red, green, blue,
+		blue, clear, green,
+		green, blue, red,
+	)
+	translucent := newImage(2, 2,
+		red, color.NRGBA{G: 255, A: 128},
+		clear, color.NRGBA{R: 10, G: 20, B: 30, A: 255},
+	)
+	small := newImage(2, 2, blue, blue, blue, blue)
+	blank := newImage(3, 3, white, white, white, white, white, white, white, white, white)
+
+	tests := []struct {
+		name string
+		ico  []byte
+		want *image.NRGBA
+	}{
+		{"1-bit", encodeICO(dibFrame(twoColors, 1, []color.NRGBA{white, red})), twoColors},
+		{"4-bit", encodeICO(dibFrame(threeColors, 4, []color.NRGBA{red, green, blue})), threeColors},
+		{"8-bit", encodeICO(dibFrame(threeColors, 8, []color.NRGBA{blue, green, red, white})), threeColors},
+		{"24-bit", encodeICO(dibFrame(threeColors, 24, nil)), threeColors},
+		// The alpha channel is used as it is, the mask would make the translucent pixel opaque
+		{"32-bit", encodeICO(dibFrame(translucent, 32, nil)), translucent},
+		{"32-bit without alpha", encodeICO(withoutAlpha(dibFrame(threeColors, 32, nil))), threeColors},
+		{"png", encodeICO(pngFrame(t, translucent)), translucent},
+		{"largest frame", encodeICO(dibFrame(small, 24, nil), dibFrame(threeColors, 24, nil)), threeColors},
+		{"deepest frame", encodeICO(dibFrame(blank, 4, []color.NRGBA{white}), dibFrame(threeColors, 32, nil)), threeColors},
+		{"broken largest frame", encodeICO(truncated(dibFrame(threeColors, 8, []color.NRGBA{red, green, blue}), 48), dibFrame(small, 24, nil)), small},
+		{"frames sharing data", encodeICO(dibFrame(threeColors, 24, nil), frame{width: 3, height: 3, bitCount: 24, data: encodeDIB(threeColors, 24, nil), offset: 6 + 16*2}), threeColors},
+		{"frame past the end", encodeICO(frame{width: 64, height: 64, bitCount: 32, data: make([]byte, 64), offset: 1 << 20}, dibFrame(small, 24, nil)), small},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := DecodeICO(bytes.NewReader(tt.ico))
+			if err != nil {
+				t.Fatalf("DecodeICO: %v", err)
+			}
+			if img.Bounds() != tt.want.Bounds() {
+				t.Fatalf("bounds = %v, want %v", img.Bounds(), tt.want.Bounds())
+			}
+			for y := 0; y < tt.want.Bounds().Dy(); y++ {
+				for x := 0; x < tt.want.Bounds().Dx(); x++ {
+					got := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+					want := tt.want.NRGBAAt(x, y)
+					// The color of transparent pixels does not matter
+					if want.A == 0 {
+						if got.A != 0 {
+							t.Errorf("pixel (%d, %d) = %v, want transparent", x, y, got)
+						}
+					} else if got != want {
+						t.Errorf("pixel (%d, %d) = %v, want %v", x, y, got, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeICOErrors(t *testing.T) {
+	img := newImage(3, 3, red, green, blue, blue, red, green, green, blue, red)
+	indexed := dibFrame(img, 8, []color.NRGBA{red, green, blue})
+
+	tests := []struct {
+		name string
+		ico  []byte
+		err  string
+	}{
+		{"empty", nil, "invalid header"},
+		{"not an ico", []byte("GIF89a\x01\x00\x01\x00"), "invalid header"},
+		{"no frames", encodeICO(), "no frames"},
+		{"short directory", encodeICO(dibFrame(img, 24, nil))[:20], "no frames"},
+		{"frame past the end", encodeICO(frame{width: 3, height: 3, bitCount: 24, data: make([]byte, 100), offset: 500}), "no frames"},
+		{"short header", encodeICO(truncated(indexed, 30)), "short bitmap header"},
+		{"short palette", encodeICO(truncated(indexed, 40+4*3-1)), "short palette"},
+		{"palette larger than the frame", encodeICO(setHeader(indexed, 32, 1<<30)), "short palette"},
+		{"short rows", encodeICO(truncated(indexed, 40+4*3+4*3-1)), "short bitmap data"},
+		{"huge header size", encodeICO(setHeader(dibFrame(img, 24, nil), 0, 1<<31)), "short bitmap data"},
+		{"compressed", encodeICO(setHeader(indexed, 16, 1)), "compressed"},
+		{"16-bit", encodeICO(setHeader(dibFrame(img, 24, nil), 14, 16)), "unsupported bit count"},
+		{"zero width", encodeICO(setHeader(indexed, 4, 0)), "unsupported bitmap size"},
+		{"negative height", encodeICO(setHeader(indexed, 8, 0xfffffffa)), "unsupported bitmap size"},
+		{"too wide", encodeICO(setHeader(indexed, 4, 5000)), "unsupported bitmap size"},
+		// PNG frames may declare any size, and are only decoded up to that of bitmaps
+		{"too wide png", encodeICO(pngFrame(t, newImage(maxICOSide+1, 1))), "frame too large"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeICO(bytes.NewReader(tt.ico))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("DecodeICO = %v, want an error containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestDecodeICOConfig(t *testing.T) {
+	small := newImage(2, 2, blue, blue, blue, blue)
+	large := newImage(3, 3)
+	cfg, err := DecodeICOConfig(bytes.NewReader(encodeICO(dibFrame(small, 24, nil), dibFrame(large, 24, nil))))
+	if err != nil {
+		t.Fatalf("DecodeICOConfig: %v", err)
+	}
+	if cfg.Width != 3 || cfg.Height != 3 {
+		t.Errorf("size = %dx%d, want 3x3", cfg.Width, cfg.Height)
+	}
+
+	// Sizes of 0 in the directory stand for 256
+	cfg, err = DecodeICOConfig(bytes.NewReader(encodeICO(frame{width: 0, height: 0, bitCount: 32, data: []byte{0}})))
+	if err != nil || cfg.Width != 256 || cfg.Height != 256 {
+		t.Errorf("DecodeICOConfig = %dx%d, %v, want 256x256", cfg.Width, cfg.Height, err)
+	}
+
+	if _, err := DecodeICOConfig(bytes.NewReader(encodeICO())); err == nil {
+		t.Error("DecodeICOConfig of an empty container succeeded")
+	}
+}
+
+func FuzzDecodeICO(f *testing.F) {
+	img := newImage(3, 3, red, green, blue, blue, clear, green, green, blue, red)
+	f.Add(encodeICO(dibFrame(img, 1, []color.NRGBA{red, green})))
+	f.Add(encodeICO(dibFrame(img, 4, []color.NRGBA{red, green, blue})))
+	f.Add(encodeICO(dibFrame(img, 8, []color.NRGBA{red, green, blue})))
+	f.Add(encodeICO(dibFrame(img, 24, nil)))
+	f.Add(encodeICO(dibFrame(img, 32, nil), withoutAlpha(dibFrame(img, 32, nil))))
+	f.Add(encodeICO(pngFrame(f, img)))
+	f.Add(encodeICO(truncated(dibFrame(img, 8, []color.NRGBA{red, green, blue}), 45)))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		img, err := DecodeICO(bytes.NewReader(data))
+		if err == nil && img.Bounds().Empty() {
+			t.Error("DecodeICO returned an empty image")
+		}
+		cfg, err := DecodeICOConfig(bytes.NewReader(data))
+		if err == nil && (cfg.Width <= 0 || cfg.Height <= 0) {
+			t.Errorf("DecodeICOConfig returned a size of %dx%d", cfg.Width, cfg.Height)
+		}
+	})
+}
@@ -0,0 +1,186 @@
+package images
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lufeed/feed-parser-api/internal/cache"
+	"github.com/lufeed/feed-parser-api/internal/fetch"
+	_ "golang.org/x/image/webp"
+)
+
+// Origins of image candidates, in the order they are trusted
+const (
+	SourceOpenGraph = "og:image"
+	SourceTwitter   = "twitter:image"
+	SourceJSONLD    = "json-ld"
+	SourceMediaRSS  = "media-rss"
+	SourceEnclosure = "enclosure"
+	SourceFeed      = "feed"
+	SourceContent   = "content"
+)
+
+const (
+	// Enough for the headers of JPEG, PNG, GIF and WebP files in practice
+	probeBytes        = 64 * 1024
+	probeCachePrefix  = "image_probe:"
+	probeCacheTTL     = 7 * 24 * time.Hour
+	failedProbeTTL    = 24 * time.Hour
+	maxDeclaredLength = 20 * 1024 * 1024
+)
+
+// errNotImage marks the probes the server answered with something other than a usable
+// image. Unlike failures to reach it, they are worth remembering.
+var errNotImage = errors.New("not an image")
+
+// Candidate is an image URL found for an item, with the dimensions its source declared,
+// if any.
+type Candidate struct {
+	URL    string `json:"url"`
+	Source string `json:"source"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+}
+
+// Info describes a probed image. Width and Height are zero when the header could not
+// be read, ContentType is empty when the URL does not serve an image.
+type Info struct {
+	URL         string `json:"url"`
+	ContentType string `json:"content_type"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+}
+
+// Probe reads the first bytes of the image at imageURL to find its real content type
+// and pixel dimensions. Results are cached, failures only when the server answered.
+func Probe(ctx context.Context, cl *http.Client, imageURL string) (Info, error) {
+	if cached, err := cache.GetCache(probeCachePrefix + imageURL); err == nil && cached != "" {
+		var info Info
+		if json.Unmarshal([]byte(cached), &info) == nil {
+			if info.ContentType == "" {
+				return info, fmt.Errorf("%w: %s", errNotImage, imageURL)
+			}
+			return info, nil
+		}
+	}
+
+	info, err := probe(ctx, cl, imageURL)
+	if err != nil {
+		// Unavailable hosts and proxies, crawl delays and the like are not the image's
+		// doing, the next item may get it
+		if errors.Is(err, errNotImage) {
+			b, _ := json.Marshal(Info{URL: imageURL})
+			cache.SetCache(probeCachePrefix+imageURL, b, failedProbeTTL)
+		}
+		return info, err
+	}
+	b, _ := json.Marshal(info)
+	cache.SetCache(probeCachePrefix+imageURL, b, probeCacheTTL)
+	return info, nil
+}
+
+func probe(ctx context.Context, cl *http.Client, imageURL string) (Info, error) {
+	info := Info{URL: imageURL}
+
+	resp, err := fetch.With(cl).Do(ctx, fetch.Request{
+		URL: imageURL,
+		Header: http.Header{
+			"Accept": {"image/avif,image/webp,image/png,image/jpeg,image/*;q=0.8"},
+			"Range":  {fmt.Sprintf("bytes=0-%d", probeBytes-1)},
+		},
+		Kind:     fetch.KindImage,
+		MaxBytes: probeBytes,
+	})
+	if err != nil {
+		return info, err
+	}
+	defer resp.Body.Close()
+
+	// Rate limits and timeouts pass
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+		resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+		return info, fmt.Errorf("%w, status %d: %s", errNotImage, resp.StatusCode, imageURL)
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return info, fmt.Errorf("image returned status %d: %s", resp.StatusCode, imageURL)
+	}
+	if resp.ContentLength > maxDeclaredLength {
+		return info, fmt.Errorf("%w, too large (%d bytes): %s", errNotImage, resp.ContentLength, imageURL)
+	}
+
+	header, err := io.ReadAll(resp.Body)
+	if err != nil && len(header) == 0 {
+		return info, err
+	}
+
+	info.ContentType = sniff(header)
+	if info.ContentType == "" {
+		return info, fmt.Errorf("%w (%s): %s", errNotImage, resp.Header.Get("Content-Type"), imageURL)
+	}
+	info.Width, info.Height = dimensions(info.ContentType, header)
+	return info, nil
+}
+
+// sniff detects the image format from its magic bytes.
+func sniff(b []byte) string {
+	switch {
+	case bytes.HasPrefix(b, []byte{0xFF, 0xD8, 0xFF}):
+		return "image/jpeg"
+	case bytes.HasPrefix(b, []byte("\x89PNG\r\n\x1a\n")):
+		return "image/png"
+	case bytes.HasPrefix(b, []byte("GIF87a")), bytes.HasPrefix(b, []byte("GIF89a")):
+		return "image/gif"
+	case len(b) >= 12 && string(b[0:4]) == "RIFF" && string(b[8:12]) == "WEBP":
+		return "image/webp"
+	case len(b) >= 12 && string(b[4:8]) == "ftyp" && (string(b[8:12]) == "avif" || string(b[8:12]) == "avis"):
+		return "image/avif"
+	case bytes.HasPrefix(b, []byte{0x00, 0x00, 0x01, 0x00}):
+		return "image/x-icon"
+	case bytes.HasPrefix(b, []byte("BM")):
+		return "image/bmp"
+	}
+
+	head := strings.ToLower(string(b[:min(len(b), 512)]))
+	if strings.Contains(head, "<svg") {
+		return "image/svg+xml"
+	}
+	return ""
+}
+
+// dimensions reads the pixel size from the image header.
+func dimensions(contentType string, header []byte) (int, int) {
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif", "image/webp":
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(header))
+		if err == nil {
+			return cfg.Width, cfg.Height
+		}
+	case "image/x-icon":
+		cfg, err := DecodeICOConfig(bytes.NewReader(header))
+		if err == nil {
+			return cfg.Width, cfg.Height
+		}
+	case "image/bmp":
+		if len(header) >= 26 {
+			w := int(int32(binary.LittleEndian.Uint32(header[18:22])))
+			h := int(int32(binary.LittleEndian.Uint32(header[22:26])))
+			if h < 0 {
+				h = -h
+			}
+			return w, h
+		}
+	}
+	return 0, 0
+}
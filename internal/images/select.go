@@ -0,0 +1,138 @@
+package images
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// Images smaller than this in either dimension are icons, avatars or pixels
+	minDimension = 120
+	// Wider or taller images than these ratios are banners, dividers or logos
+	maxAspectRatio = 4.0
+	minAspectRatio = 0.25
+	// Social cards are the best fit for item cards
+	targetAspectRatio = 1.91
+	targetArea        = 1200 * 630
+	maxProbes         = 6
+)
+
+var (
+	sourcePriority = map[string]float64{
+		SourceOpenGraph: 30,
+		SourceJSONLD:    25,
+		SourceTwitter:   25,
+		SourceMediaRSS:  20,
+		SourceEnclosure: 20,
+		SourceFeed:      15,
+		SourceContent:   10,
+	}
+	rejectedURLRegex = regexp.MustCompile(`(?i)(pixel|tracking|tracker|spacer|blank|transparent|beacon|1x1|logo|favicon|sprite|badge|avatar|gravatar|emoji|button|doubleclick|/ads?/|feeds\.feedburner\.com/~r)`)
+)
+
+// Select probes the candidates and returns the best acceptable image, if any.
+// Candidates are probed concurrently, at most maxProbes of them.
+func Select(ctx context.Context, cl *http.Client, candidates []Candidate) (Info, bool) {
+	candidates = dedupe(candidates)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return sourcePriority[candidates[i].Source] > sourcePriority[candidates[j].Source]
+	})
+	if len(candidates) > maxProbes {
+		candidates = candidates[:maxProbes]
+	}
+
+	infos := make([]Info, len(candidates))
+	ok := make([]bool, len(candidates))
+	var wg sync.WaitGroup
+	for i, c := range candidates {
+		wg.Add(1)
+		go func(i int, c Candidate) {
+			defer wg.Done()
+			info, err := Probe(ctx, cl, c.URL)
+			if err != nil {
+				return
+			}
+			// Declared dimensions stand in for formats whose header we cannot read
+			if info.Width == 0 && info.Height == 0 {
+				info.Width, info.Height = c.Width, c.Height
+			}
+			infos[i], ok[i] = info, Acceptable(info)
+		}(i, c)
+	}
+	wg.Wait()
+
+	best := -1
+	bestScore := 0.0
+	for i, c := range candidates {
+		if !ok[i] {
+			continue
+		}
+		if s := score(c, infos[i]); best == -1 || s > bestScore {
+			best, bestScore = i, s
+		}
+	}
+	if best == -1 {
+		return Info{}, false
+	}
+	return infos[best], true
+}
+
+// Acceptable reports whether a probed image is fit to illustrate an item.
+func Acceptable(info Info) bool {
+	if info.ContentType == "" || info.ContentType == "image/x-icon" || info.ContentType == "image/svg+xml" {
+		return false
+	}
+	if rejectedURLRegex.MatchString(info.URL) {
+		return false
+	}
+	if info.Width == 0 || info.Height == 0 {
+		// Unknown size, kept but ranked below measured images
+		return true
+	}
+	if info.Width < minDimension || info.Height < minDimension {
+		return false
+	}
+	ratio := float64(info.Width) / float64(info.Height)
+	return ratio <= maxAspectRatio && ratio >= minAspectRatio
+}
+
+func score(c Candidate, info Info) float64 {
+	s := sourcePriority[c.Source]
+	if info.Width == 0 || info.Height == 0 {
+		return s
+	}
+	area := math.Min(float64(info.Width*info.Height), targetArea)
+	s += 50 * area / targetArea
+
+	ratio := float64(info.Width) / float64(info.Height)
+	s += 20 * (1 - math.Min(math.Abs(math.Log(ratio/targetAspectRatio)), 1))
+	return s
+}
+
+// dedupe drops repeated URLs, keeping the first occurrence, and URLs that cannot be
+// fetched over HTTP.
+func dedupe(candidates []Candidate) []Candidate {
+	seen := make(map[string]bool)
+	var result []Candidate
+	for _, c := range candidates {
+		u, err := url.Parse(strings.TrimSpace(c.URL))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			continue
+		}
+		key := u.Host + path.Clean(u.Path) + "?" + u.RawQuery
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		c.URL = u.String()
+		result = append(result, c)
+	}
+	return result
+}
@@ -0,0 +1,69 @@
+package images
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// Params describe a derivative of a remote image. A zero Width or Height leaves that
+// dimension unconstrained, an empty Format picks one from the image.
+type Params struct {
+	URL    string
+	Width  int
+	Height int
+	Format string
+}
+
+func (p Params) canonical() string {
+	return fmt.Sprintf("%s\n%d\n%d\n%s", p.URL, p.Width, p.Height, p.Format)
+}
+
+// Sign returns the signature that authorizes p, so the image endpoint cannot be used
+// as an open proxy.
+func Sign(key string, p Params) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(p.canonical()))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature authorizes p.
+func Verify(key string, p Params, signature string) bool {
+	if key == "" || signature == "" {
+		return false
+	}
+	expected, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(p.canonical()))
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// Query encodes p and its signature as the query of an image endpoint URL.
+func (p Params) Query(key string) url.Values {
+	query := url.Values{}
+	query.Set("url", p.URL)
+	if p.Width > 0 {
+		query.Set("w", strconv.Itoa(p.Width))
+	}
+	if p.Height > 0 {
+		query.Set("h", strconv.Itoa(p.Height))
+	}
+	if p.Format != "" {
+		query.Set("format", p.Format)
+	}
+	query.Set("sig", Sign(key, p))
+	return query
+}
+
+// CacheKey identifies the derivative described by p.
+func (p Params) CacheKey() string {
+	sum := sha256.Sum256([]byte(p.canonical()))
+	return "image_derivative:" + hex.EncodeToString(sum[:])
+}
@@ -2,16 +2,24 @@ package middleware
 
 import (
 	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/labstack/echo/v4"
 	"github.com/lufeed/feed-parser-api/internal/config"
+	"github.com/lufeed/feed-parser-api/internal/placeholder"
 )
 
-func APIKeyAuth(cfg *config.AppConfig) echo.MiddlewareFunc {
+// APIKeyAuth checks the API key of the Authorization header. GET requests to the routes
+// of queryKeyPaths may pass it as the api_key query parameter instead.
+func APIKeyAuth(cfg *config.AppConfig, queryKeyPaths ...string) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			authHeader := c.Request().Header.Get("Authorization")
+			if authHeader == "" && c.Request().Method == http.MethodGet && slices.Contains(queryKeyPaths, c.Path()) && c.QueryParam("api_key") != "" {
+				// Feed readers cannot send headers
+				authHeader = "Bearer " + c.QueryParam("api_key")
+			}
 			if authHeader == "" {
 				return echo.NewHTTPError(http.StatusUnauthorized, "Missing authorization header")
 			}
@@ -29,6 +37,10 @@ func APIKeyAuth(cfg *config.AppConfig) echo.MiddlewareFunc {
 				return echo.NewHTTPError(http.StatusUnauthorized, "Invalid API key")
 			}
 
+			// Parsers read the placeholder policy of the key's tenant from the request context
+			ctx := placeholder.WithPolicy(c.Request().Context(), placeholder.ForAPIKey(apiKey))
+			c.SetRequest(c.Request().WithContext(ctx))
+
 			return next(c)
 		}
 	}
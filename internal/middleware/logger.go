@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	echoMiddleware "github.com/labstack/echo/v4/middleware"
+)
+
+// RequestLogger logs requests like the Echo logger, with the api_key query parameter
+// redacted from the URI.
+func RequestLogger() echo.MiddlewareFunc {
+	return echoMiddleware.LoggerWithConfig(echoMiddleware.LoggerConfig{
+		Format: strings.Replace(echoMiddleware.DefaultLoggerConfig.Format, "${uri}", "${custom}", 1),
+		CustomTagFunc: func(c echo.Context, buf *bytes.Buffer) (int, error) {
+			return buf.WriteString(redactedURI(c.Request()))
+		},
+	})
+}
+
+// redactedURI returns the request URI with the value of api_key replaced, keeping the
+// other parameters as they were sent.
+func redactedURI(req *http.Request) string {
+	path, query, found := strings.Cut(req.RequestURI, "?")
+	if !found {
+		return req.RequestURI
+	}
+	params := strings.Split(query, "&")
+	for i, param := range params {
+		if name, _, _ := strings.Cut(param, "="); name == "api_key" {
+			params[i] = "api_key=redacted"
+		}
+	}
+	return path + "?" + strings.Join(params, "&")
+}
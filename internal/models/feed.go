@@ -5,15 +5,60 @@ import (
 	"time"
 )
 
+// Origins of Feed.PublishedAt
+const (
+	PublishedAtSourceFeed     = "feed"
+	PublishedAtSourcePage     = "page"
+	PublishedAtSourceFallback = "fallback"
+)
+
+// Access classifications of Feed.Access
+const (
+	AccessFree          = "free"
+	AccessMetered       = "metered"
+	AccessHardPaywall   = "hard_paywall"
+	AccessLoginRequired = "login_required"
+)
+
+// Alternate is a language version of an item page, from <link rel="alternate" hreflang>.
+type Alternate struct {
+	Lang string `json:"lang"`
+	URL  string `json:"url"`
+}
+
 type Feed struct {
 	ID          uuid.UUID `json:"id"`
 	Title       string    `json:"title"`
 	Description string    `json:"description"`
 	URL         string    `json:"url"`
-	ImageURL    string    `json:"image_url"`
-	HTML        *string   `json:"html,omitempty"`
-	PublishedAt time.Time `json:"published_at"`
-	FeedID      string    `json:"feed_id"`
-	FeedName    string    `json:"feed_name"`
-	UserID      string    `json:"user_id"`
+	// AMPURL is the AMP version of the item page, URL is always the regular one
+	AMPURL     string      `json:"amp_url,omitempty"`
+	Alternates []Alternate `json:"alternates,omitempty"`
+	ImageURL   *string     `json:"image_url"`
+	// ImageIsPlaceholder is set when the item has no image of its own
+	ImageIsPlaceholder bool   `json:"image_is_placeholder"`
+	ImageWidth         int    `json:"image_width,omitempty"`
+	ImageHeight        int    `json:"image_height,omitempty"`
+	ImageBlurhash      string `json:"image_blurhash,omitempty"`
+	ImageColor         string `json:"image_dominant_color,omitempty"`
+	// Media lists the videos and audios of the item, HasVideo is set when one of them
+	// is a video
+	Media    []Media `json:"media,omitempty"`
+	HasVideo bool    `json:"has_video"`
+	// Access tells whether the item can be read for free, AccessReasons lists the
+	// signals the classification is based on
+	Access        string   `json:"access,omitempty"`
+	AccessReasons []string `json:"access_reasons,omitempty"`
+	HTML          *string  `json:"html,omitempty"`
+	// Truncated is set when the item page was larger than the page limit and only its
+	// beginning was read
+	Truncated         bool      `json:"truncated,omitempty"`
+	Summary           string    `json:"summary,omitempty"`
+	Keywords          []string  `json:"keywords,omitempty"`
+	Topics            []string  `json:"topics,omitempty"`
+	PublishedAt       time.Time `json:"published_at"`
+	PublishedAtSource string    `json:"published_at_source"`
+	FeedID            string    `json:"feed_id"`
+	FeedName          string    `json:"feed_name"`
+	UserID            string    `json:"user_id"`
 }
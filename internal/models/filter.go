@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ItemFilter selects the items of a source to keep. All conditions that are set must
+// hold for an item to be kept.
+type ItemFilter struct {
+	// IncludeKeywords keeps items mentioning at least one of the keywords
+	IncludeKeywords []string `json:"include_keywords,omitempty"`
+	// ExcludeKeywords drops items mentioning any of the keywords
+	ExcludeKeywords []string `json:"exclude_keywords,omitempty"`
+	TitleRegex      string   `json:"title_regex,omitempty"`
+	ContentRegex    string   `json:"content_regex,omitempty"`
+	// Authors keeps items written by one of the authors (case-insensitive substring)
+	Authors []string `json:"authors,omitempty"`
+	// Categories keeps items tagged with one of the categories (case-insensitive)
+	Categories []string `json:"categories,omitempty"`
+	// MinLength is the minimum number of characters of the item text
+	MinLength int   `json:"min_length,omitempty"`
+	HasImage  *bool `json:"has_image,omitempty"`
+	// Access keeps items with one of the access classifications, see Feed.Access
+	Access []string `json:"access,omitempty"`
+}
+
+// SavedFilter is a named filter that can be attached to subscriptions.
+type SavedFilter struct {
+	ID        uuid.UUID  `json:"id"`
+	Name      string     `json:"name"`
+	Filter    ItemFilter `json:"filter"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,27 @@
+package models
+
+// Kinds of Media
+const (
+	MediaKindVideo = "video"
+	MediaKindAudio = "audio"
+)
+
+// Media is a video or audio an item or page offers. Players meant to be embedded in
+// an iframe have the type text/html.
+type Media struct {
+	Kind   string `json:"kind"`
+	URL    string `json:"url"`
+	Type   string `json:"type,omitempty"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+	// Duration in seconds
+	Duration int    `json:"duration,omitempty"`
+	Poster   string `json:"poster,omitempty"`
+	// Origin is where the media was found: og, twitter, json-ld, html or feed
+	Origin string `json:"origin"`
+}
+
+// IsEmbed reports whether the media is a player page rather than a media file.
+func (m Media) IsEmbed() bool {
+	return m.Type == "text/html"
+}
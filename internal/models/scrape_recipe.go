@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ScrapeRecipe describes how to turn a list page of a site without any feed into feed
+// items. Selectors are CSS selectors evaluated relative to each item; a selector may end
+// with "@attr" to read an attribute instead of the element text (e.g. "time@datetime").
+type ScrapeRecipe struct {
+	ID              uuid.UUID `json:"id"`
+	Name            string    `json:"name"`
+	ListURL         string    `json:"list_url"`
+	ItemSelector    string    `json:"item_selector"`
+	TitleSelector   string    `json:"title_selector"`
+	LinkSelector    string    `json:"link_selector"`
+	DateSelector    string    `json:"date_selector"`
+	DateFormat      string    `json:"date_format"`
+	ImageSelector   string    `json:"image_selector"`
+	SummarySelector string    `json:"summary_selector"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
@@ -8,9 +8,34 @@ type Source struct {
 	Description string    `json:"description" `
 	FeedURL     string    `json:"feed_url"`
 	HomeURL     string    `json:"home_url"`
-	ImageURL    string    `json:"image_url"`
-	IconURL     string    `json:"icon_url"`
-	HTML        *string   `json:"html,omitempty"`
-	UserID      string    `json:"user_id"`
-	RequestID   string    `json:"request_id"`
+	ImageURL    *string   `json:"image_url"`
+	IconURL     *string   `json:"icon_url"`
+	// ImageIsPlaceholder and IconIsPlaceholder are set when the source has no cover
+	// or icon of its own
+	ImageIsPlaceholder bool    `json:"image_is_placeholder"`
+	IconIsPlaceholder  bool    `json:"icon_is_placeholder"`
+	HTML               *string `json:"html,omitempty"`
+	// Truncated is set when the feed was larger than the feed limit and only the items
+	// at its beginning were read
+	Truncated bool          `json:"truncated,omitempty"`
+	Platform  *PlatformInfo `json:"platform,omitempty"`
+	Topics    []TopicScore  `json:"topics,omitempty"`
+	UserID    string        `json:"user_id"`
+	RequestID string        `json:"request_id"`
+}
+
+// PlatformInfo holds what is known about a source hosted on a platform such as
+// YouTube or Reddit, whose profile URL was rewritten to the platform feed.
+type PlatformInfo struct {
+	Name            string `json:"name"`
+	Handle          string `json:"handle,omitempty"`
+	ProfileURL      string `json:"profile_url"`
+	AvatarURL       string `json:"avatar_url,omitempty"`
+	SubscriberCount *int64 `json:"subscriber_count,omitempty"`
+}
+
+// TopicScore is the share of the items of a source that belong to a topic.
+type TopicScore struct {
+	Topic string  `json:"topic"`
+	Score float64 `json:"score"`
 }
@@ -0,0 +1,106 @@
+package opengraph
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lufeed/feed-parser-api/internal/access"
+	"golang.org/x/net/html"
+)
+
+// maxInlineScript bounds how much of each inline script ends up in the access markup
+const maxInlineScript = 4096
+
+// StatusError is returned when a page answers with a status other than 200 OK.
+type StatusError struct {
+	StatusCode int
+	// URL is the URL that answered, after redirects
+	URL string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("received non-200 status code: %d", e.StatusCode)
+}
+
+// getAccessSignals gathers what the page tells about its access restrictions:
+// the schema.org isAccessibleForFree and hasPart declarations and the markup paywall
+// providers leave behind. text is the article text of the page.
+func (e *Extractor) getAccessSignals(doc *html.Node, text string) access.Signals {
+	s := access.Signals{
+		StatusCode:     e.statusCode,
+		FinalURL:       e.finalURL,
+		PageTextLength: utf8.RuneCountInString(text),
+	}
+
+	for _, entity := range e.getJSONLD(doc) {
+		if free, ok := jsonLDBool(entity["isAccessibleForFree"]); ok && s.AccessibleForFree == nil {
+			s.AccessibleForFree = &free
+		}
+		s.PaywalledSelectors = append(s.PaywalledSelectors, paywalledSelectors(entity["hasPart"])...)
+	}
+
+	var markup strings.Builder
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			for _, a := range n.Attr {
+				switch {
+				case a.Key == "class", a.Key == "id", n.Data == "script" && a.Key == "src":
+					markup.WriteString(a.Val)
+					markup.WriteString("\n")
+				}
+			}
+			if n.Data == "script" && n.FirstChild != nil && n.FirstChild.Type == html.TextNode {
+				script := n.FirstChild.Data
+				if len(script) > maxInlineScript {
+					script = script[:maxInlineScript]
+				}
+				markup.WriteString(script)
+				markup.WriteString("\n")
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(doc)
+	s.Markup = markup.String()
+	return s
+}
+
+// paywalledSelectors returns the cssSelector of the WebPageElement parts that are
+// not accessible for free.
+func paywalledSelectors(v interface{}) []string {
+	var selectors []string
+	switch t := v.(type) {
+	case []interface{}:
+		for _, part := range t {
+			selectors = append(selectors, paywalledSelectors(part)...)
+		}
+	case map[string]interface{}:
+		if free, ok := jsonLDBool(t["isAccessibleForFree"]); ok && !free {
+			if selector := jsonLDString(t, "cssSelector"); selector != "" {
+				selectors = append(selectors, selector)
+			}
+		}
+	}
+	return selectors
+}
+
+// jsonLDBool reads a boolean that may be given as a JSON boolean or as "true" and
+// "false" strings, as many sites do.
+func jsonLDBool(v interface{}) (bool, bool) {
+	switch t := v.(type) {
+	case bool:
+		return t, true
+	case string:
+		switch strings.ToLower(strings.TrimSpace(t)) {
+		case "true":
+			return true, true
+		case "false":
+			return false, true
+		}
+	}
+	return false, false
+}
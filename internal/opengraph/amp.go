@@ -0,0 +1,135 @@
+package opengraph
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/lufeed/feed-parser-api/internal/models"
+	"golang.org/x/net/html"
+)
+
+// Where AMP versions of blocked pages are looked for when no patterns are configured.
+// {url} is the page URL without query and trailing slash, {origin} its scheme and
+// host and {path} its path without trailing slash.
+var defaultAMPURLPatterns = []string{"{url}/amp", "{url}?amp=1", "{origin}/amp{path}"}
+
+// isAMPDoc reports whether the document is an AMP page, marked by the amp or ⚡
+// attribute of its html element.
+func isAMPDoc(doc *html.Node) bool {
+	for n := doc.FirstChild; n != nil; n = n.NextSibling {
+		if n.Type == html.ElementNode && n.Data == "html" {
+			for _, a := range n.Attr {
+				if a.Key == "amp" || a.Key == "⚡" {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	return false
+}
+
+// linkHref returns the href of the first link element with the given rel.
+func linkHref(doc *html.Node, rel string) string {
+	var href string
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if href != "" {
+			return
+		}
+		if n.Type == html.ElementNode && n.Data == "link" {
+			var linkRel, linkHref string
+			for _, a := range n.Attr {
+				switch a.Key {
+				case "rel":
+					linkRel = a.Val
+				case "href":
+					linkHref = strings.TrimSpace(a.Val)
+				}
+			}
+			for _, r := range strings.Fields(strings.ToLower(linkRel)) {
+				if r == rel {
+					href = linkHref
+					return
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(doc)
+	return href
+}
+
+// getAlternates collects the language versions of the page declared with
+// <link rel="alternate" hreflang>.
+func (e *Extractor) getAlternates(doc *html.Node) []models.Alternate {
+	var alternates []models.Alternate
+	seen := make(map[models.Alternate]bool)
+
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "link" {
+			var rel, lang, href string
+			for _, a := range n.Attr {
+				switch a.Key {
+				case "rel":
+					rel = strings.ToLower(a.Val)
+				case "hreflang":
+					lang = strings.TrimSpace(a.Val)
+				case "href":
+					href = strings.TrimSpace(a.Val)
+				}
+			}
+			if lang != "" && href != "" && strings.Contains(" "+rel+" ", " alternate ") {
+				alternate := models.Alternate{Lang: lang, URL: e.resolveURL(href)}
+				if alternate.URL != "" && !seen[alternate] {
+					seen[alternate] = true
+					alternates = append(alternates, alternate)
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(doc)
+	return alternates
+}
+
+// isBlockedStatus reports whether a status means the page refuses to serve us, rather
+// than that it does not exist.
+func isBlockedStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests,
+		http.StatusUnavailableForLegalReasons, http.StatusServiceUnavailable:
+		return true
+	}
+	return false
+}
+
+// ampFallbackURLs expands the patterns into the URLs the AMP version of pageURL may
+// be served at.
+func ampFallbackURLs(pageURL string, patterns []string) []string {
+	u, err := url.Parse(pageURL)
+	if err != nil || u.Host == "" {
+		return nil
+	}
+	if len(patterns) == 0 {
+		patterns = defaultAMPURLPatterns
+	}
+	origin := u.Scheme + "://" + u.Host
+	path := strings.TrimSuffix(u.EscapedPath(), "/")
+	replacer := strings.NewReplacer("{url}", origin+path, "{origin}", origin, "{path}", path)
+
+	var urls []string
+	for _, pattern := range patterns {
+		candidate := replacer.Replace(pattern)
+		if candidate != pageURL {
+			urls = append(urls, candidate)
+		}
+	}
+	return urls
+}
@@ -0,0 +1,359 @@
+package opengraph
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lufeed/feed-parser-api/internal/fetch"
+	"github.com/lufeed/feed-parser-api/internal/logger"
+	"golang.org/x/net/html"
+)
+
+const maxManifestBytes = 256 * 1024
+
+var (
+	// Regular expression to extract size from sizes attribute (e.g., "32x32", "48x48")
+	sizeRegex = regexp.MustCompile(`(\d+)x(\d+)`)
+	// Common icon paths tried when the page declares none
+	fallbackIconPaths = []string{
+		"/favicon.ico",
+		"/favicon.svg",
+		"/apple-touch-icon.png",
+		"/apple-touch-icon-precomposed.png",
+		path.Join("/favicon", "favicon.ico"),
+		path.Join("/favicon", "favicon.svg"),
+	}
+)
+
+// IconCandidate is an icon declared by a page or its web app manifest.
+type IconCandidate struct {
+	URL  string
+	Type string
+	// Size stores the largest dimension (width or height)
+	Size  int
+	Score int
+}
+
+// IsVector reports whether the icon is an SVG image.
+func (c IconCandidate) IsVector() bool {
+	return strings.Contains(c.Type, "svg") || strings.HasSuffix(strings.ToLower(c.URL), ".svg")
+}
+
+// SiteIcons lists the icons of a site, best first, with the colors and name it
+// brands itself with.
+type SiteIcons struct {
+	Icons      []IconCandidate
+	ThemeColor string
+	MaskColor  string
+	Name       string
+}
+
+type webManifest struct {
+	Name            string `json:"name"`
+	ShortName       string `json:"short_name"`
+	ThemeColor      string `json:"theme_color"`
+	BackgroundColor string `json:"background_color"`
+	Icons           []struct {
+		Src     string `json:"src"`
+		Sizes   string `json:"sizes"`
+		Type    string `json:"type"`
+		Purpose string `json:"purpose"`
+	} `json:"icons"`
+}
+
+// ExecIcons collects the icons declared by the page, its web app manifest and the
+// common fallback paths, without validating them.
+func (e *Extractor) ExecIcons() (SiteIcons, error) {
+	doc, err := e.getDoc()
+	if err != nil {
+		return SiteIcons{}, err
+	}
+
+	icons := SiteIcons{Icons: e.getIconCandidates(doc)}
+	var manifestHref string
+
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			attrs := make(map[string]string)
+			for _, a := range n.Attr {
+				attrs[strings.ToLower(a.Key)] = strings.TrimSpace(a.Val)
+			}
+			switch n.Data {
+			case "meta":
+				switch strings.ToLower(attrs["name"]) {
+				case "theme-color":
+					// Prefer the color without a media query, which is the light scheme one
+					if icons.ThemeColor == "" || attrs["media"] == "" {
+						icons.ThemeColor = attrs["content"]
+					}
+				}
+				if attrs["property"] == "og:site_name" && icons.Name == "" {
+					icons.Name = attrs["content"]
+				}
+			case "link":
+				for _, rel := range strings.Fields(strings.ToLower(attrs["rel"])) {
+					switch rel {
+					case "manifest":
+						manifestHref = attrs["href"]
+					case "mask-icon":
+						icons.MaskColor = attrs["color"]
+					}
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(doc)
+
+	if manifestHref != "" {
+		manifestURL := e.resolveURL(manifestHref)
+		manifest, err := e.getManifest(manifestURL)
+		if err != nil {
+			logger.GetSugaredLogger().Debugf("Cannot read web app manifest %s: %s", manifestURL, err.Error())
+		} else {
+			icons.Icons = append(icons.Icons, manifestIcons(manifestURL, manifest)...)
+			if icons.ThemeColor == "" {
+				icons.ThemeColor = manifest.ThemeColor
+			}
+			if icons.ThemeColor == "" {
+				icons.ThemeColor = manifest.BackgroundColor
+			}
+			if icons.Name == "" {
+				icons.Name = manifest.ShortName
+			}
+			if icons.Name == "" {
+				icons.Name = manifest.Name
+			}
+		}
+	}
+
+	for _, u := range e.fallbackIconURLs() {
+		icons.Icons = append(icons.Icons, IconCandidate{URL: u})
+	}
+	icons.Icons = sortIcons(icons.Icons)
+	return icons, nil
+}
+
+func (e *Extractor) getIcon(doc *html.Node) string {
+	// Candidates are sorted best first, the first one serving an image wins
+	for _, icon := range e.getIconCandidates(doc) {
+		if e.checkHead(icon.URL) {
+			return icon.URL
+		}
+	}
+
+	// Fallbacks: try common paths and validate
+	fallbacks := e.fallbackIconURLs()
+	for _, u := range fallbacks {
+		if e.checkHead(u) {
+			return u
+		}
+	}
+	if len(fallbacks) > 0 {
+		// last resort: default /favicon.ico without HEAD check
+		return fallbacks[0]
+	}
+	return ""
+}
+
+// getIconCandidates walks the DOM and collects scored icon link candidates, best first.
+func (e *Extractor) getIconCandidates(doc *html.Node) []IconCandidate {
+	var icons []IconCandidate
+
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "link" {
+			var relRaw, href, sizes, typ string
+			for _, a := range n.Attr {
+				switch strings.ToLower(a.Key) {
+				case "rel":
+					relRaw = strings.ToLower(a.Val)
+				case "href":
+					href = a.Val
+				case "sizes":
+					sizes = strings.ToLower(a.Val)
+				case "type":
+					typ = strings.ToLower(a.Val)
+				}
+			}
+
+			if href == "" {
+				goto next
+			}
+
+			// Recognize multiple rel tokens (e.g., "shortcut icon", "icon apple-touch-icon")
+			if !isIconRel(relRaw) {
+				goto next
+			}
+
+			// Resolve to absolute, validation is left to the caller
+			if abs := e.resolveURL(href); abs != "" {
+				icons = append(icons, scoreIcon(abs, relRaw, sizes, typ))
+			}
+		}
+	next:
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(doc)
+
+	return sortIcons(icons)
+}
+
+func isIconRel(relRaw string) bool {
+	for _, t := range strings.Fields(relRaw) {
+		if t == "icon" || t == "shortcut" || t == "shortcut-icon" || t == "apple-touch-icon" || t == "apple-touch-icon-precomposed" || t == "mask-icon" || t == "fluid-icon" { // common variants
+			return true
+		}
+	}
+	// Fallback: any rel containing "icon"
+	return strings.Contains(relRaw, "icon")
+}
+
+// scoreIcon rates an icon by its format, declared size and rel.
+func scoreIcon(href, relRaw, sizes, typ string) IconCandidate {
+	icon := IconCandidate{URL: href, Type: typ}
+
+	// Prefer SVG/vector
+	if icon.IsVector() {
+		icon.Score += 2000
+		icon.Size = 1000 // vectors scale well
+	}
+
+	// Parse sizes attr
+	if sizes != "" {
+		if sizes == "any" {
+			icon.Score += 1500
+			if icon.Size < 800 {
+				icon.Size = 800
+			}
+		} else if size := largestIconSize(sizes); size > 0 {
+			icon.Size = size
+			icon.Score += size
+		}
+	}
+
+	// Heuristic: filenames with -NxN
+	if icon.Size == 0 {
+		filenameMatches := sizeRegex.FindStringSubmatch(href)
+		if len(filenameMatches) >= 3 {
+			width, _ := strconv.Atoi(filenameMatches[1])
+			icon.Size = width
+			icon.Score += width
+		}
+	}
+
+	if icon.Size == 0 {
+		// default small score for unknown size
+		icon.Size = 16
+		icon.Score += 16
+	}
+
+	// apple-touch icons are often high-res
+	if strings.Contains(relRaw, "apple-touch-icon") {
+		icon.Score += 200
+	}
+	return icon
+}
+
+// largestIconSize returns the largest dimension of a sizes value like "32x32 192x192".
+func largestIconSize(sizes string) int {
+	largest := 0
+	for _, matches := range sizeRegex.FindAllStringSubmatch(sizes, -1) {
+		width, _ := strconv.Atoi(matches[1])
+		height, _ := strconv.Atoi(matches[2])
+		if width > 0 && height > 0 {
+			largest = max(largest, width, height)
+		}
+	}
+	return largest
+}
+
+func (e *Extractor) getManifest(manifestURL string) (webManifest, error) {
+	var manifest webManifest
+	resp, err := fetch.With(e.cl).Do(e.ctx, fetch.Request{
+		URL:      manifestURL,
+		Header:   http.Header{"Accept": {"application/manifest+json, application/json;q=0.9, */*;q=0.5"}},
+		Kind:     fetch.KindPage,
+		MaxBytes: maxManifestBytes,
+	})
+	if err != nil {
+		return manifest, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return manifest, fmt.Errorf("received non-200 status code: %d", resp.StatusCode)
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&manifest)
+	return manifest, err
+}
+
+// manifestIcons scores the manifest icons like link icons. Their sources are relative
+// to the manifest, not to the page.
+func manifestIcons(manifestURL string, manifest webManifest) []IconCandidate {
+	base, err := url.Parse(manifestURL)
+	if err != nil {
+		return nil
+	}
+	var icons []IconCandidate
+	for _, icon := range manifest.Icons {
+		// Monochrome icons are silhouettes meant to be tinted by the platform
+		if icon.Src == "" || strings.TrimSpace(strings.ToLower(icon.Purpose)) == "monochrome" {
+			continue
+		}
+		src, err := url.Parse(strings.TrimSpace(icon.Src))
+		if err != nil {
+			continue
+		}
+		icons = append(icons, scoreIcon(base.ResolveReference(src).String(), "manifest", strings.ToLower(icon.Sizes), strings.ToLower(icon.Type)))
+	}
+	return icons
+}
+
+func (e *Extractor) fallbackIconURLs() []string {
+	baseURL, err := url.Parse(e.baseUrl)
+	if err != nil || baseURL.Host == "" {
+		return nil
+	}
+	scheme := baseURL.Scheme
+	if scheme == "" {
+		scheme = "https"
+	}
+	urls := make([]string, 0, len(fallbackIconPaths))
+	for _, pth := range fallbackIconPaths {
+		urls = append(urls, scheme+"://"+baseURL.Host+pth)
+	}
+	return urls
+}
+
+// sortIcons orders icons by score then size, dropping repeated URLs.
+func sortIcons(icons []IconCandidate) []IconCandidate {
+	sort.SliceStable(icons, func(i, j int) bool {
+		if icons[i].Score != icons[j].Score {
+			return icons[i].Score > icons[j].Score
+		}
+		return icons[i].Size > icons[j].Size
+	})
+	seen := make(map[string]bool)
+	result := icons[:0]
+	for _, icon := range icons {
+		if seen[icon.URL] {
+			continue
+		}
+		seen[icon.URL] = true
+		result = append(result, icon)
+	}
+	return result
+}
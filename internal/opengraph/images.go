@@ -0,0 +1,158 @@
+package opengraph
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/lufeed/feed-parser-api/internal/images"
+	"golang.org/x/net/html"
+)
+
+// getImageCandidates collects every image the page offers for itself: Open Graph and
+// Twitter card images, JSON-LD images and the first image of the main content.
+func (e *Extractor) getImageCandidates(doc *html.Node) []images.Candidate {
+	var candidates []images.Candidate
+	// Index of the og:image that structured properties like og:image:width refer to
+	current := -1
+
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "meta" {
+			var key, content string
+			for _, a := range n.Attr {
+				switch a.Key {
+				case "property", "name":
+					key = strings.ToLower(strings.TrimSpace(a.Val))
+				case "content":
+					content = strings.TrimSpace(a.Val)
+				}
+			}
+			if content != "" {
+				switch key {
+				case "og:image", "og:image:url", "og:image:secure_url":
+					// og:image:url repeats og:image, structured properties follow their image
+					if current == -1 || key == "og:image" {
+						candidates = append(candidates, images.Candidate{URL: e.resolveURL(content), Source: images.SourceOpenGraph})
+						current = len(candidates) - 1
+					} else if key == "og:image:secure_url" {
+						candidates[current].URL = e.resolveURL(content)
+					}
+				case "og:image:width":
+					if current != -1 {
+						candidates[current].Width, _ = strconv.Atoi(content)
+					}
+				case "og:image:height":
+					if current != -1 {
+						candidates[current].Height, _ = strconv.Atoi(content)
+					}
+				case "twitter:image", "twitter:image:src":
+					candidates = append(candidates, images.Candidate{URL: e.resolveURL(content), Source: images.SourceTwitter})
+					current = -1
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(doc)
+
+	for _, entity := range e.getJSONLD(doc) {
+		for _, u := range jsonLDImages(entity["image"]) {
+			candidates = append(candidates, images.Candidate{URL: e.resolveURL(u), Source: images.SourceJSONLD})
+		}
+	}
+
+	if main := e.findMainContent(doc); main != nil {
+		if c, ok := e.firstContentImage(main); ok {
+			candidates = append(candidates, c)
+		}
+	}
+	return candidates
+}
+
+// firstContentImage returns the first img of the node, preferring the largest srcset
+// entry and lazy-loading attributes over placeholder src values.
+func (e *Extractor) firstContentImage(n *html.Node) (images.Candidate, bool) {
+	if n.Type == html.ElementNode && n.Data == "img" {
+		var src, srcset string
+		var width, height int
+		for _, a := range n.Attr {
+			switch a.Key {
+			case "src":
+				if src == "" && !strings.HasPrefix(a.Val, "data:") {
+					src = a.Val
+				}
+			case "data-src", "data-original", "data-lazy-src":
+				src = a.Val
+			case "srcset", "data-srcset":
+				srcset = a.Val
+			case "width":
+				width, _ = strconv.Atoi(a.Val)
+			case "height":
+				height, _ = strconv.Atoi(a.Val)
+			}
+		}
+		if largest := largestSrcsetEntry(srcset); largest != "" {
+			src = largest
+		}
+		if src != "" {
+			return images.Candidate{URL: e.resolveURL(src), Source: images.SourceContent, Width: width, Height: height}, true
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && (c.Data == "aside" || c.Data == "nav" || c.Data == "footer") {
+			continue
+		}
+		if candidate, ok := e.firstContentImage(c); ok {
+			return candidate, true
+		}
+	}
+	return images.Candidate{}, false
+}
+
+// largestSrcsetEntry picks the URL with the highest width or density descriptor.
+func largestSrcsetEntry(srcset string) string {
+	var best string
+	bestValue := 0.0
+	for _, entry := range strings.Split(srcset, ",") {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+		value := 1.0
+		if len(fields) > 1 {
+			descriptor := fields[1]
+			if v, err := strconv.ParseFloat(strings.TrimRight(descriptor, "wx"), 64); err == nil {
+				value = v
+			}
+		}
+		if best == "" || value > bestValue {
+			best, bestValue = fields[0], value
+		}
+	}
+	return best
+}
+
+// jsonLDImages returns the URLs of a schema.org image property, which may be a URL,
+// an ImageObject or a list of either.
+func jsonLDImages(v interface{}) []string {
+	switch image := v.(type) {
+	case string:
+		return []string{image}
+	case map[string]interface{}:
+		if u := jsonLDString(image, "url"); u != "" {
+			return []string{u}
+		}
+		if u := jsonLDString(image, "contentUrl"); u != "" {
+			return []string{u}
+		}
+	case []interface{}:
+		var urls []string
+		for _, item := range image {
+			urls = append(urls, jsonLDImages(item)...)
+		}
+		return urls
+	}
+	return nil
+}
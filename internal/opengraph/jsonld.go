@@ -0,0 +1,60 @@
+package opengraph
+
+import (
+	"encoding/json"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// getJSONLD collects all JSON-LD objects embedded in the page. Top-level arrays and
+// @graph containers are flattened so callers can look at each entity on its own.
+func (e *Extractor) getJSONLD(doc *html.Node) []map[string]interface{} {
+	var entities []map[string]interface{}
+
+	var collect func(v interface{})
+	collect = func(v interface{}) {
+		switch t := v.(type) {
+		case []interface{}:
+			for _, item := range t {
+				collect(item)
+			}
+		case map[string]interface{}:
+			entities = append(entities, t)
+			if graph, ok := t["@graph"]; ok {
+				collect(graph)
+			}
+		}
+	}
+
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "script" {
+			for _, a := range n.Attr {
+				if a.Key == "type" && strings.EqualFold(strings.TrimSpace(a.Val), "application/ld+json") {
+					if n.FirstChild != nil && n.FirstChild.Type == html.TextNode {
+						var v interface{}
+						if err := json.Unmarshal([]byte(n.FirstChild.Data), &v); err == nil {
+							collect(v)
+						}
+					}
+					break
+				}
+			}
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(doc)
+	return entities
+}
+
+// jsonLDString returns the string value stored under key, if any.
+func jsonLDString(entity map[string]interface{}, key string) string {
+	if v, ok := entity[key].(string); ok {
+		return strings.TrimSpace(v)
+	}
+	return ""
+}
@@ -0,0 +1,301 @@
+package opengraph
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/lufeed/feed-parser-api/internal/models"
+	"golang.org/x/net/html"
+)
+
+// Origins of models.Media found in pages
+const (
+	MediaOriginOpenGraph = "og"
+	MediaOriginTwitter   = "twitter"
+	MediaOriginJSONLD    = "json-ld"
+	MediaOriginHTML      = "html"
+)
+
+var isoDurationRegex = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// getMedia collects the videos and audios of the page: Open Graph video and audio,
+// Twitter players, JSON-LD VideoObject and AudioObject entities and video and audio
+// elements. The first og:image is used as poster of videos that declare none.
+func (e *Extractor) getMedia(doc *html.Node) []models.Media {
+	var media []models.Media
+	// Index of the og:video, og:audio or twitter:player that structured properties refer to
+	current := -1
+	var duration int
+	var poster string
+
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "meta" {
+			var key, content string
+			for _, a := range n.Attr {
+				switch a.Key {
+				case "property", "name":
+					key = strings.ToLower(strings.TrimSpace(a.Val))
+				case "content":
+					content = strings.TrimSpace(a.Val)
+				}
+			}
+			if content != "" {
+				kind, origin, property := mediaProperty(key)
+				switch property {
+				case "url":
+					// og:video:url repeats og:video, structured properties follow their media
+					if current == -1 || media[current].Kind != kind || media[current].Origin != origin || !strings.HasSuffix(key, ":url") {
+						m := models.Media{Kind: kind, URL: e.resolveURL(content), Origin: origin}
+						if origin == MediaOriginTwitter {
+							// twitter:player is the page of the player itself
+							m.Type = "text/html"
+						}
+						media = append(media, m)
+						current = len(media) - 1
+					}
+				case "secure_url":
+					if current != -1 && media[current].Kind == kind {
+						media[current].URL = e.resolveURL(content)
+					}
+				case "stream":
+					// A twitter player stream is the media file behind the player page
+					media = append(media, models.Media{Kind: kind, URL: e.resolveURL(content), Origin: origin})
+					current = len(media) - 1
+				case "type", "stream:content_type":
+					if current != -1 {
+						media[current].Type = strings.ToLower(content)
+					}
+				case "width":
+					if current != -1 {
+						media[current].Width, _ = strconv.Atoi(content)
+					}
+				case "height":
+					if current != -1 {
+						media[current].Height, _ = strconv.Atoi(content)
+					}
+				}
+
+				switch key {
+				case "video:duration", "og:video:duration", "music:duration", "og:audio:duration":
+					duration, _ = strconv.Atoi(content)
+				case "og:image", "og:image:url":
+					if poster == "" {
+						poster = e.resolveURL(content)
+					}
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(doc)
+
+	for i := range media {
+		if media[i].Duration == 0 {
+			media[i].Duration = duration
+		}
+		if media[i].Kind == models.MediaKindVideo && media[i].Poster == "" {
+			media[i].Poster = poster
+		}
+	}
+
+	media = append(media, e.jsonLDMedia(doc)...)
+	media = append(media, e.elementMedia(doc)...)
+	return DedupeMedia(media)
+}
+
+// mediaProperty splits a meta key such as og:video:secure_url into the media kind,
+// its origin and the property. Unrelated keys return an empty property.
+func mediaProperty(key string) (string, string, string) {
+	prefixes := []struct {
+		prefix, kind, origin string
+	}{
+		{"og:video", models.MediaKindVideo, MediaOriginOpenGraph},
+		{"og:audio", models.MediaKindAudio, MediaOriginOpenGraph},
+		{"twitter:player", models.MediaKindVideo, MediaOriginTwitter},
+	}
+	for _, p := range prefixes {
+		if key == p.prefix {
+			return p.kind, p.origin, "url"
+		}
+		if rest, ok := strings.CutPrefix(key, p.prefix+":"); ok {
+			return p.kind, p.origin, rest
+		}
+	}
+	return "", "", ""
+}
+
+// jsonLDMedia reads VideoObject and AudioObject entities, including those nested as
+// the video or audio of an article.
+func (e *Extractor) jsonLDMedia(doc *html.Node) []models.Media {
+	var media []models.Media
+
+	var add func(v interface{}, kind string)
+	add = func(v interface{}, kind string) {
+		switch t := v.(type) {
+		case []interface{}:
+			for _, item := range t {
+				add(item, kind)
+			}
+		case map[string]interface{}:
+			u := jsonLDString(t, "contentUrl")
+			typ := jsonLDString(t, "encodingFormat")
+			if u == "" {
+				u, typ = jsonLDString(t, "embedUrl"), "text/html"
+			}
+			if u == "" {
+				return
+			}
+			m := models.Media{
+				Kind:     kind,
+				URL:      e.resolveURL(u),
+				Type:     strings.ToLower(typ),
+				Duration: ParseISODuration(jsonLDString(t, "duration")),
+				Origin:   MediaOriginJSONLD,
+			}
+			m.Width, m.Height = jsonLDInt(t["width"]), jsonLDInt(t["height"])
+			if thumbnails := jsonLDImages(t["thumbnailUrl"]); len(thumbnails) > 0 {
+				m.Poster = e.resolveURL(thumbnails[0])
+			} else if thumbnails := jsonLDImages(t["thumbnail"]); len(thumbnails) > 0 {
+				m.Poster = e.resolveURL(thumbnails[0])
+			}
+			media = append(media, m)
+		}
+	}
+
+	for _, entity := range e.getJSONLD(doc) {
+		switch jsonLDString(entity, "@type") {
+		case "VideoObject":
+			add(entity, models.MediaKindVideo)
+		case "AudioObject":
+			add(entity, models.MediaKindAudio)
+		default:
+			add(entity["video"], models.MediaKindVideo)
+			add(entity["audio"], models.MediaKindAudio)
+		}
+	}
+	return media
+}
+
+// jsonLDInt reads a number that may be given as a JSON number, a string such as
+// "640px" or a QuantitativeValue.
+func jsonLDInt(v interface{}) int {
+	switch t := v.(type) {
+	case float64:
+		return int(t)
+	case string:
+		n, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(t), "px"))
+		return n
+	case map[string]interface{}:
+		return jsonLDInt(t["value"])
+	}
+	return 0
+}
+
+// elementMedia reads video and audio elements, with their src attribute or source
+// children.
+func (e *Extractor) elementMedia(doc *html.Node) []models.Media {
+	var media []models.Media
+
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode && (n.Data == "video" || n.Data == "audio") {
+			base := models.Media{Kind: models.MediaKindVideo, Origin: MediaOriginHTML}
+			if n.Data == "audio" {
+				base.Kind = models.MediaKindAudio
+			}
+			var src string
+			for _, a := range n.Attr {
+				switch a.Key {
+				case "src", "data-src":
+					src = a.Val
+				case "poster":
+					base.Poster = e.resolveURL(a.Val)
+				case "width":
+					base.Width, _ = strconv.Atoi(a.Val)
+				case "height":
+					base.Height, _ = strconv.Atoi(a.Val)
+				}
+			}
+			if src != "" && !strings.HasPrefix(src, "blob:") {
+				m := base
+				m.URL = e.resolveURL(src)
+				media = append(media, m)
+			}
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				if c.Type != html.ElementNode || c.Data != "source" {
+					continue
+				}
+				m := base
+				for _, a := range c.Attr {
+					switch a.Key {
+					case "src", "data-src":
+						m.URL = e.resolveURL(a.Val)
+					case "type":
+						// Strip codecs parameters such as video/mp4; codecs="avc1"
+						m.Type = strings.ToLower(strings.TrimSpace(strings.Split(a.Val, ";")[0]))
+					}
+				}
+				if m.URL != "" {
+					media = append(media, m)
+				}
+			}
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(doc)
+	return media
+}
+
+// DedupeMedia drops media whose URL was already seen, keeping the first occurrence
+// but filling in the details it lacks from the later ones.
+func DedupeMedia(media []models.Media) []models.Media {
+	index := make(map[string]int)
+	var result []models.Media
+	for _, m := range media {
+		if m.URL == "" || strings.HasPrefix(m.URL, "data:") {
+			continue
+		}
+		i, ok := index[m.URL]
+		if !ok {
+			index[m.URL] = len(result)
+			result = append(result, m)
+			continue
+		}
+		first := &result[i]
+		if first.Type == "" {
+			first.Type = m.Type
+		}
+		if first.Width == 0 && first.Height == 0 {
+			first.Width, first.Height = m.Width, m.Height
+		}
+		if first.Duration == 0 {
+			first.Duration = m.Duration
+		}
+		if first.Poster == "" {
+			first.Poster = m.Poster
+		}
+	}
+	return result
+}
+
+// ParseISODuration converts an ISO 8601 duration such as PT1H2M3S to seconds.
+func ParseISODuration(value string) int {
+	matches := isoDurationRegex.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(value)))
+	if matches == nil {
+		// Some sites put plain seconds in duration fields
+		seconds, _ := strconv.Atoi(value)
+		return seconds
+	}
+	days, _ := strconv.Atoi(matches[1])
+	hours, _ := strconv.Atoi(matches[2])
+	minutes, _ := strconv.Atoi(matches[3])
+	seconds, _ := strconv.ParseFloat(matches[4], 64)
+	return days*86400 + hours*3600 + minutes*60 + int(seconds)
+}
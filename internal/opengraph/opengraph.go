@@ -1,48 +1,76 @@
 package opengraph
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"math"
-	"math/rand"
+	"io"
 	"net/http"
 	"net/url"
-	"path"
-	"regexp"
-	"strconv"
 	"strings"
-	"time"
 
-	"github.com/lufeed/feed-parser-api/internal/browser"
+	"github.com/lufeed/feed-parser-api/internal/access"
+	"github.com/lufeed/feed-parser-api/internal/breaker"
+	"github.com/lufeed/feed-parser-api/internal/config"
+	"github.com/lufeed/feed-parser-api/internal/fetch"
+	"github.com/lufeed/feed-parser-api/internal/images"
 	"github.com/lufeed/feed-parser-api/internal/logger"
+	"github.com/lufeed/feed-parser-api/internal/models"
+	"github.com/lufeed/feed-parser-api/internal/robots"
 	"go.uber.org/zap"
 	"golang.org/x/net/html"
 	"golang.org/x/net/html/charset"
 )
 
 type WebsiteInformation struct {
-	Image       string
-	Description string
-	Icon        string
-	Title       string
-	HTML        string
+	Image         string
+	Description   string
+	Icon          string
+	Title         string
+	HTML          string
+	PublishedTime string
+	Language      string
+	// ImageCandidates are all images the page offers, Image is the first og:image
+	ImageCandidates []images.Candidate
+	Media           []models.Media
+	Access          access.Signals
+	// AMPURL is the AMP version of the page. CanonicalURL is only set when the page
+	// itself is an AMP page and names the regular page it stands for
+	AMPURL       string
+	CanonicalURL string
+	Alternates   []models.Alternate
+	// Truncated is set when the page was larger than the page limit
+	Truncated bool
 }
 
 type Extractor struct {
+	ctx     context.Context
 	cl      *http.Client
 	baseUrl string
 	host    string
 	icon    bool
+	amp     config.AMPConfig
+	// statusCode and finalURL of the last page fetched, truncated when its body was cut
+	statusCode int
+	finalURL   string
+	truncated  bool
+	// ampURL and canonicalURL of the last document, see WebsiteInformation
+	ampURL       string
+	canonicalURL string
 }
 
 func NewExtractor(cl *http.Client, baseUrl string, host string, icon bool) *Extractor {
-	return &Extractor{cl: cl, baseUrl: baseUrl, host: host, icon: icon}
+	e := &Extractor{ctx: context.Background(), cl: cl, baseUrl: baseUrl, host: host, icon: icon}
+	if cfg := config.GetConfig(); cfg != nil {
+		e.amp = cfg.AMP
+	}
+	return e
 }
 
-func (e *Extractor) applyBrowserHeaders(req *http.Request) {
-	profile := browser.GetBrowserHeaders()
-	for k, v := range profile {
-		req.Header.Set(k, v)
-	}
+// SetContext cancels the requests of the extractor along with ctx.
+func (e *Extractor) SetContext(ctx context.Context) {
+	e.ctx = ctx
 }
 
 func (e *Extractor) Exec() (WebsiteInformation, error) {
@@ -52,18 +80,28 @@ func (e *Extractor) Exec() (WebsiteInformation, error) {
 	}
 
 	wsi := WebsiteInformation{
-		Image:       e.getImage(doc),
-		Description: e.getDescription(doc),
-		Title:       e.getTitle(doc),
-		HTML:        e.getHTML(doc),
+		Image:         e.getImage(doc),
+		Description:   e.getDescription(doc),
+		Title:         e.getTitle(doc),
+		HTML:          e.getHTML(doc),
+		PublishedTime: e.getPublishedTime(doc),
+		Language:      e.getLanguage(doc),
 	}
+	wsi.ImageCandidates = e.getImageCandidates(doc)
+	wsi.Media = e.getMedia(doc)
+	wsi.Access = e.getAccessSignals(doc, wsi.HTML)
+	wsi.AMPURL, wsi.CanonicalURL = e.ampURL, e.canonicalURL
+	wsi.Alternates = e.getAlternates(doc)
+	wsi.Truncated = e.truncated
 
 	if e.icon {
 		wsi.Icon = e.getIcon(doc)
 	}
 
+	// The home page only fills in what the page lacks, most pages need no second request
+	missing := wsi.Image == "" || (e.icon && wsi.Icon == "") || wsi.Description == "" || wsi.Title == ""
 	parsedHomeURL, err := url.Parse(e.baseUrl)
-	if err == nil {
+	if err == nil && missing {
 		newUrl := fmt.Sprintf("%s://%s", parsedHomeURL.Scheme, parsedHomeURL.Host)
 		if newUrl != e.baseUrl {
 			e.baseUrl = newUrl
@@ -134,71 +172,107 @@ func (e *Extractor) getDoc() (*html.Node, error) {
 		return nil, fmt.Errorf("URL missing host: %s", baseUrl)
 	}
 
-	// Retry mechanism for fetching the URL (handles transient errors and 429)
-	const maxRetries = 3
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		// Create a new request with headers per attempt
-		req, err := http.NewRequest("GET", baseUrl, nil)
-		if err != nil {
-			logger.GetSugaredLogger().Warnf("Error creating request for %s: %s", baseUrl, err.Error())
-			return nil, err
+	e.ampURL, e.canonicalURL = "", ""
+	doc, heavyAMPURL, err := e.fetchDoc(baseUrl, e.amp.MaxPageBytes)
+	var statusErr *StatusError
+	switch {
+	case err == nil && heavyAMPURL != "":
+		// Too heavy to parse in full: extract the lighter AMP version instead, the
+		// beginning of the page is better than nothing if that fails
+		if ampDoc, _, ampErr := e.fetchDoc(heavyAMPURL, 0); ampErr == nil && isAMPDoc(ampDoc) {
+			logger.GetSugaredLogger().Debugf("Page %s is too heavy, using AMP version %s", baseUrl, heavyAMPURL)
+			doc, e.ampURL = ampDoc, e.finalURL
 		}
-
-		e.applyBrowserHeaders(req)
-
-		resp, err := e.cl.Do(req)
-		if err != nil {
-			// Network/transport error: retry with backoff if attempts remain
-			if attempt < maxRetries-1 {
-				backoff := time.Duration(math.Pow(2, float64(attempt+1))) * time.Second
-				jitter := time.Duration(rand.Int63n(int64(backoff) / 2))
-				retryAfter := backoff + jitter
-				logger.GetSugaredLogger().Warnf("Fetch error for host:%s url:%s (attempt %d/%d), retrying after %v: %s", e.host, baseUrl, attempt+1, maxRetries, retryAfter, err.Error())
-				time.Sleep(retryAfter)
-				continue
+	case errors.As(err, &statusErr) && e.amp.FetchOnBlocked && isBlockedStatus(statusErr.StatusCode):
+		for _, candidate := range ampFallbackURLs(baseUrl, e.amp.FallbackURLPatterns) {
+			if ampDoc, _, ampErr := e.fetchDoc(candidate, 0); ampErr == nil && isAMPDoc(ampDoc) {
+				logger.GetSugaredLogger().Debugf("Page %s is blocked (%d), using AMP version %s", baseUrl, statusErr.StatusCode, candidate)
+				doc, e.ampURL, err = ampDoc, e.finalURL, nil
+				break
 			}
-			logger.GetSugaredLogger().Warnf("Error fetching url from host:%s - url: %s - %s", e.host, baseUrl, err.Error())
-			return nil, err
 		}
+		if err != nil {
+			// Report the blocked page rather than the last AMP guess
+			e.statusCode, e.finalURL = statusErr.StatusCode, statusErr.URL
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
 
-		if resp.StatusCode == http.StatusOK {
-			defer resp.Body.Close()
-			reader, err := charset.NewReader(resp.Body, resp.Header.Get("Content-Type"))
-			if err != nil {
-				logger.GetSugaredLogger().Warnf("Error creating charset reader: host:%s url: %s err: %s", e.host, baseUrl, err.Error())
-				return nil, err
-			}
-			doc, err := html.Parse(reader)
-			if err != nil {
-				logger.GetSugaredLogger().Warnf("Error parsing HTML: %s", err.Error())
-				return nil, err
+	if e.ampURL == "" {
+		if isAMPDoc(doc) {
+			e.ampURL = e.finalURL
+			// Standalone AMP pages are their own canonical page
+			if canonical := e.resolveURL(linkHref(doc, "canonical")); canonical != e.ampURL {
+				e.canonicalURL = canonical
 			}
-			return doc, nil
+		} else {
+			e.ampURL = e.resolveURL(linkHref(doc, "amphtml"))
 		}
+	}
+	return doc, nil
+}
 
-		// Not OK status
-		if (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable) && attempt < maxRetries-1 {
-			resp.Body.Close()
-			backoff := time.Duration(math.Pow(2, float64(attempt+1))) * time.Second
-			jitter := time.Duration(rand.Int63n(int64(backoff) / 2))
-			retryAfter := backoff + jitter
-			logger.GetSugaredLogger().Warnf("Got %d for host:%s url:%s (attempt %d/%d), retrying after %v", resp.StatusCode, e.host, baseUrl, attempt+1, maxRetries, retryAfter)
-			time.Sleep(retryAfter)
-			continue
+// fetchDoc downloads and parses pageURL. When maxBytes is set and the page is larger,
+// only its beginning is parsed: if it links an AMP version, the partial document is
+// returned along with the AMP URL, otherwise the rest of the page is read as usual.
+func (e *Extractor) fetchDoc(pageURL string, maxBytes int64) (*html.Node, string, error) {
+	resp, err := fetch.With(e.cl).Do(e.ctx, fetch.Request{URL: pageURL, Kind: fetch.KindPage, RequireHTML: true})
+	if err != nil {
+		var disallowed *robots.DisallowedError
+		var unavailable *breaker.HostUnavailableError
+		if !errors.As(err, &disallowed) && !errors.As(err, &unavailable) {
+			logger.GetSugaredLogger().Warnf("Error fetching url from host:%s - url: %s - %s", e.host, pageURL, err.Error())
 		}
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	e.statusCode = resp.StatusCode
+	e.finalURL = resp.URL
+	e.truncated = false
+	if resp.StatusCode != http.StatusOK {
+		logger.GetSugaredLogger().Debugf("Received non-200 status code (%d) for %s", resp.StatusCode, pageURL)
+		return nil, "", &StatusError{StatusCode: resp.StatusCode, URL: e.finalURL}
+	}
 
-		// Close body and return error for non-OK
-		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
-			resp.Body.Close()
-			return nil, fmt.Errorf("received non-200 status code: %d", resp.StatusCode)
+	contentType := resp.Header.Get("Content-Type")
+	var body io.Reader = resp.Body
+	if maxBytes > 0 {
+		head, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+		if err != nil {
+			logger.GetSugaredLogger().Warnf("Error reading body: host:%s url: %s err: %s", e.host, pageURL, err.Error())
+			return nil, "", err
+		}
+		if int64(len(head)) > maxBytes {
+			if partial, err := parseHTML(bytes.NewReader(head), contentType); err == nil {
+				if ampURL := e.resolveURL(linkHref(partial, "amphtml")); ampURL != "" {
+					return partial, ampURL, nil
+				}
+			}
 		}
-		logger.GetSugaredLogger().Debugf("Received non-200 status code (%d) for %s", resp.StatusCode, baseUrl)
-		resp.Body.Close()
-		return nil, fmt.Errorf("received non-200 status code: %d", resp.StatusCode)
+		body = io.MultiReader(bytes.NewReader(head), resp.Body)
+	}
+	doc, err := parseHTML(body, contentType)
+	if err != nil {
+		logger.GetSugaredLogger().Warnf("Error parsing HTML: host:%s url: %s err: %s", e.host, pageURL, err.Error())
+		return nil, "", err
+	}
+	// The parser closes what the cut left open, the beginning of the page still counts
+	if e.truncated = resp.Truncated(); e.truncated {
+		logger.GetSugaredLogger().Debugf("Page %s exceeds the page limit, parsed its beginning", pageURL)
 	}
+	return doc, "", nil
+}
 
-	// Exhausted retries
-	return nil, fmt.Errorf("failed to fetch URL after retries: %s", baseUrl)
+// parseHTML parses a page body, converting it to UTF-8 according to contentType.
+func parseHTML(body io.Reader, contentType string) (*html.Node, error) {
+	reader, err := charset.NewReader(body, contentType)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create charset reader: %w", err)
+	}
+	return html.Parse(reader)
 }
 
 func (e *Extractor) getDescription(doc *html.Node) string {
@@ -255,181 +329,97 @@ func (e *Extractor) getImage(doc *html.Node) string {
 	return ogImage
 }
 
-type iconInfo struct {
-	href  string
-	size  int // stores the largest dimension (width or height)
-	score int
-}
-
-func (e *Extractor) getIcon(doc *html.Node) string {
-	var icons []iconInfo
-	// Regular expression to extract size from sizes attribute (e.g., "32x32", "48x48")
-	sizeRegex := regexp.MustCompile(`(\d+)x(\d+)`)
-
-	// walk DOM and collect icon link candidates with scoring
+func (e *Extractor) getTitle(doc *html.Node) string {
+	var ogTitle string
 	var f func(*html.Node)
 	f = func(n *html.Node) {
-		if n.Type == html.ElementNode && n.Data == "link" {
-			var relRaw, href, sizes, typ string
+		if n.Type == html.ElementNode && n.Data == "meta" {
+			var property, content string
 			for _, a := range n.Attr {
-				switch strings.ToLower(a.Key) {
-				case "rel":
-					relRaw = strings.ToLower(a.Val)
-				case "href":
-					href = a.Val
-				case "sizes":
-					sizes = strings.ToLower(a.Val)
-				case "type":
-					typ = strings.ToLower(a.Val)
+				if a.Key == "property" && a.Val == "og:title" {
+					property = a.Val
 				}
-			}
-
-			if href == "" {
-				goto next
-			}
-
-			// Recognize multiple rel tokens (e.g., "shortcut icon", "icon apple-touch-icon")
-			relTokens := strings.Fields(relRaw)
-			hasIconRel := false
-			for _, t := range relTokens {
-				if t == "icon" || t == "shortcut" || t == "shortcut-icon" || t == "apple-touch-icon" || t == "apple-touch-icon-precomposed" || t == "mask-icon" || t == "fluid-icon" { // common variants
-					hasIconRel = true
-					break
+				if a.Key == "content" {
+					content = a.Val
 				}
 			}
-			if !hasIconRel && strings.Contains(relRaw, "icon") {
-				// Fallback: any rel containing "icon"
-				hasIconRel = true
-			}
-			if !hasIconRel {
-				goto next
-			}
-
-			// determine base score
-			score := 0
-			size := 0
-
-			// Prefer SVG/vector
-			if strings.Contains(typ, "svg") || strings.HasSuffix(strings.ToLower(href), ".svg") {
-				score += 2000
-				size = 1000 // vectors scale well
+			if property == "og:title" && content != "" {
+				ogTitle = content
+				return
 			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(doc)
+	return ogTitle
+}
 
-			// Parse sizes attr
-			if sizes != "" {
-				if sizes == "any" {
-					score += 1500
-					if size < 800 {
-						size = 800
+// getLanguage returns the language declared on the html element, or in the
+// content-language meta tag.
+func (e *Extractor) getLanguage(doc *html.Node) string {
+	var language string
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if language != "" {
+			return
+		}
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "html":
+				for _, a := range n.Attr {
+					if (a.Key == "lang" || a.Key == "xml:lang") && strings.TrimSpace(a.Val) != "" {
+						language = strings.TrimSpace(a.Val)
 					}
-				} else {
-					matches := sizeRegex.FindStringSubmatch(sizes)
-					if len(matches) >= 3 {
-						width, _ := strconv.Atoi(matches[1])
-						height, _ := strconv.Atoi(matches[2])
-						if width > 0 && height > 0 {
-							if width > height {
-								size = width
-							} else {
-								size = height
-							}
-							score += size
-						}
+				}
+			case "meta":
+				var httpEquiv, content string
+				for _, a := range n.Attr {
+					if a.Key == "http-equiv" {
+						httpEquiv = strings.ToLower(a.Val)
+					}
+					if a.Key == "content" {
+						content = a.Val
 					}
 				}
-			}
-
-			// Heuristic: filenames with -NxN
-			if size == 0 {
-				filenameMatches := sizeRegex.FindStringSubmatch(href)
-				if len(filenameMatches) >= 3 {
-					width, _ := strconv.Atoi(filenameMatches[1])
-					size = width
-					score += size
+				if httpEquiv == "content-language" && content != "" {
+					language = strings.TrimSpace(strings.Split(content, ",")[0])
 				}
-			}
-
-			if size == 0 {
-				// default small score for unknown size
-				size = 16
-				score += 16
-			}
-
-			// apple-touch icons are often high-res
-			if strings.Contains(relRaw, "apple-touch-icon") {
-				score += 200
-			}
-
-			// Resolve to absolute and validate with HEAD
-			abs := e.resolveURL(href)
-			if abs != "" && e.checkHead(abs) {
-				icons = append(icons, iconInfo{href: abs, size: size, score: score})
+			case "body":
+				return
 			}
 		}
-	next:
 		for c := n.FirstChild; c != nil; c = c.NextSibling {
 			f(c)
 		}
 	}
 	f(doc)
-
-	// pick best candidate
-	var best iconInfo
-	for _, icon := range icons {
-		if icon.score > best.score || (icon.score == best.score && icon.size > best.size) {
-			best = icon
-		}
-	}
-
-	if best.href != "" {
-		return best.href
-	}
-
-	// Fallbacks: try common paths and validate
-	// e.g. /favicon.ico, /favicon.svg, /apple-touch-icon.png, /favicon/favicon.svg
-	baseURL, err := url.Parse(e.baseUrl)
-	if err == nil && baseURL.Host != "" {
-		candidates := []string{
-			"/favicon.ico",
-			"/favicon.svg",
-			"/apple-touch-icon.png",
-			"/apple-touch-icon-precomposed.png",
-			path.Join("/favicon", "favicon.ico"),
-			path.Join("/favicon", "favicon.svg"),
-		}
-		scheme := baseURL.Scheme
-		if scheme == "" {
-			scheme = "https"
-		}
-		for _, pth := range candidates {
-			u := scheme + "://" + baseURL.Host + pth
-			if e.checkHead(u) {
-				return u
-			}
-		}
-		// last resort: default /favicon.ico without HEAD check
-		return scheme + "://" + baseURL.Host + "/favicon.ico"
-	}
-
-	return ""
+	return language
 }
 
-func (e *Extractor) getTitle(doc *html.Node) string {
-	var ogTitle string
+// getPublishedTime looks for the publication date of the page in the article meta
+// tags first and falls back to the datePublished of any JSON-LD entity.
+func (e *Extractor) getPublishedTime(doc *html.Node) string {
+	var published string
 	var f func(*html.Node)
 	f = func(n *html.Node) {
+		if published != "" {
+			return
+		}
 		if n.Type == html.ElementNode && n.Data == "meta" {
 			var property, content string
 			for _, a := range n.Attr {
-				if a.Key == "property" && a.Val == "og:title" {
+				if (a.Key == "property" || a.Key == "name" || a.Key == "itemprop") &&
+					(a.Val == "article:published_time" || a.Val == "og:published_time" || a.Val == "datePublished" || a.Val == "pubdate") {
 					property = a.Val
 				}
 				if a.Key == "content" {
 					content = a.Val
 				}
 			}
-			if property == "og:title" && content != "" {
-				ogTitle = content
+			if property != "" && content != "" {
+				published = strings.TrimSpace(content)
 				return
 			}
 		}
@@ -438,7 +428,16 @@ func (e *Extractor) getTitle(doc *html.Node) string {
 		}
 	}
 	f(doc)
-	return ogTitle
+	if published != "" {
+		return published
+	}
+
+	for _, entity := range e.getJSONLD(doc) {
+		if v := jsonLDString(entity, "datePublished"); v != "" {
+			return v
+		}
+	}
+	return ""
 }
 
 func (e *Extractor) getHTML(doc *html.Node) string {
@@ -558,24 +557,10 @@ func (e *Extractor) checkHead(checkUrl string) bool {
 			}
 		}
 
-		// Test image URL before downloading with browser-like headers
-		headReq, err := http.NewRequest("HEAD", resultUrl, nil)
-		if err != nil {
-			logger.GetSugaredLogger().With(zap.String("url", resultUrl)).Debugf("Error creating HEAD request: %s", err.Error())
-			return false
-		}
-		// use a random-ish profile for HEAD checks to reduce blocking
-		e.applyBrowserHeaders(headReq)
-		headResp, err := e.cl.Do(headReq)
-		if err != nil {
-			logger.GetSugaredLogger().With(zap.String("url", resultUrl)).Debugf("Error checking image URL: %s", err.Error())
+		// Read the image header to make sure an actual image is served
+		if _, err := images.Probe(e.ctx, e.cl, resultUrl); err != nil {
+			logger.GetSugaredLogger().With(zap.String("url", resultUrl)).Debugf("Image URL is not usable: %s", err.Error())
 			resultUrl = ""
-		} else {
-			headResp.Body.Close()
-			if headResp.StatusCode != http.StatusOK {
-				logger.GetSugaredLogger().With(zap.String("url", resultUrl), zap.Int("status", headResp.StatusCode)).Debug("Image URL returned non-200 status code")
-				resultUrl = ""
-			}
 		}
 	}
 	return resultUrl != ""
@@ -0,0 +1,74 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"regexp"
+
+	"github.com/lufeed/feed-parser-api/internal/fetch"
+	"github.com/lufeed/feed-parser-api/internal/logger"
+	"github.com/mmcdole/gofeed"
+)
+
+// feedAccept prefers feed formats but takes anything, sitemaps are recognized later
+const feedAccept = "application/rss+xml, application/atom+xml, application/feed+json, application/xml;q=0.9, text/xml;q=0.9, */*;q=0.8"
+
+var atomRootRegex = regexp.MustCompile(`<feed[\s>]`)
+
+// fetchFeed downloads and parses the feed at feedURL. Statuses other than 2xx are
+// reported the way gofeed reports them. Feeds beyond the feed limit are cut after
+// their last complete item and reported as truncated.
+func fetchFeed(ctx context.Context, fc *fetch.Client, feedURL string) (*gofeed.Feed, bool, error) {
+	resp, err := fc.Do(ctx, fetch.Request{
+		URL:    feedURL,
+		Kind:   fetch.KindFeed,
+		Header: http.Header{"Accept": {feedAccept}},
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, false, gofeed.HTTPError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, err
+	}
+
+	feed, err := gofeed.NewParser().Parse(bytes.NewReader(body))
+	if !resp.Truncated() {
+		return feed, false, err
+	}
+	logger.GetSugaredLogger().Debugf("Feed %s exceeds the feed limit, parsing its beginning", feedURL)
+	if err != nil {
+		if closed, ok := closeTruncatedFeed(body); ok {
+			feed, err = gofeed.NewParser().Parse(bytes.NewReader(closed))
+		}
+	}
+	return feed, true, err
+}
+
+// closeTruncatedFeed cuts an RSS or Atom document after its last complete item and
+// closes the elements the cut left open.
+func closeTruncatedFeed(body []byte) ([]byte, bool) {
+	head := body[:min(len(body), 2048)]
+	itemEnd, closing := "</item>", "</channel></rss>"
+	switch {
+	case bytes.Contains(head, []byte("<rdf:RDF")):
+		closing = "</rdf:RDF>"
+	case atomRootRegex.Match(head):
+		itemEnd, closing = "</entry>", "</feed>"
+	}
+
+	i := bytes.LastIndex(body, []byte(itemEnd))
+	if i < 0 {
+		return nil, false
+	}
+	closed := make([]byte, 0, i+len(itemEnd)+len(closing))
+	closed = append(closed, body[:i+len(itemEnd)]...)
+	return append(closed, closing...), true
+}
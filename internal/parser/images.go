@@ -0,0 +1,72 @@
+package parser
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/lufeed/feed-parser-api/internal/images"
+	"github.com/lufeed/feed-parser-api/internal/opengraph"
+	"github.com/mmcdole/gofeed"
+	ext "github.com/mmcdole/gofeed/extensions"
+)
+
+// selectImage picks the best image for an item among the images of its page and the
+// ones its feed entry carries.
+func selectImage(ctx context.Context, cl *http.Client, item *gofeed.Item, wsi opengraph.WebsiteInformation, itemLink string) (images.Info, bool) {
+	candidates := append([]images.Candidate{}, wsi.ImageCandidates...)
+	candidates = append(candidates, feedImageCandidates(item)...)
+
+	base, err := url.Parse(itemLink)
+	if err == nil {
+		for i, c := range candidates {
+			if u, err := url.Parse(c.URL); err == nil && !u.IsAbs() {
+				candidates[i].URL = base.ResolveReference(u).String()
+			}
+		}
+	}
+	return images.Select(ctx, cl, candidates)
+}
+
+// feedImageCandidates collects Media RSS contents and thumbnails, image enclosures and
+// the item image of a feed entry.
+func feedImageCandidates(item *gofeed.Item) []images.Candidate {
+	var candidates []images.Candidate
+	if media, ok := item.Extensions["media"]; ok {
+		candidates = append(candidates, mediaCandidates(media)...)
+		for _, group := range media["group"] {
+			candidates = append(candidates, mediaCandidates(group.Children)...)
+		}
+	}
+	for _, enclosure := range item.Enclosures {
+		if enclosure != nil && strings.HasPrefix(enclosure.Type, "image/") {
+			candidates = append(candidates, images.Candidate{URL: enclosure.URL, Source: images.SourceEnclosure})
+		}
+	}
+	if item.Image != nil && item.Image.URL != "" {
+		candidates = append(candidates, images.Candidate{URL: item.Image.URL, Source: images.SourceFeed})
+	}
+	return candidates
+}
+
+func mediaCandidates(media map[string][]ext.Extension) []images.Candidate {
+	var candidates []images.Candidate
+	for _, name := range []string{"content", "thumbnail"} {
+		for _, m := range media[name] {
+			u := m.Attrs["url"]
+			if u == "" {
+				continue
+			}
+			medium, typ := m.Attrs["medium"], m.Attrs["type"]
+			if name == "content" && medium != "image" && !strings.HasPrefix(typ, "image/") {
+				continue
+			}
+			width, _ := strconv.Atoi(m.Attrs["width"])
+			height, _ := strconv.Atoi(m.Attrs["height"])
+			candidates = append(candidates, images.Candidate{URL: u, Source: images.SourceMediaRSS, Width: width, Height: height})
+		}
+	}
+	return candidates
+}
@@ -0,0 +1,130 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/lufeed/feed-parser-api/internal/models"
+	"github.com/lufeed/feed-parser-api/internal/opengraph"
+	"github.com/mmcdole/gofeed"
+	ext "github.com/mmcdole/gofeed/extensions"
+)
+
+const mediaOriginFeed = "feed"
+
+// collectMedia merges the media of the page with the ones the feed entry carries.
+// Videos without a poster get the item image.
+func collectMedia(item *gofeed.Item, wsi opengraph.WebsiteInformation, imageURL string) ([]models.Media, bool) {
+	media := append([]models.Media{}, wsi.Media...)
+	media = opengraph.DedupeMedia(append(media, feedMedia(item)...))
+
+	hasVideo := false
+	for i := range media {
+		if media[i].Kind != models.MediaKindVideo {
+			continue
+		}
+		hasVideo = true
+		if media[i].Poster == "" {
+			media[i].Poster = imageURL
+		}
+	}
+	return media, hasVideo
+}
+
+// feedMedia collects Media RSS videos and audios and media enclosures, such as the
+// episodes of podcasts.
+func feedMedia(item *gofeed.Item) []models.Media {
+	var media []models.Media
+	if mediaExt, ok := item.Extensions["media"]; ok {
+		media = append(media, mediaRSSMedia(mediaExt)...)
+		for _, group := range mediaExt["group"] {
+			media = append(media, mediaRSSMedia(group.Children)...)
+		}
+	}
+
+	duration := 0
+	if item.ITunesExt != nil {
+		duration = parseClockDuration(item.ITunesExt.Duration)
+	}
+	for _, enclosure := range item.Enclosures {
+		if enclosure == nil || enclosure.URL == "" {
+			continue
+		}
+		kind := mediaKind("", enclosure.Type)
+		if kind == "" {
+			continue
+		}
+		media = append(media, models.Media{
+			Kind:     kind,
+			URL:      enclosure.URL,
+			Type:     strings.ToLower(enclosure.Type),
+			Duration: duration,
+			Origin:   mediaOriginFeed,
+		})
+	}
+	return media
+}
+
+func mediaRSSMedia(m map[string][]ext.Extension) []models.Media {
+	poster := ""
+	for _, thumbnail := range m["thumbnail"] {
+		if u := thumbnail.Attrs["url"]; u != "" {
+			poster = u
+			break
+		}
+	}
+
+	var media []models.Media
+	for _, content := range m["content"] {
+		u := content.Attrs["url"]
+		kind := mediaKind(content.Attrs["medium"], content.Attrs["type"])
+		if u == "" || kind == "" {
+			continue
+		}
+		width, _ := strconv.Atoi(content.Attrs["width"])
+		height, _ := strconv.Atoi(content.Attrs["height"])
+		duration, _ := strconv.ParseFloat(content.Attrs["duration"], 64)
+		item := models.Media{
+			Kind:     kind,
+			URL:      u,
+			Type:     strings.ToLower(content.Attrs["type"]),
+			Width:    width,
+			Height:   height,
+			Duration: int(duration),
+			Origin:   mediaOriginFeed,
+		}
+		if kind == models.MediaKindVideo {
+			item.Poster = poster
+		}
+		media = append(media, item)
+	}
+	return media
+}
+
+// mediaKind tells videos from audios by the Media RSS medium or the MIME type. Other
+// media return an empty kind.
+func mediaKind(medium, mimeType string) string {
+	mimeType = strings.ToLower(mimeType)
+	switch {
+	case medium == models.MediaKindVideo, strings.HasPrefix(mimeType, "video/"),
+		mimeType == "application/x-shockwave-flash", mimeType == "application/x-mpegurl",
+		mimeType == "application/vnd.apple.mpegurl", mimeType == "application/dash+xml":
+		return models.MediaKindVideo
+	case medium == models.MediaKindAudio, strings.HasPrefix(mimeType, "audio/"):
+		return models.MediaKindAudio
+	}
+	return ""
+}
+
+// parseClockDuration reads durations written as seconds, MM:SS or HH:MM:SS.
+func parseClockDuration(value string) int {
+	total := 0
+	for _, part := range strings.Split(strings.TrimSpace(value), ":") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return 0
+		}
+		total = total*60 + n
+	}
+	return total
+}
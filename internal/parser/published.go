@@ -0,0 +1,72 @@
+package parser
+
+import (
+	"time"
+
+	"github.com/lufeed/feed-parser-api/internal/cache"
+	"github.com/lufeed/feed-parser-api/internal/dates"
+	"github.com/lufeed/feed-parser-api/internal/models"
+	"github.com/lufeed/feed-parser-api/internal/opengraph"
+	"github.com/mmcdole/gofeed"
+)
+
+const (
+	// futureTolerance allows for small clock differences between us and the publisher.
+	futureTolerance = 15 * time.Minute
+	// firstSeenExpiration keeps the first-seen time well beyond the item cache lifetime.
+	firstSeenExpiration = 30 * 24 * time.Hour
+)
+
+// resolvePublished determines the publication time of a feed item. The feed dates are
+// preferred, then the date advertised by the page itself. When neither is usable the
+// time the item was first seen is used, so the fallback stays stable across parses.
+// Dates in the future are clamped to the first-seen time.
+func resolvePublished(item *gofeed.Item, wsi opengraph.WebsiteInformation, link string) (time.Time, string) {
+	firstSeen := getFirstSeen(link)
+
+	if t, ok := feedPublished(item); ok {
+		return dates.Clamp(t, firstSeen, futureTolerance), models.PublishedAtSourceFeed
+	}
+
+	if t, ok := dates.Parse(wsi.PublishedTime); ok {
+		return dates.Clamp(t, firstSeen, futureTolerance), models.PublishedAtSourcePage
+	}
+
+	return firstSeen, models.PublishedAtSourceFallback
+}
+
+func feedPublished(item *gofeed.Item) (time.Time, bool) {
+	if item.PublishedParsed != nil {
+		return *item.PublishedParsed, true
+	}
+	if item.UpdatedParsed != nil {
+		return *item.UpdatedParsed, true
+	}
+	if t, ok := dates.Parse(item.Published); ok {
+		return t, true
+	}
+	if item.DublinCoreExt != nil {
+		for _, d := range item.DublinCoreExt.Date {
+			if t, ok := dates.Parse(d); ok {
+				return t, true
+			}
+		}
+	}
+	return dates.Parse(item.Updated)
+}
+
+// getFirstSeen returns the time the link was first seen, recording now if it is new.
+func getFirstSeen(link string) time.Time {
+	key := "first_seen:" + link
+	cached, err := cache.GetCache(key)
+	if err == nil && cached != "" {
+		t, err := time.Parse(time.RFC3339, cached)
+		if err == nil {
+			return t
+		}
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	cache.SetCache(key, now.Format(time.RFC3339), firstSeenExpiration)
+	return now
+}
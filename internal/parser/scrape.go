@@ -0,0 +1,56 @@
+package parser
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/lufeed/feed-parser-api/internal/models"
+	"github.com/lufeed/feed-parser-api/internal/scrape"
+	"github.com/mmcdole/gofeed"
+)
+
+// parseRecipeFeed scrapes the list page of a recipe and presents the result as a feed,
+// so the items get the same enrichment as items of regular feeds.
+func parseRecipeFeed(cl *http.Client, recipe models.ScrapeRecipe) (*gofeed.Feed, error) {
+	items, err := scrape.NewExtractor(cl, recipe).Exec()
+	if err != nil {
+		return nil, err
+	}
+
+	parsedURL, err := url.Parse(recipe.ListURL)
+	if err != nil {
+		return nil, err
+	}
+
+	title := recipe.Name
+	if title == "" {
+		title = parsedURL.Host
+	}
+
+	feed := &gofeed.Feed{
+		Title:    title,
+		Link:     fmt.Sprintf("%s://%s", parsedURL.Scheme, parsedURL.Host),
+		FeedLink: recipe.ListURL,
+		FeedType: "scrape",
+	}
+
+	for _, i := range items {
+		item := &gofeed.Item{
+			Title:           i.Title,
+			Link:            i.Link,
+			GUID:            i.Link,
+			Description:     i.Summary,
+			PublishedParsed: i.PublishedAt,
+		}
+		if i.PublishedAt == nil {
+			item.Published = i.RawDate
+		}
+		if i.ImageURL != "" {
+			item.Image = &gofeed.Image{URL: i.ImageURL}
+		}
+		feed.Items = append(feed.Items, item)
+	}
+
+	return feed, nil
+}
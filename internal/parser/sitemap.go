@@ -0,0 +1,86 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/lufeed/feed-parser-api/internal/config"
+	"github.com/lufeed/feed-parser-api/internal/logger"
+	"github.com/lufeed/feed-parser-api/internal/sitemap"
+	"github.com/mmcdole/gofeed"
+)
+
+// parseSitemapFeed loads a sitemap (or sitemap index) and presents it as a feed, so
+// sites without RSS go through the same item pipeline as regular sources.
+func parseSitemapFeed(ctx context.Context, cl *http.Client, sitemapURL string) (*gofeed.Feed, error) {
+	entries, err := sitemap.NewParser(cl, config.GetConfig().Sitemap).Exec(ctx, sitemapURL)
+	if err != nil {
+		return nil, err
+	}
+
+	parsedURL, err := url.Parse(sitemapURL)
+	if err != nil {
+		return nil, err
+	}
+
+	feed := &gofeed.Feed{
+		Title:    parsedURL.Host,
+		Link:     fmt.Sprintf("%s://%s", parsedURL.Scheme, parsedURL.Host),
+		FeedLink: sitemapURL,
+		FeedType: "sitemap",
+	}
+
+	for _, entry := range entries {
+		if feed.Title == parsedURL.Host && entry.PublicationName != "" {
+			feed.Title = entry.PublicationName
+		}
+		if feed.Language == "" {
+			feed.Language = entry.Language
+		}
+
+		item := &gofeed.Item{
+			Title:           entry.Title,
+			Link:            entry.URL,
+			GUID:            entry.URL,
+			PublishedParsed: entry.PublishedAt,
+			UpdatedParsed:   entry.LastMod,
+		}
+		if len(entry.Images) > 0 {
+			item.Image = &gofeed.Image{URL: entry.Images[0]}
+		}
+		feed.Items = append(feed.Items, item)
+	}
+
+	return feed, nil
+}
+
+// discoverSitemapFeed looks up the sitemaps announced in the robots.txt of siteURL and
+// returns the first one that can be parsed, together with its URL.
+func discoverSitemapFeed(ctx context.Context, cl *http.Client, siteURL string) (*gofeed.Feed, string, error) {
+	sitemaps, err := sitemap.Discover(ctx, cl, siteURL)
+	if err != nil {
+		return nil, "", err
+	}
+
+	for _, sitemapURL := range sitemaps {
+		if err := ctx.Err(); err != nil {
+			return nil, "", err
+		}
+		feed, err := parseSitemapFeed(ctx, cl, sitemapURL)
+		if err != nil {
+			logger.GetSugaredLogger().Debugf("Cannot parse sitemap %s: %s", sitemapURL, err.Error())
+			continue
+		}
+		return feed, sitemapURL, nil
+	}
+
+	return nil, "", fmt.Errorf("no usable sitemap found for %s", siteURL)
+}
+
+// isFeedTypeError reports whether gofeed rejected the document because it is not a feed.
+func isFeedTypeError(err error) bool {
+	return errors.Is(err, gofeed.ErrFeedTypeNotDetected)
+}
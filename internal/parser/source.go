@@ -3,16 +3,19 @@ package parser
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/lufeed/feed-parser-api/internal/browser"
-	"math"
-	"math/rand"
+	"html"
 	"net/http"
-	"net/url"
 	"strings"
 	"time"
 
+	"github.com/lufeed/feed-parser-api/internal/access"
 	"github.com/lufeed/feed-parser-api/internal/cache"
+	"github.com/lufeed/feed-parser-api/internal/config"
+	"github.com/lufeed/feed-parser-api/internal/fetch"
+	"github.com/lufeed/feed-parser-api/internal/filter"
+	"github.com/lufeed/feed-parser-api/internal/images"
 
 	"github.com/google/uuid"
 	"github.com/lufeed/feed-parser-api/internal/proxy"
@@ -22,18 +25,23 @@ import (
 	"github.com/lufeed/feed-parser-api/internal/logger"
 	"github.com/lufeed/feed-parser-api/internal/models"
 	"github.com/lufeed/feed-parser-api/internal/opengraph"
+	"github.com/lufeed/feed-parser-api/internal/placeholder"
+	"github.com/lufeed/feed-parser-api/internal/platform"
+	"github.com/lufeed/feed-parser-api/internal/robots"
+	"github.com/lufeed/feed-parser-api/internal/scrape"
+	"github.com/lufeed/feed-parser-api/internal/summary"
+	"github.com/lufeed/feed-parser-api/internal/topics"
 	"github.com/mmcdole/gofeed"
 )
 
 type SourceParser struct {
 	ctx          context.Context
 	proxyManager *proxy.Manager
+	source       models.Source
+	language     string
+	filter       *filter.Filter
 }
 
-var (
-	maxRetries = 3
-)
-
 func NewSourceParser(ctx context.Context, pm *proxy.Manager) *SourceParser {
 	return &SourceParser{
 		ctx:          ctx,
@@ -41,55 +49,109 @@ func NewSourceParser(ctx context.Context, pm *proxy.Manager) *SourceParser {
 	}
 }
 
+// SetFilter restricts the items returned by Exec to those matching f. A nil filter
+// keeps every item.
+func (s *SourceParser) SetFilter(f *filter.Filter) {
+	s.filter = f
+}
+
 // FeedItemHandler is a callback for each parsed feed item
 // If nil, no callback is invoked (API mode)
 type FeedItemHandler func(item models.Feed)
 
 func (s *SourceParser) Exec(sourceURL string, sendHTML bool, onItem FeedItemHandler) ([]models.Feed, error) {
 	var feed *gofeed.Feed
+	var truncated bool
 	var err error
 	logger.GetSugaredLogger().Infof("Parsing feed %s", sourceURL)
 
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		fp := gofeed.NewParser()
-		fp.UserAgent = browser.GetUserAgent()
-		cl, proxyID := s.proxyManager.GetProxiedClient()
-		fp.Client = cl
-		feed, err = fp.ParseURL(sourceURL)
-		if err == nil {
-			break
-		}
-		if !strings.Contains(err.Error(), "429") {
+	if recipe, ok := scrape.GetRecipeByURL(sourceURL); ok {
+		cl, proxyID, err := s.proxyManager.Acquire(s.ctx, recipe.ListURL)
+		if err != nil {
 			return nil, err
 		}
+		feed, err = parseRecipeFeed(cl, recipe)
 		s.proxyManager.ReleaseProxy(proxyID)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-		backoffTime := time.Duration(math.Pow(2, float64(attempt+1))) * time.Second
-		jitter := time.Duration(rand.Int63n(int64(backoffTime) / 2))
-		retryAfter := backoffTime + jitter
+	if feed == nil {
+		sourceURL, err = s.resolvePlatformFeed(sourceURL)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-		logger.GetSugaredLogger().Warnf("Got 429 error for %s (attempt %d/%d), retrying after %v",
-			sourceURL, attempt+1, maxRetries, retryAfter)
-		time.Sleep(retryAfter)
+	if feed == nil {
+		feed, truncated, err = fetchFeed(s.ctx, fetch.New(s.proxyManager), sourceURL)
+		if isFeedTypeError(err) {
+			// Not RSS/Atom/JSON: the source may be a sitemap or a site announcing one
+			var cl *http.Client
+			var proxyID int
+			cl, proxyID, err = s.proxyManager.Acquire(s.ctx, sourceURL)
+			if err != nil {
+				return nil, err
+			}
+			feed, err = parseSitemapFeed(s.ctx, cl, sourceURL)
+			if err != nil {
+				feed, _, err = discoverSitemapFeed(s.ctx, cl, sourceURL)
+			}
+			s.proxyManager.ReleaseProxy(proxyID)
+		}
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	if feed == nil {
 		return nil, fmt.Errorf("failed to parse feed URL: %s", sourceURL)
 	}
 
+	s.source = models.Source{
+		Name:        strings.TrimSpace(html.UnescapeString(feed.Title)),
+		Description: feed.Description,
+		FeedURL:     sourceURL,
+		HomeURL:     strings.Split(feed.Link, "?")[0],
+		Truncated:   truncated,
+	}
+	if feed.Image != nil && feed.Image.URL != "" {
+		imageURL := feed.Image.URL
+		s.source.ImageURL = &imageURL
+	}
+	s.language = feed.Language
+
 	var results []models.Feed
 	var mu sync.Mutex
 	var wg sync.WaitGroup
 
-	proxyCount := s.proxyManager.ProxyCount()
-	sem := make(chan struct{}, proxyCount)
+	sem := make(chan struct{}, s.proxyManager.Capacity())
+
+	// Items the feed alone rules out are dropped before their pages are fetched
+	items := feed.Items
+	if s.filter != nil {
+		items = nil
+		for _, item := range feed.Items {
+			if s.filter.Prefilter(item) {
+				items = append(items, item)
+			}
+		}
+	}
+
+	// The page text is only kept in the results when asked for, but the filter may need it
+	fetchHTML := sendHTML || s.filter.NeedsContent()
+
+	// Item pages wait for proxies behind the feeds of other sources, so that a busy
+	// source does not hold up the next one
+	itemCtx := proxy.WithPriority(s.ctx, -1)
 
 	maxItems := 20
-	itemCount := len(feed.Items)
+	itemCount := len(items)
 	if itemCount > maxItems {
 		itemCount = maxItems
 	}
-	for _, item := range feed.Items[:itemCount] {
+	for _, item := range items[:itemCount] {
 		sem <- struct{}{} // acquire slot
 		wg.Add(1)
 		go func(i *gofeed.Item) {
@@ -99,24 +161,42 @@ func (s *SourceParser) Exec(sourceURL string, sendHTML bool, onItem FeedItemHand
 			}()
 			var f models.Feed
 
+			cached := false
 			cacheData, err := cache.GetCache(i.Link)
 			if err == nil && cacheData != "" {
-				err = json.Unmarshal([]byte(cacheData), &f)
+				// fallback to parsing if unmarshal fails
+				cached = json.Unmarshal([]byte(cacheData), &f) == nil
+			}
+			if cached && f.HTML == nil && s.filter.NeedsContent() {
+				// Cached without the page text, the filter would judge the item on nothing
+				cached = false
+			}
+			if !cached {
+				cl, proxyID, err := s.proxyManager.Acquire(itemCtx, i.Link)
 				if err != nil {
-					// fallback to parsing if unmarshal fails
-					cl, proxyID := s.proxyManager.GetProxiedClient()
-					f, err = s.parseFeedItem(cl, i, feed.Link, sendHTML)
-					s.proxyManager.ReleaseProxy(proxyID)
-					b, _ := json.Marshal(f)
-					cache.SetCache(i.Link, b, time.Hour*24)
+					logger.GetSugaredLogger().Warnf("Cannot parse item %s: %s", i.Link, err.Error())
+					return
 				}
-			} else {
-				cl, proxyID := s.proxyManager.GetProxiedClient()
-				f, err = s.parseFeedItem(cl, i, feed.Link, sendHTML)
+				f, err = s.parseFeedItem(cl, i, feed.Link, fetchHTML)
 				s.proxyManager.ReleaseProxy(proxyID)
+				if err != nil {
+					// Not cached, so that the item is parsed again once its host is back
+					logger.GetSugaredLogger().Warnf("Cannot parse item %s: %s", i.Link, err.Error())
+					return
+				}
 				b, _ := json.Marshal(f)
 				cache.SetCache(i.Link, b, time.Hour*24)
 			}
+			if f.ImageIsPlaceholder {
+				// Cached items carry the placeholder of whoever parsed them first
+				f.ImageURL, _ = placeholder.FromContext(s.ctx).ItemImageURL("")
+			}
+			if !s.matchFilter(i, f) {
+				return
+			}
+			if !sendHTML {
+				f.HTML = nil
+			}
 			if onItem != nil {
 				onItem(f)
 			}
@@ -127,54 +207,86 @@ func (s *SourceParser) Exec(sourceURL string, sendHTML bool, onItem FeedItemHand
 	}
 	wg.Wait()
 
+	if topics.GetAnalyzer() != nil {
+		s.source.Topics = topics.SourceProfile(sourceURL)
+	}
+
 	return results, nil
 }
 
+// Source returns the feed level information of the last source parsed by Exec.
+func (s *SourceParser) Source() models.Source {
+	return s.source
+}
+
+func (s *SourceParser) matchFilter(item *gofeed.Item, f models.Feed) bool {
+	if s.filter == nil {
+		return true
+	}
+	pageText := ""
+	if f.HTML != nil {
+		pageText = *f.HTML
+	}
+	return s.filter.Match(item, f, !f.ImageIsPlaceholder, pageText)
+}
+
+// resolvePlatformFeed rewrites profile and channel URLs of known platforms to the feed
+// the platform publishes for them.
+func (s *SourceParser) resolvePlatformFeed(sourceURL string) (string, error) {
+	if _, _, ok := platform.Find(sourceURL); !ok {
+		return sourceURL, nil
+	}
+	cl, proxyID, err := s.proxyManager.Acquire(s.ctx, sourceURL)
+	if err != nil {
+		return "", err
+	}
+	defer s.proxyManager.ReleaseProxy(proxyID)
+	return platform.ResolveFeedURL(cl, sourceURL)
+}
+
 func (s *SourceParser) parseFeedItem(cl *http.Client, item *gofeed.Item, host string, sendHTML bool) (models.Feed, error) {
 	itemLink := strings.Split(item.Link, "?")[0]
 	opengraphExtractor := opengraph.NewExtractor(cl, itemLink, host, false)
+	opengraphExtractor.SetContext(s.ctx)
 	wsi, err := opengraphExtractor.Exec()
-	if err != nil {
+	classifier := access.GetClassifier()
+	var statusErr *opengraph.StatusError
+	var disallowed *robots.DisallowedError
+	var contentType *fetch.ContentTypeError
+	// fetched tells whether the page was read, the access of items whose page was not
+	// cannot be told from it
+	fetched := true
+	switch {
+	case err == nil:
+	case errors.As(err, &disallowed):
+		// Items whose page robots.txt disallows are still made, from what the feed carries
+		wsi = opengraph.WebsiteInformation{}
+		fetched = false
+	case errors.As(err, &contentType):
+		// So are items linking documents, images or downloads rather than pages
+		wsi = opengraph.WebsiteInformation{}
+		fetched = false
+	case classifier != nil && errors.As(err, &statusErr) && classifier.IsRestrictedStatus(statusErr.StatusCode, statusErr.URL):
+		// And items whose page is behind a login
+		wsi = opengraph.WebsiteInformation{}
+		wsi.Access.StatusCode, wsi.Access.FinalURL = statusErr.StatusCode, statusErr.URL
+	default:
 		return models.Feed{}, err
 	}
-	if wsi.Image == "" && item.Image != nil {
-		wsi.Image = item.Image.URL
-	}
 	if wsi.Description == "" {
 		wsi.Description = item.Description
 	}
-	published := item.PublishedParsed
-	if published == nil {
-		published = item.UpdatedParsed
-	}
-	if published == nil {
-		now := time.Now()
-		published = &now
-	}
+	published, publishedSource := resolvePublished(item, wsi, itemLink)
 
-	imageURL := ""
-	if wsi.Image != "" {
-		parsedURL, err := url.Parse(wsi.Image)
-		if err == nil {
-			if !parsedURL.IsAbs() {
-				// Get the base URL from the feed item's URL
-				baseURL, err := url.Parse(itemLink)
-				if err == nil {
-					// Remove any path segments after the last slash and then resolve
-					basePathParts := strings.Split(baseURL.Path, "/")
-					if len(basePathParts) > 1 {
-						baseURL.Path = strings.Join(basePathParts[:len(basePathParts)-1], "/")
-					}
-					imageURL = baseURL.ResolveReference(parsedURL).String()
-				}
-			} else {
-				imageURL = wsi.Image
-			}
-		}
+	title := item.Title
+	if title == "" {
+		title = wsi.Title
 	}
 
-	if imageURL == "" {
-		imageURL = "https://s3.eu-central-1.amazonaws.com/lufeed/feeds/lufeed-bg.png"
+	imageURL := ""
+	image, hasImage := selectImage(s.ctx, cl, item, wsi, itemLink)
+	if hasImage {
+		imageURL = image.URL
 	}
 
 	feedID, err := uuid.NewUUID()
@@ -183,12 +295,58 @@ func (s *SourceParser) parseFeedItem(cl *http.Client, item *gofeed.Item, host st
 	}
 
 	feed := models.Feed{
-		ID:          feedID,
-		Title:       item.Title,
-		Description: wsi.Description,
-		URL:         itemLink,
-		ImageURL:    imageURL,
-		PublishedAt: *published,
+		ID:                feedID,
+		Title:             title,
+		Description:       wsi.Description,
+		URL:               itemLink,
+		AMPURL:            wsi.AMPURL,
+		Alternates:        wsi.Alternates,
+		Truncated:         wsi.Truncated,
+		PublishedAt:       published,
+		PublishedAtSource: publishedSource,
+	}
+	if wsi.CanonicalURL != "" {
+		// The feed links the AMP page, the item links the page it stands for
+		feed.URL = wsi.CanonicalURL
+	}
+	feed.ImageURL, feed.ImageIsPlaceholder = placeholder.FromContext(s.ctx).ItemImageURL(imageURL)
+
+	feed.Media, feed.HasVideo = collectMedia(item, wsi, imageURL)
+
+	if classifier != nil && fetched {
+		result := classifier.Classify(itemLink, wsi.Access, filter.FeedText(item))
+		feed.Access, feed.AccessReasons = result.Access, result.Reasons
+	}
+
+	if hasImage {
+		feed.ImageWidth = image.Width
+		feed.ImageHeight = image.Height
+		// Images too large to decode keep their dimensions, without a preview
+		if images.TooLarge(image.Width, image.Height) {
+			logger.GetSugaredLogger().Debugf("Image %s is too large for a preview (%dx%d pixels)", image.URL, image.Width, image.Height)
+		} else if preview, err := images.GetPreview(s.ctx, cl, image.URL); err != nil {
+			logger.GetSugaredLogger().Debugf("Cannot compute image preview of %s: %s", image.URL, err.Error())
+		} else {
+			feed.ImageBlurhash = preview.Blurhash
+			feed.ImageColor = preview.DominantColor
+		}
+	}
+
+	if analyzer := topics.GetAnalyzer(); analyzer != nil {
+		language := wsi.Language
+		if language == "" {
+			language = s.language
+		}
+		result := analyzer.Analyze(language, title, wsi.Description+"\n"+wsi.HTML)
+		feed.Keywords = result.Keywords
+		feed.Topics = result.Topics
+		if err := topics.RecordSourceTopics(s.source.FeedURL, result.Topics); err != nil {
+			logger.GetSugaredLogger().Debugf("Cannot record topics of %s: %s", s.source.FeedURL, err.Error())
+		}
+	}
+
+	if cfg := config.GetConfig().Summary; cfg.Enabled {
+		feed.Summary = summary.NewSummarizer(cfg.MaxSentences, cfg.MaxChars).Summarize(wsi.HTML)
 	}
 
 	if sendHTML {
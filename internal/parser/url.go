@@ -3,17 +3,22 @@ package parser
 import (
 	"context"
 	"fmt"
-	"github.com/lufeed/feed-parser-api/internal/browser"
 	"html"
 	"net/http"
 	"net/url"
 	"strings"
 
 	"github.com/google/uuid"
+	"github.com/lufeed/feed-parser-api/internal/fetch"
+	"github.com/lufeed/feed-parser-api/internal/images"
 	"github.com/lufeed/feed-parser-api/internal/logger"
 	"github.com/lufeed/feed-parser-api/internal/models"
 	"github.com/lufeed/feed-parser-api/internal/opengraph"
+	"github.com/lufeed/feed-parser-api/internal/placeholder"
+	"github.com/lufeed/feed-parser-api/internal/platform"
 	"github.com/lufeed/feed-parser-api/internal/proxy"
+	"github.com/lufeed/feed-parser-api/internal/scrape"
+	"github.com/lufeed/feed-parser-api/internal/topics"
 	"github.com/mmcdole/gofeed"
 	"go.uber.org/zap"
 )
@@ -35,21 +40,46 @@ func NewURLParser(ctx context.Context, pm *proxy.Manager) *URLParser {
 type SourceHandler func(source models.Source)
 
 func (p *URLParser) Exec(sourceUrl string, sendHTML bool, onSource SourceHandler) (models.Source, error) {
-	fp := gofeed.NewParser()
-	cl, proxyID := p.proxyManager.GetProxiedClient()
-	fp.UserAgent = browser.GetUserAgent()
-	fp.Client = cl
+	cl, proxyID, err := p.proxyManager.Acquire(p.ctx, sourceUrl)
+	if err != nil {
+		return models.Source{}, err
+	}
+	// Held until the home page is parsed as well
+	defer p.proxyManager.ReleaseProxy(proxyID)
 
 	logger.GetSugaredLogger().Infof("Parsing url %s", sourceUrl)
 
-	feed, err := fp.ParseURL(sourceUrl)
+	var feed *gofeed.Feed
+	var truncated bool
+	feedURL := sourceUrl
+
+	// Profile and channel URLs of known platforms are rewritten to the platform feed
+	adapter, profileURL, isPlatform := platform.Find(sourceUrl)
+	if isPlatform {
+		feedURL, err = adapter.FeedURL(cl, profileURL)
+		if err != nil {
+			logger.GetSugaredLogger().Warnf("Cannot resolve %s feed for URL: %s error: %s", adapter.Name(), sourceUrl, err.Error())
+			return models.Source{}, err
+		}
+	}
+
+	if recipe, ok := scrape.GetRecipeByURL(sourceUrl); ok {
+		feed, err = parseRecipeFeed(cl, recipe)
+	} else {
+		feed, truncated, err = fetchFeed(p.ctx, fetch.With(cl), feedURL)
+	}
+	if err != nil && isFeedTypeError(err) {
+		// Not RSS/Atom/JSON: accept sitemaps, directly or through robots.txt
+		feed, err = parseSitemapFeed(p.ctx, cl, sourceUrl)
+		if err != nil {
+			feed, feedURL, err = discoverSitemapFeed(p.ctx, cl, sourceUrl)
+		}
+	}
 	if err != nil {
 		logger.GetSugaredLogger().Warnf("Cannot parse URL: %s error: %s", sourceUrl, err.Error())
 		return models.Source{}, err
 	}
 
-	p.proxyManager.ReleaseProxy(proxyID)
-
 	id, err := uuid.NewUUID()
 	if err != nil {
 		return models.Source{}, err
@@ -59,23 +89,36 @@ func (p *URLParser) Exec(sourceUrl string, sendHTML bool, onSource SourceHandler
 		ID:          id,
 		Name:        strings.TrimSpace(html.UnescapeString(feed.Title)),
 		Description: feed.Description,
-		FeedURL:     sourceUrl,
+		FeedURL:     feedURL,
 		HomeURL:     strings.Split(feed.Link, "?")[0],
+		Truncated:   truncated,
 	}
 
 	opengraphExtractor := opengraph.NewExtractor(cl, newSource.HomeURL, newSource.HomeURL, true)
+	opengraphExtractor.SetContext(p.ctx)
 	wsi, err := opengraphExtractor.Exec()
 	if wsi.Description != "" {
 		newSource.Description = html.UnescapeString(wsi.Description)
 	} else {
 		newSource.Description = html.UnescapeString(feed.Title)
 	}
-	newSource.ImageURL = wsi.Image
-	if newSource.ImageURL == "" && feed.Image != nil && feed.Image.URL != "" {
-		newSource.ImageURL = feed.Image.URL
+	imageURL := wsi.Image
+	if imageURL == "" && feed.Image != nil && feed.Image.URL != "" {
+		imageURL = feed.Image.URL
+	}
+	imageURL = p.getImageUrl(cl, newSource.HomeURL, imageURL, "covers")
+	iconURL := p.getImageUrl(cl, newSource.HomeURL, wsi.Icon, "icons")
+
+	if isPlatform {
+		info, err := adapter.Metadata(cl, profileURL)
+		if err != nil {
+			logger.GetSugaredLogger().Debugf("Cannot get %s metadata for URL: %s error: %s", adapter.Name(), sourceUrl, err.Error())
+		}
+		newSource.Platform = &info
+		if info.AvatarURL != "" {
+			iconURL = p.getImageUrl(cl, newSource.HomeURL, info.AvatarURL, "icons")
+		}
 	}
-	newSource.ImageURL = p.getImageUrl(cl, newSource.HomeURL, newSource.ImageURL, "covers")
-	newSource.IconURL = p.getImageUrl(cl, newSource.HomeURL, wsi.Icon, "icons")
 
 	if newSource.Name == "" {
 		newSource.Name = strings.TrimSpace(wsi.Title)
@@ -84,16 +127,20 @@ func (p *URLParser) Exec(sourceUrl string, sendHTML bool, onSource SourceHandler
 		}
 	}
 
+	if analyzer := topics.GetAnalyzer(); analyzer != nil {
+		newSource.Topics = topics.SourceProfile(feedURL)
+		if len(newSource.Topics) == 0 {
+			newSource.Topics = analyzer.EstimateProfile(feed.Items)
+		}
+	}
+
 	if sendHTML {
 		newSource.HTML = &wsi.HTML
 	}
 
-	if newSource.ImageURL == "" {
-		newSource.ImageURL = "https://s3.eu-central-1.amazonaws.com/lufeed/sources/covers/lufeed-bg.png"
-	}
-	if newSource.IconURL == "" {
-		newSource.IconURL = "https://s3.eu-central-1.amazonaws.com/lufeed/sources/icons/lf-icon.png"
-	}
+	policy := placeholder.FromContext(p.ctx)
+	newSource.ImageURL, newSource.ImageIsPlaceholder = policy.SourceImageURL(imageURL)
+	newSource.IconURL, newSource.IconIsPlaceholder = policy.SourceIconURL(iconURL)
 
 	if onSource != nil {
 		onSource(newSource)
@@ -123,15 +170,10 @@ func (p *URLParser) getImageUrl(cl *http.Client, homeUrl, originalURL string, im
 			}
 		}
 
-		// Test image URL before downloading
-		headResp, err := cl.Head(imageURL)
-		if err != nil {
-			logger.GetSugaredLogger().With(zap.String("url", imageURL)).Errorf("Error checking image URL: %s", err.Error())
-			imageURL = ""
-		} else {
-			headResp.Body.Close()
-			if headResp.StatusCode != http.StatusOK {
-				logger.GetSugaredLogger().With(zap.String("url", imageURL), zap.Int("status", headResp.StatusCode)).Debugf("Image URL returned non-200 status code")
+		// Make sure the URL serves an actual image, not an error page
+		if imageURL != "" {
+			if _, err := images.Probe(p.ctx, cl, imageURL); err != nil {
+				logger.GetSugaredLogger().With(zap.String("url", imageURL)).Debugf("Image URL is not usable: %s", err.Error())
 				imageURL = ""
 			}
 		}
@@ -0,0 +1,149 @@
+package placeholder
+
+import (
+	"context"
+
+	"github.com/lufeed/feed-parser-api/internal/config"
+)
+
+// Modes of a placeholder policy
+const (
+	// ModeURL fills missing images with the configured placeholder URLs
+	ModeURL = "url"
+	// ModeNull leaves missing images null
+	ModeNull = "null"
+)
+
+// Placeholders used when none are configured, those served before placeholders
+// became configurable
+const (
+	defaultItemImage   = "https://s3.eu-central-1.amazonaws.com/lufeed/feeds/lufeed-bg.png"
+	defaultSourceImage = "https://s3.eu-central-1.amazonaws.com/lufeed/sources/covers/lufeed-bg.png"
+	defaultSourceIcon  = "https://s3.eu-central-1.amazonaws.com/lufeed/sources/icons/lf-icon.png"
+)
+
+type contextKey struct{}
+
+// Policy decides what missing item images, source covers and source icons are
+// replaced with. An empty placeholder URL leaves the image null in any mode.
+type Policy struct {
+	Null        bool
+	ItemImage   string
+	SourceImage string
+	SourceIcon  string
+}
+
+// Default returns the policy of the placeholders section of the configuration. URLs
+// it does not set are the built-in placeholders.
+func Default() Policy {
+	policy := Policy{
+		ItemImage:   defaultItemImage,
+		SourceImage: defaultSourceImage,
+		SourceIcon:  defaultSourceIcon,
+	}
+	cfg := config.GetConfig()
+	if cfg == nil {
+		return policy
+	}
+	return fromConfig(policy, cfg.Placeholders.PlaceholderPolicy)
+}
+
+// ForAPIKey returns the policy of the tenant owning apiKey, the default policy if
+// no tenant lists the key.
+func ForAPIKey(apiKey string) Policy {
+	policy := Default()
+	if apiKey == "" {
+		return policy
+	}
+	for _, tenant := range tenants() {
+		for _, key := range tenant.APIKeys {
+			if key == apiKey {
+				return fromConfig(policy, tenant.PlaceholderPolicy)
+			}
+		}
+	}
+	return policy
+}
+
+// ForTenant returns the policy of the named tenant, the default policy if there is
+// no such tenant.
+func ForTenant(name string) Policy {
+	policy := Default()
+	if name == "" {
+		return policy
+	}
+	for _, tenant := range tenants() {
+		if tenant.Name == name {
+			return fromConfig(policy, tenant.PlaceholderPolicy)
+		}
+	}
+	return policy
+}
+
+func tenants() []config.PlaceholderTenant {
+	if cfg := config.GetConfig(); cfg != nil {
+		return cfg.Placeholders.Tenants
+	}
+	return nil
+}
+
+// WithPolicy returns a copy of ctx carrying policy, for parsers to pick up.
+func WithPolicy(ctx context.Context, policy Policy) context.Context {
+	return context.WithValue(ctx, contextKey{}, policy)
+}
+
+// FromContext returns the policy carried by ctx, the default policy if there is none.
+func FromContext(ctx context.Context) Policy {
+	if ctx != nil {
+		if policy, ok := ctx.Value(contextKey{}).(Policy); ok {
+			return policy
+		}
+	}
+	return Default()
+}
+
+// ItemImageURL returns the image of an item: imageURL itself, or the placeholder when
+// it is empty. The second value reports whether the placeholder was used.
+func (p Policy) ItemImageURL(imageURL string) (*string, bool) {
+	return p.resolve(imageURL, p.ItemImage)
+}
+
+// SourceImageURL returns the cover of a source, or the placeholder when it is empty.
+func (p Policy) SourceImageURL(imageURL string) (*string, bool) {
+	return p.resolve(imageURL, p.SourceImage)
+}
+
+// SourceIconURL returns the icon of a source, or the placeholder when it is empty.
+func (p Policy) SourceIconURL(iconURL string) (*string, bool) {
+	return p.resolve(iconURL, p.SourceIcon)
+}
+
+func (p Policy) resolve(value, placeholder string) (*string, bool) {
+	if value != "" {
+		return &value, false
+	}
+	if p.Null || placeholder == "" {
+		return nil, true
+	}
+	return &placeholder, true
+}
+
+// fromConfig overrides base with the fields set in cfg.
+func fromConfig(base Policy, cfg config.PlaceholderPolicy) Policy {
+	switch cfg.Mode {
+	case ModeNull:
+		base.Null = true
+	case ModeURL:
+		base.Null = false
+	}
+	if cfg.ItemImage != "" {
+		base.ItemImage = cfg.ItemImage
+	}
+	if cfg.SourceImage != "" {
+		base.SourceImage = cfg.SourceImage
+	}
+	if cfg.SourceIcon != "" {
+		base.SourceIcon = cfg.SourceIcon
+	}
+	return base
+}
@@ -0,0 +1,113 @@
+package platform
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/lufeed/feed-parser-api/internal/models"
+)
+
+// githubAdapter maps repositories to their release feed and users or organizations to
+// their public activity feed.
+type githubAdapter struct{}
+
+type githubRepo struct {
+	FullName        string `json:"full_name"`
+	StargazersCount *int64 `json:"stargazers_count"`
+	Owner           struct {
+		AvatarURL string `json:"avatar_url"`
+	} `json:"owner"`
+}
+
+type githubUser struct {
+	Login     string `json:"login"`
+	AvatarURL string `json:"avatar_url"`
+	Followers *int64 `json:"followers"`
+}
+
+// Top level paths on github.com that are not user or organization names
+var githubReservedPaths = map[string]bool{
+	"about": true, "explore": true, "features": true, "marketplace": true, "orgs": true,
+	"pricing": true, "search": true, "settings": true, "sponsors": true, "topics": true,
+	"trending": true, "login": true, "notifications": true, "issues": true, "pulls": true,
+}
+
+func (a *githubAdapter) Name() string {
+	return "github"
+}
+
+func (a *githubAdapter) Match(u *url.URL) bool {
+	if !hostIs(u, "github.com") {
+		return false
+	}
+	segments := pathSegments(u)
+	if len(segments) == 0 || githubReservedPaths[strings.ToLower(segments[0])] {
+		return false
+	}
+	for _, s := range segments {
+		if strings.HasSuffix(s, ".atom") {
+			return false
+		}
+	}
+	return len(segments) <= 2 || segments[2] == "releases" || segments[2] == "tags" || segments[2] == "commits"
+}
+
+func (a *githubAdapter) FeedURL(cl *http.Client, u *url.URL) (string, error) {
+	segments := pathSegments(u)
+	if len(segments) == 1 {
+		return "https://github.com/" + segments[0] + ".atom", nil
+	}
+
+	repo := "https://github.com/" + segments[0] + "/" + strings.TrimSuffix(segments[1], ".git")
+	if len(segments) >= 3 {
+		switch segments[2] {
+		case "tags":
+			return repo + "/tags.atom", nil
+		case "commits":
+			branch := "HEAD"
+			if len(segments) >= 4 {
+				branch = segments[3]
+			}
+			return repo + "/commits/" + branch + ".atom", nil
+		}
+	}
+	return repo + "/releases.atom", nil
+}
+
+func (a *githubAdapter) Metadata(cl *http.Client, u *url.URL) (models.PlatformInfo, error) {
+	segments := pathSegments(u)
+	info := models.PlatformInfo{
+		Name:       a.Name(),
+		Handle:     segments[0],
+		ProfileURL: "https://github.com/" + segments[0],
+		AvatarURL:  "https://github.com/" + segments[0] + ".png",
+	}
+
+	if len(segments) == 1 {
+		var user githubUser
+		if err := getJSON(cl, "https://api.github.com/users/"+segments[0], &user); err != nil {
+			return info, err
+		}
+		if user.AvatarURL != "" {
+			info.AvatarURL = user.AvatarURL
+		}
+		info.SubscriberCount = user.Followers
+		return info, nil
+	}
+
+	name := strings.TrimSuffix(segments[1], ".git")
+	info.Handle = segments[0] + "/" + name
+	info.ProfileURL = "https://github.com/" + info.Handle
+
+	var repo githubRepo
+	if err := getJSON(cl, "https://api.github.com/repos/"+info.Handle, &repo); err != nil {
+		return info, err
+	}
+	if repo.Owner.AvatarURL != "" {
+		info.AvatarURL = repo.Owner.AvatarURL
+	}
+	// Stars are the closest thing to subscribers a repository has
+	info.SubscriberCount = repo.StargazersCount
+	return info, nil
+}
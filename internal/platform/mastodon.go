@@ -0,0 +1,63 @@
+package platform
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/lufeed/feed-parser-api/internal/models"
+)
+
+var mastodonPathRegex = regexp.MustCompile(`^/@([A-Za-z0-9_]+)/?$`)
+
+// mastodonAdapter maps profile URLs on any Mastodon (or compatible) instance to the
+// RSS feed the instance serves for them.
+type mastodonAdapter struct{}
+
+type mastodonAccount struct {
+	Acct           string `json:"acct"`
+	Avatar         string `json:"avatar"`
+	FollowersCount *int64 `json:"followers_count"`
+}
+
+func (a *mastodonAdapter) Name() string {
+	return "mastodon"
+}
+
+func (a *mastodonAdapter) Match(u *url.URL) bool {
+	// Other platforms using the same /@user profile path
+	if hostIs(u, "substack.com", "tiktok.com", "threads.net", "threads.com") {
+		return false
+	}
+	return mastodonPathRegex.MatchString(u.Path)
+}
+
+func (a *mastodonAdapter) FeedURL(cl *http.Client, u *url.URL) (string, error) {
+	return a.profileURL(u) + ".rss", nil
+}
+
+func (a *mastodonAdapter) Metadata(cl *http.Client, u *url.URL) (models.PlatformInfo, error) {
+	user := mastodonPathRegex.FindStringSubmatch(u.Path)[1]
+	info := models.PlatformInfo{
+		Name:       a.Name(),
+		Handle:     "@" + user + "@" + u.Host,
+		ProfileURL: a.profileURL(u),
+	}
+
+	var account mastodonAccount
+	lookupURL := "https://" + u.Host + "/api/v1/accounts/lookup?acct=" + url.QueryEscape(user)
+	if err := getJSON(cl, lookupURL, &account); err != nil {
+		return info, err
+	}
+	if account.Acct != "" && !strings.Contains(account.Acct, "@") {
+		info.Handle = "@" + account.Acct + "@" + u.Host
+	}
+	info.AvatarURL = account.Avatar
+	info.SubscriberCount = account.FollowersCount
+	return info, nil
+}
+
+func (a *mastodonAdapter) profileURL(u *url.URL) string {
+	return "https://" + u.Host + strings.TrimSuffix(u.Path, "/")
+}
@@ -0,0 +1,54 @@
+package platform
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/lufeed/feed-parser-api/internal/models"
+)
+
+// mediumAdapter maps Medium authors, publications and author subdomains to the
+// Medium RSS feeds.
+type mediumAdapter struct{}
+
+func (a *mediumAdapter) Name() string {
+	return "medium"
+}
+
+func (a *mediumAdapter) Match(u *url.URL) bool {
+	segments := pathSegments(u)
+	if hostIs(u, "medium.com") {
+		return len(segments) >= 1 && segments[0] != "feed" && segments[0] != "tag" && segments[0] != "m"
+	}
+	// Author subdomains such as someone.medium.com
+	return strings.HasSuffix(u.Host, ".medium.com") && (len(segments) == 0 || segments[0] != "feed")
+}
+
+func (a *mediumAdapter) FeedURL(cl *http.Client, u *url.URL) (string, error) {
+	return "https://medium.com/feed/" + a.handle(u), nil
+}
+
+func (a *mediumAdapter) Metadata(cl *http.Client, u *url.URL) (models.PlatformInfo, error) {
+	handle := a.handle(u)
+	info := models.PlatformInfo{
+		Name:       a.Name(),
+		Handle:     handle,
+		ProfileURL: "https://medium.com/" + handle,
+	}
+
+	body, err := getBody(cl, info.ProfileURL, "")
+	if err != nil {
+		return info, err
+	}
+	info.AvatarURL = metaContent(string(body), "og:image")
+	return info, nil
+}
+
+// handle returns "@author" for authors and the slug for publications.
+func (a *mediumAdapter) handle(u *url.URL) string {
+	if !hostIs(u, "medium.com") {
+		return "@" + strings.TrimSuffix(u.Host, ".medium.com")
+	}
+	return pathSegments(u)[0]
+}
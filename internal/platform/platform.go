@@ -0,0 +1,183 @@
+package platform
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lufeed/feed-parser-api/internal/cache"
+	"github.com/lufeed/feed-parser-api/internal/fetch"
+	"github.com/lufeed/feed-parser-api/internal/models"
+)
+
+const (
+	resolutionExpiration = 7 * 24 * time.Hour
+)
+
+// Adapter recognizes profile, channel or repository URLs of a platform and maps them
+// to the feed the platform publishes for them.
+type Adapter interface {
+	// Name identifies the platform, e.g. "youtube"
+	Name() string
+	// Match reports whether u is a URL shape handled by the adapter. Feed URLs produced
+	// by FeedURL must not match, so resolving is idempotent.
+	Match(u *url.URL) bool
+	// FeedURL returns the native feed of the profile at u, fetching the page if needed.
+	FeedURL(cl *http.Client, u *url.URL) (string, error)
+	// Metadata returns platform specific information about the profile at u.
+	Metadata(cl *http.Client, u *url.URL) (models.PlatformInfo, error)
+}
+
+var adapters []Adapter
+
+func init() {
+	Register(&youtubeAdapter{})
+	Register(&redditAdapter{})
+	Register(&githubAdapter{})
+	Register(&mediumAdapter{})
+	Register(&substackAdapter{})
+	// Mastodon matches any host with an /@user path, so it goes after Medium
+	Register(&mastodonAdapter{})
+}
+
+// Register adds an adapter to the registry. Adapters are consulted in registration order.
+func Register(a Adapter) {
+	adapters = append(adapters, a)
+}
+
+// Find returns the adapter handling rawURL, together with the parsed URL.
+func Find(rawURL string) (Adapter, *url.URL, bool) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || u.Host == "" {
+		return nil, nil, false
+	}
+	u.Host = strings.ToLower(u.Host)
+	for _, a := range adapters {
+		if a.Match(u) {
+			return a, u, true
+		}
+	}
+	return nil, nil, false
+}
+
+// ResolveFeedURL rewrites rawURL to the platform feed when an adapter recognizes it.
+// URLs no adapter handles are returned unchanged. Resolutions are cached since some
+// of them require fetching the profile page.
+func ResolveFeedURL(cl *http.Client, rawURL string) (string, error) {
+	a, u, ok := Find(rawURL)
+	if !ok {
+		return rawURL, nil
+	}
+
+	cacheKey := "platform_feed:" + u.String()
+	if cached, err := cache.GetCache(cacheKey); err == nil && cached != "" {
+		return cached, nil
+	}
+
+	feedURL, err := a.FeedURL(cl, u)
+	if err != nil {
+		return rawURL, fmt.Errorf("%s: %w", a.Name(), err)
+	}
+	cache.SetCache(cacheKey, feedURL, resolutionExpiration)
+	return feedURL, nil
+}
+
+func pathSegments(u *url.URL) []string {
+	var segments []string
+	for _, s := range strings.Split(u.Path, "/") {
+		if s != "" {
+			segments = append(segments, s)
+		}
+	}
+	return segments
+}
+
+func hostIs(u *url.URL, domains ...string) bool {
+	host := strings.TrimPrefix(u.Host, "www.")
+	host = strings.TrimPrefix(host, "m.")
+	for _, d := range domains {
+		if host == d {
+			return true
+		}
+	}
+	return false
+}
+
+func getBody(cl *http.Client, pageURL string, accept string) ([]byte, error) {
+	header := http.Header{}
+	if accept != "" {
+		header.Set("Accept", accept)
+	}
+	resp, err := fetch.With(cl).Do(context.Background(), fetch.Request{
+		URL:    pageURL,
+		Kind:   fetch.KindPage,
+		Header: header,
+		// Profile pages, API calls ask for what they accept
+		RequireHTML: accept == "",
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("received non-200 status code: %d", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func getJSON(cl *http.Client, apiURL string, v interface{}) error {
+	body, err := getBody(cl, apiURL, "application/json")
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+// parseCount turns abbreviated counts such as "1.2M" or "12,345" into a number.
+func parseCount(s string) (int64, bool) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	s = strings.ReplaceAll(s, ",", "")
+	if s == "" {
+		return 0, false
+	}
+
+	multiplier := 1.0
+	switch s[len(s)-1] {
+	case 'K':
+		multiplier = 1e3
+	case 'M':
+		multiplier = 1e6
+	case 'B':
+		multiplier = 1e9
+	}
+	if multiplier != 1 {
+		s = s[:len(s)-1]
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false
+	}
+	return int64(f * multiplier), true
+}
+
+// metaContent returns the content of the first <meta property|name="key"> in page.
+func metaContent(page string, key string) string {
+	re := regexp.MustCompile(`<meta[^>]+(?:property|name)="` + regexp.QuoteMeta(key) + `"[^>]+content="([^"]+)"`)
+	if m := re.FindStringSubmatch(page); len(m) == 2 {
+		return m[1]
+	}
+	re = regexp.MustCompile(`<meta[^>]+content="([^"]+)"[^>]+(?:property|name)="` + regexp.QuoteMeta(key) + `"`)
+	if m := re.FindStringSubmatch(page); len(m) == 2 {
+		return m[1]
+	}
+	return ""
+}
@@ -0,0 +1,254 @@
+package platform
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lufeed/feed-parser-api/internal/config"
+	"github.com/lufeed/feed-parser-api/internal/logger"
+	"github.com/lufeed/feed-parser-api/internal/models"
+)
+
+// fixtures maps the URLs the adapters fetch to the files of testdata served for them.
+var fixtures = map[string]string{
+	"www.youtube.com/@examplechannel":                    "youtube_channel.html",
+	"www.youtube.com/c/ExampleChannel":                   "youtube_channel.html",
+	"www.reddit.com/r/golang/about.json":                 "reddit_about.json",
+	"api.github.com/users/golang":                        "github_user.json",
+	"api.github.com/repos/golang/go":                     "github_repo.json",
+	"mastodon.example/api/v1/accounts/lookup?acct=alice": "mastodon_account.json",
+	"medium.com/@janewriter":                             "medium_profile.html",
+	"exampleletter.substack.com":                         "substack_home.html",
+}
+
+// rewriteTransport sends every request to the fixture server, keeping the original
+// host in a header.
+type rewriteTransport struct {
+	target *url.URL
+}
+
+func (t rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("X-Original-Host", req.URL.Host)
+	req.URL.Scheme = t.target.Scheme
+	req.URL.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// newFixtureClient returns a client answering from testdata, without network access.
+func newFixtureClient(t *testing.T) *http.Client {
+	t.Helper()
+	logger.Initialize(&config.AppConfig{})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("X-Original-Host") + strings.TrimSuffix(r.URL.Path, "/")
+		if r.URL.RawQuery != "" {
+			key += "?" + r.URL.RawQuery
+		}
+		name, ok := fixtures[key]
+		if !ok {
+			t.Errorf("unexpected request for %s", key)
+			http.NotFound(w, r)
+			return
+		}
+		body, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Error(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if strings.HasSuffix(name, ".json") {
+			w.Header().Set("Content-Type", "application/json")
+		} else {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		}
+		w.Write(body)
+	}))
+	t.Cleanup(srv.Close)
+
+	target, _ := url.Parse(srv.URL)
+	return &http.Client{Transport: rewriteTransport{target: target}}
+}
+
+func TestFind(t *testing.T) {
+	tests := []struct {
+		url     string
+		adapter string
+	}{
+		{"https://www.youtube.com/@examplechannel", "youtube"},
+		{"https://youtube.com/channel/UCabcdefghijklmnopqrstuv", "youtube"},
+		{"https://www.youtube.com/playlist?list=PL123", "youtube"},
+		{"https://www.youtube.com/user/legacyname", "youtube"},
+		{"https://m.youtube.com/c/ExampleChannel", "youtube"},
+		{"https://www.youtube.com/watch?v=abc", ""},
+		{"https://www.youtube.com/feeds/videos.xml?channel_id=UCabcdefghijklmnopqrstuv", ""},
+		{"https://www.reddit.com/r/golang", "reddit"},
+		{"https://old.reddit.com/u/spez/", "reddit"},
+		{"https://www.reddit.com/r/golang/.rss", ""},
+		{"https://www.reddit.com/r", ""},
+		{"https://github.com/golang", "github"},
+		{"https://github.com/golang/go", "github"},
+		{"https://github.com/golang/go/releases", "github"},
+		{"https://github.com/golang/go/issues", ""},
+		{"https://github.com/trending", ""},
+		{"https://github.com/golang/go/releases.atom", ""},
+		{"https://mastodon.example/@alice", "mastodon"},
+		{"https://mastodon.example/@alice.rss", ""},
+		{"https://substack.com/@someone", ""},
+		{"https://medium.com/@janewriter", "medium"},
+		{"https://janewriter.medium.com", "medium"},
+		{"https://medium.com/feed/@janewriter", ""},
+		{"https://exampleletter.substack.com", "substack"},
+		{"https://exampleletter.substack.com/feed", ""},
+		{"https://example.com/blog", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			a, _, ok := Find(tt.url)
+			name := ""
+			if ok {
+				name = a.Name()
+			}
+			if name != tt.adapter {
+				t.Errorf("Find(%q) = %q, want %q", tt.url, name, tt.adapter)
+			}
+		})
+	}
+}
+
+func TestFeedURL(t *testing.T) {
+	cl := newFixtureClient(t)
+	tests := []struct {
+		url  string
+		feed string
+	}{
+		{"https://www.youtube.com/channel/UCabcdefghijklmnopqrstuv", "https://www.youtube.com/feeds/videos.xml?channel_id=UCabcdefghijklmnopqrstuv"},
+		{"https://www.youtube.com/user/legacyname", "https://www.youtube.com/feeds/videos.xml?user=legacyname"},
+		{"https://www.youtube.com/playlist?list=PL123", "https://www.youtube.com/feeds/videos.xml?playlist_id=PL123"},
+		// Resolved through the channel page
+		{"https://www.youtube.com/@examplechannel", "https://www.youtube.com/feeds/videos.xml?channel_id=UCabcdefghijklmnopqrstuv"},
+		{"https://www.youtube.com/c/ExampleChannel/videos", "https://www.youtube.com/feeds/videos.xml?channel_id=UCabcdefghijklmnopqrstuv"},
+		{"https://www.reddit.com/r/golang", "https://www.reddit.com/r/golang/.rss"},
+		{"https://old.reddit.com/u/spez", "https://www.reddit.com/user/spez/.rss"},
+		{"https://github.com/golang", "https://github.com/golang.atom"},
+		{"https://github.com/golang/go", "https://github.com/golang/go/releases.atom"},
+		{"https://github.com/golang/go.git", "https://github.com/golang/go/releases.atom"},
+		{"https://github.com/golang/go/tags", "https://github.com/golang/go/tags.atom"},
+		{"https://github.com/golang/go/commits/master", "https://github.com/golang/go/commits/master.atom"},
+		{"https://mastodon.example/@alice/", "https://mastodon.example/@alice.rss"},
+		{"https://medium.com/@janewriter", "https://medium.com/feed/@janewriter"},
+		{"https://janewriter.medium.com/", "https://medium.com/feed/@janewriter"},
+		{"https://medium.com/some-publication", "https://medium.com/feed/some-publication"},
+		{"https://exampleletter.substack.com/archive", "https://exampleletter.substack.com/feed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			a, u, ok := Find(tt.url)
+			if !ok {
+				t.Fatalf("no adapter for %q", tt.url)
+			}
+			feed, err := a.FeedURL(cl, u)
+			if err != nil {
+				t.Fatalf("FeedURL(%q): %v", tt.url, err)
+			}
+			if feed != tt.feed {
+				t.Errorf("FeedURL(%q) = %q, want %q", tt.url, feed, tt.feed)
+			}
+			// Feed URLs are not matched again
+			if _, _, ok := Find(feed); ok {
+				t.Errorf("feed URL %q matches an adapter", feed)
+			}
+		})
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	cl := newFixtureClient(t)
+	count := func(n int64) *int64 { return &n }
+	tests := []struct {
+		url  string
+		want models.PlatformInfo
+	}{
+		{"https://www.youtube.com/@examplechannel", models.PlatformInfo{
+			Name:            "youtube",
+			Handle:          "@examplechannel",
+			ProfileURL:      "https://www.youtube.com/@examplechannel",
+			AvatarURL:       "https://yt3.googleusercontent.com/example-avatar=s900-c-k-c0x00ffffff-no-rj",
+			SubscriberCount: count(1200000),
+		}},
+		{"https://www.youtube.com/c/ExampleChannel", models.PlatformInfo{
+			Name:            "youtube",
+			Handle:          "@examplechannel",
+			ProfileURL:      "https://www.youtube.com/c/ExampleChannel",
+			AvatarURL:       "https://yt3.googleusercontent.com/example-avatar=s900-c-k-c0x00ffffff-no-rj",
+			SubscriberCount: count(1200000),
+		}},
+		{"https://www.reddit.com/r/golang", models.PlatformInfo{
+			Name:            "reddit",
+			Handle:          "r/golang",
+			ProfileURL:      "https://www.reddit.com/r/golang/",
+			AvatarURL:       "https://styles.redditmedia.com/t5_2rc7j/styles/communityIcon_example.png?width=256&s=abc123",
+			SubscriberCount: count(312456),
+		}},
+		{"https://github.com/golang", models.PlatformInfo{
+			Name:            "github",
+			Handle:          "golang",
+			ProfileURL:      "https://github.com/golang",
+			AvatarURL:       "https://avatars.githubusercontent.com/u/4314092?v=4",
+			SubscriberCount: count(5021),
+		}},
+		{"https://github.com/golang/go", models.PlatformInfo{
+			Name:            "github",
+			Handle:          "golang/go",
+			ProfileURL:      "https://github.com/golang/go",
+			AvatarURL:       "https://avatars.githubusercontent.com/u/4314092?v=4",
+			SubscriberCount: count(125000),
+		}},
+		{"https://mastodon.example/@alice", models.PlatformInfo{
+			Name:            "mastodon",
+			Handle:          "@alice@mastodon.example",
+			ProfileURL:      "https://mastodon.example/@alice",
+			AvatarURL:       "https://files.mastodon.example/accounts/avatars/109/302/original/alice.png",
+			SubscriberCount: count(842),
+		}},
+		{"https://janewriter.medium.com", models.PlatformInfo{
+			Name:       "medium",
+			Handle:     "@janewriter",
+			ProfileURL: "https://medium.com/@janewriter",
+			AvatarURL:  "https://miro.medium.com/v2/resize:fill:96:96/jane.jpeg",
+		}},
+		{"https://exampleletter.substack.com", models.PlatformInfo{
+			Name:       "substack",
+			Handle:     "exampleletter",
+			ProfileURL: "https://exampleletter.substack.com",
+			AvatarURL:  "https://substackcdn.com/image/fetch/w_256/example-letter-logo.png",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			a, u, ok := Find(tt.url)
+			if !ok {
+				t.Fatalf("no adapter for %q", tt.url)
+			}
+			got, err := a.Metadata(cl, u)
+			if err != nil {
+				t.Fatalf("Metadata(%q): %v", tt.url, err)
+			}
+			if got.Name != tt.want.Name || got.Handle != tt.want.Handle || got.ProfileURL != tt.want.ProfileURL || got.AvatarURL != tt.want.AvatarURL {
+				t.Errorf("Metadata(%q) = %+v, want %+v", tt.url, got, tt.want)
+			}
+			if (got.SubscriberCount == nil) != (tt.want.SubscriberCount == nil) ||
+				got.SubscriberCount != nil && *got.SubscriberCount != *tt.want.SubscriberCount {
+				t.Errorf("Metadata(%q) subscribers = %v, want %v", tt.url, got.SubscriberCount, tt.want.SubscriberCount)
+			}
+		})
+	}
+}
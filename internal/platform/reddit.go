@@ -0,0 +1,89 @@
+package platform
+
+import (
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/lufeed/feed-parser-api/internal/models"
+)
+
+// redditAdapter maps subreddit and user URLs to their RSS listings.
+type redditAdapter struct{}
+
+type redditAbout struct {
+	Data struct {
+		DisplayName   string `json:"display_name_prefixed"`
+		Name          string `json:"name"`
+		Subscribers   *int64 `json:"subscribers"`
+		IconImg       string `json:"icon_img"`
+		CommunityIcon string `json:"community_icon"`
+		Subreddit     *struct {
+			DisplayName string `json:"display_name_prefixed"`
+			Subscribers *int64 `json:"subscribers"`
+		} `json:"subreddit"`
+	} `json:"data"`
+}
+
+func (a *redditAdapter) Name() string {
+	return "reddit"
+}
+
+func (a *redditAdapter) Match(u *url.URL) bool {
+	if !hostIs(u, "reddit.com", "old.reddit.com", "new.reddit.com") {
+		return false
+	}
+	segments := pathSegments(u)
+	if len(segments) < 2 || strings.HasSuffix(u.Path, ".rss") {
+		return false
+	}
+	switch segments[0] {
+	case "r", "user", "u":
+		return true
+	}
+	return false
+}
+
+func (a *redditAdapter) FeedURL(cl *http.Client, u *url.URL) (string, error) {
+	return a.profileURL(u) + ".rss", nil
+}
+
+func (a *redditAdapter) Metadata(cl *http.Client, u *url.URL) (models.PlatformInfo, error) {
+	segments := pathSegments(u)
+	info := models.PlatformInfo{
+		Name:       a.Name(),
+		ProfileURL: a.profileURL(u),
+	}
+	if segments[0] == "r" {
+		info.Handle = "r/" + segments[1]
+	} else {
+		info.Handle = "u/" + segments[1]
+	}
+
+	var about redditAbout
+	if err := getJSON(cl, a.profileURL(u)+"about.json", &about); err != nil {
+		return info, err
+	}
+
+	info.SubscriberCount = about.Data.Subscribers
+	if about.Data.Subreddit != nil && info.SubscriberCount == nil {
+		info.SubscriberCount = about.Data.Subreddit.Subscribers
+	}
+	// Reddit HTML-escapes the query string of image URLs
+	info.AvatarURL = html.UnescapeString(about.Data.CommunityIcon)
+	if info.AvatarURL == "" {
+		info.AvatarURL = html.UnescapeString(about.Data.IconImg)
+	}
+	return info, nil
+}
+
+// profileURL returns the canonical listing URL with a trailing slash.
+func (a *redditAdapter) profileURL(u *url.URL) string {
+	segments := pathSegments(u)
+	kind := segments[0]
+	if kind == "u" {
+		kind = "user"
+	}
+	return "https://www.reddit.com/" + kind + "/" + segments[1] + "/"
+}
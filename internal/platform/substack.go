@@ -0,0 +1,43 @@
+package platform
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/lufeed/feed-parser-api/internal/models"
+)
+
+// substackAdapter maps publications on substack.com subdomains to their feed.
+type substackAdapter struct{}
+
+func (a *substackAdapter) Name() string {
+	return "substack"
+}
+
+func (a *substackAdapter) Match(u *url.URL) bool {
+	if !strings.HasSuffix(u.Host, ".substack.com") || u.Host == "www.substack.com" {
+		return false
+	}
+	segments := pathSegments(u)
+	return len(segments) == 0 || (segments[0] != "feed" && segments[0] != "api")
+}
+
+func (a *substackAdapter) FeedURL(cl *http.Client, u *url.URL) (string, error) {
+	return "https://" + u.Host + "/feed", nil
+}
+
+func (a *substackAdapter) Metadata(cl *http.Client, u *url.URL) (models.PlatformInfo, error) {
+	info := models.PlatformInfo{
+		Name:       a.Name(),
+		Handle:     strings.TrimSuffix(u.Host, ".substack.com"),
+		ProfileURL: "https://" + u.Host,
+	}
+
+	body, err := getBody(cl, info.ProfileURL, "")
+	if err != nil {
+		return info, err
+	}
+	info.AvatarURL = metaContent(string(body), "og:image")
+	return info, nil
+}
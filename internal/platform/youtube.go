@@ -0,0 +1,134 @@
+package platform
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/lufeed/feed-parser-api/internal/models"
+)
+
+const youtubeFeedURL = "https://www.youtube.com/feeds/videos.xml"
+
+var (
+	youtubeChannelIDRegex   = regexp.MustCompile(`^UC[\w-]{22}$`)
+	youtubePageChannelRegex = []*regexp.Regexp{
+		regexp.MustCompile(`<meta itemprop="(?:channelId|identifier)" content="(UC[\w-]{22})"`),
+		regexp.MustCompile(`<link rel="canonical" href="https://www\.youtube\.com/channel/(UC[\w-]{22})"`),
+		regexp.MustCompile(`"(?:channelId|externalId|browseId)":"(UC[\w-]{22})"`),
+	}
+	youtubeSubscribersRegex = regexp.MustCompile(`"(\d[\d.,]*\s*[KMB]?) subscribers"`)
+	youtubeHandleRegex      = regexp.MustCompile(`"canonicalBaseUrl":"/(@[^"]+)"`)
+)
+
+// youtubeAdapter maps channel, handle, legacy user and playlist URLs to the YouTube
+// Atom feeds.
+type youtubeAdapter struct{}
+
+func (a *youtubeAdapter) Name() string {
+	return "youtube"
+}
+
+func (a *youtubeAdapter) Match(u *url.URL) bool {
+	if !hostIs(u, "youtube.com") {
+		return false
+	}
+	if u.Path == "/playlist" && u.Query().Get("list") != "" {
+		return true
+	}
+	segments := pathSegments(u)
+	if len(segments) == 0 {
+		return false
+	}
+	if strings.HasPrefix(segments[0], "@") {
+		return true
+	}
+	switch segments[0] {
+	case "channel", "c", "user":
+		return len(segments) >= 2
+	}
+	return false
+}
+
+func (a *youtubeAdapter) FeedURL(cl *http.Client, u *url.URL) (string, error) {
+	if u.Path == "/playlist" {
+		return youtubeFeedURL + "?playlist_id=" + url.QueryEscape(u.Query().Get("list")), nil
+	}
+
+	segments := pathSegments(u)
+	switch {
+	case segments[0] == "channel" && youtubeChannelIDRegex.MatchString(segments[1]):
+		return youtubeFeedURL + "?channel_id=" + segments[1], nil
+	case segments[0] == "user":
+		return youtubeFeedURL + "?user=" + url.QueryEscape(segments[1]), nil
+	}
+
+	// Handles and custom URLs need the channel ID from the channel page
+	body, err := getBody(cl, a.profileURL(u), "")
+	if err != nil {
+		return "", err
+	}
+	channelID := youtubeChannelID(string(body))
+	if channelID == "" {
+		return "", fmt.Errorf("cannot find channel ID on %s", u.String())
+	}
+	return youtubeFeedURL + "?channel_id=" + channelID, nil
+}
+
+func (a *youtubeAdapter) Metadata(cl *http.Client, u *url.URL) (models.PlatformInfo, error) {
+	info := models.PlatformInfo{
+		Name:       a.Name(),
+		ProfileURL: a.profileURL(u),
+	}
+	segments := pathSegments(u)
+	if len(segments) > 0 && strings.HasPrefix(segments[0], "@") {
+		info.Handle = segments[0]
+	}
+
+	if u.Path == "/playlist" {
+		return info, nil
+	}
+
+	body, err := getBody(cl, info.ProfileURL, "")
+	if err != nil {
+		return info, err
+	}
+	return parseYoutubePage(string(body), info), nil
+}
+
+func (a *youtubeAdapter) profileURL(u *url.URL) string {
+	if u.Path == "/playlist" {
+		return "https://www.youtube.com/playlist?list=" + url.QueryEscape(u.Query().Get("list"))
+	}
+	segments := pathSegments(u)
+	if strings.HasPrefix(segments[0], "@") {
+		return "https://www.youtube.com/" + segments[0]
+	}
+	return "https://www.youtube.com/" + segments[0] + "/" + segments[1]
+}
+
+func youtubeChannelID(page string) string {
+	for _, re := range youtubePageChannelRegex {
+		if m := re.FindStringSubmatch(page); len(m) == 2 {
+			return m[1]
+		}
+	}
+	return ""
+}
+
+func parseYoutubePage(page string, info models.PlatformInfo) models.PlatformInfo {
+	info.AvatarURL = metaContent(page, "og:image")
+	if info.Handle == "" {
+		if m := youtubeHandleRegex.FindStringSubmatch(page); len(m) == 2 {
+			info.Handle = m[1]
+		}
+	}
+	if m := youtubeSubscribersRegex.FindStringSubmatch(page); len(m) == 2 {
+		if count, ok := parseCount(strings.ReplaceAll(m[1], " ", "")); ok {
+			info.SubscriberCount = &count
+		}
+	}
+	return info
+}
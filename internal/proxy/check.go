@@ -0,0 +1,115 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/lufeed/feed-parser-api/internal/logger"
+)
+
+// maxCheckBody is more than any IP echo service answers with
+const maxCheckBody = 4096
+
+// runHealthChecks probes the proxies against the check URL every interval. Each probe
+// verifies that the proxy answers and that it reaches sites from its own address:
+// the one configured for it, or at least not the one of the direct connection.
+func (m *Manager) runHealthChecks(checkURL string, interval, timeout time.Duration) {
+	direct := &http.Client{Transport: &http.Transport{DialContext: baseDialer().DialContext}}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		directIP, err := checkExitIP(direct, checkURL, timeout)
+		if err != nil {
+			logger.GetSugaredLogger().Debugf("Cannot check the direct exit IP: %s", err.Error())
+		}
+		for _, id := range m.health.due(time.Now()) {
+			m.checkProxy(id, checkURL, timeout, directIP)
+		}
+		<-ticker.C
+	}
+}
+
+func (m *Manager) checkProxy(id int, checkURL string, timeout time.Duration, directIP netip.Addr) {
+	m.mu.Lock()
+	var expected string
+	if e := m.endpoint(id); e != nil {
+		expected = e.ExitIP
+		if e.rotateSession(time.Now()) {
+			m.dropClient(id)
+		}
+	}
+	transport := m.getOrCreateTransport(id)
+	m.mu.Unlock()
+
+	exitIP, err := checkExitIP(&http.Client{Transport: transport}, checkURL, timeout)
+	switch {
+	case err != nil:
+	case expected != "" && exitIP.String() != normalizeIP(expected):
+		err = fmt.Errorf("exit IP %s instead of %s", exitIP, expected)
+	case expected == "" && directIP.IsValid() && exitIP == directIP:
+		err = fmt.Errorf("exit IP %s is the one of the direct connection", exitIP)
+	}
+	if err != nil {
+		m.health.recordCheck(id, "", err)
+		return
+	}
+	m.health.recordCheck(id, exitIP.String(), nil)
+}
+
+// checkExitIP asks checkURL which address the request comes from.
+func checkExitIP(cl *http.Client, checkURL string, timeout time.Duration) (netip.Addr, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, checkURL, nil)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	resp, err := cl.Do(req)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return netip.Addr{}, fmt.Errorf("check URL answered %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxCheckBody))
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	return parseExitIP(body)
+}
+
+// parseExitIP reads the address out of a plain text answer, or out of the ip or
+// origin field of a JSON one.
+func parseExitIP(body []byte) (netip.Addr, error) {
+	text := strings.TrimSpace(string(body))
+	var fields struct {
+		IP     string `json:"ip"`
+		Origin string `json:"origin"`
+	}
+	if json.Unmarshal(body, &fields) == nil {
+		text = fields.IP
+		if text == "" {
+			// httpbin lists the addresses the request went through
+			text, _, _ = strings.Cut(fields.Origin, ",")
+		}
+	}
+	addr, err := netip.ParseAddr(strings.TrimSpace(text))
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("no IP address in the check URL answer")
+	}
+	return addr.Unmap(), nil
+}
+
+func normalizeIP(ip string) string {
+	if addr, err := netip.ParseAddr(strings.TrimSpace(ip)); err == nil {
+		return addr.Unmap().String()
+	}
+	return ip
+}
@@ -0,0 +1,148 @@
+package proxy
+
+import (
+	"fmt"
+	"math/rand"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lufeed/feed-parser-api/internal/config"
+)
+
+const (
+	defaultSessionTTL  = 10 * time.Minute
+	sessionPlaceholder = "{session}"
+)
+
+var proxyTypes = []string{"http", "https", "socks5"}
+
+// endpoint is a configured proxy with its credentials resolved, counting the requests
+// it serves.
+type endpoint struct {
+	config.Proxy
+	url *url.URL
+	// username may hold placeholders, filled by proxyURL
+	username       string
+	password       string
+	maxConcurrency int
+	sessionTTL     time.Duration
+	// position is the index of the proxy in the configuration
+	position       int
+	inUse          int
+	lastUsed       time.Time
+	session        string
+	sessionExpires time.Time
+}
+
+func newEndpoint(p config.Proxy) (*endpoint, error) {
+	if p.ID <= 0 {
+		return nil, fmt.Errorf("proxy ID must be positive")
+	}
+	kind := strings.ToLower(strings.TrimSpace(p.Type))
+	if kind != "" && !slices.Contains(proxyTypes, kind) {
+		return nil, fmt.Errorf("unknown proxy type %q", p.Type)
+	}
+	raw := p.URL
+	if !strings.Contains(raw, "://") {
+		scheme := kind
+		if scheme == "" {
+			scheme = "http"
+		}
+		raw = scheme + "://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	if kind != "" {
+		u.Scheme = kind
+	}
+	if !slices.Contains(proxyTypes, u.Scheme) {
+		return nil, fmt.Errorf("unknown proxy type %q", u.Scheme)
+	}
+
+	e := &endpoint{
+		Proxy:          p,
+		url:            u,
+		maxConcurrency: max(p.MaxConcurrency, 1),
+		sessionTTL:     time.Duration(p.SessionTTL) * time.Second,
+	}
+	e.Type = u.Scheme
+	if e.sessionTTL <= 0 {
+		e.sessionTTL = defaultSessionTTL
+	}
+	if u.User != nil {
+		e.username = u.User.Username()
+		e.password, _ = u.User.Password()
+		u.User = nil
+	}
+	if e.username, err = secret(p.Username, p.UsernameEnv, p.UsernameFile, e.username); err != nil {
+		return nil, err
+	}
+	if e.password, err = secret(p.Password, p.PasswordEnv, p.PasswordFile, e.password); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// secret reads a credential from file, from the environment variable env or from
+// value, in that order, falling back to fallback.
+func secret(value, env, file, fallback string) (string, error) {
+	if file != "" {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("cannot read proxy credential: %w", err)
+		}
+		return strings.TrimSpace(string(b)), nil
+	}
+	if env != "" {
+		if v, ok := os.LookupEnv(env); ok {
+			return v, nil
+		}
+		return "", fmt.Errorf("proxy credential variable %s is not set", env)
+	}
+	if value != "" {
+		return value, nil
+	}
+	return fallback, nil
+}
+
+// sticky reports whether the username asks for sticky sessions.
+func (e *endpoint) sticky() bool {
+	return strings.Contains(e.username, sessionPlaceholder)
+}
+
+// rotateSession starts a new session once the current one is over, and reports
+// whether it did.
+func (e *endpoint) rotateSession(now time.Time) bool {
+	if !e.sticky() || (e.session != "" && now.Before(e.sessionExpires)) {
+		return false
+	}
+	e.session = strconv.FormatUint(rand.Uint64(), 36)
+	e.sessionExpires = now.Add(e.sessionTTL)
+	return true
+}
+
+// proxyURL returns the URL of the proxy with its credentials, the placeholders of the
+// username filled with the current session and the labels.
+func (e *endpoint) proxyURL() *url.URL {
+	u := *e.url
+	if e.username == "" && e.password == "" {
+		return &u
+	}
+	pairs := []string{sessionPlaceholder, e.session}
+	for name, value := range e.Labels {
+		pairs = append(pairs, "{"+name+"}", value)
+	}
+	username := strings.NewReplacer(pairs...).Replace(e.username)
+	if e.password == "" {
+		u.User = url.User(username)
+	} else {
+		u.User = url.UserPassword(username, e.password)
+	}
+	return &u
+}
@@ -0,0 +1,338 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lufeed/feed-parser-api/internal/config"
+	"github.com/lufeed/feed-parser-api/internal/logger"
+)
+
+const (
+	defaultCheckInterval    = 60 * time.Second
+	defaultCheckTimeout     = 10 * time.Second
+	defaultFailureThreshold = 3
+	defaultCooldown         = time.Minute
+	defaultMaxCooldown      = 30 * time.Minute
+	// healthDecay is the weight of the latest request in the error, block and latency
+	// averages
+	healthDecay = 0.2
+	// latencyScale is the latency halving the score of a proxy
+	latencyScale = 2 * time.Second
+	// minScore keeps poor proxies in the rotation, so that they can recover
+	minScore = 0.05
+)
+
+// outcome of a request through a proxy
+type outcome int
+
+const (
+	outcomeSuccess outcome = iota
+	// outcomeBlocked is a 403 or 429, the target may have banned the proxy
+	outcomeBlocked
+	// outcomeFailure is a proxy that could not be reached or refused the request
+	outcomeFailure
+)
+
+// Stats are the health and usage metrics of one proxy.
+type Stats struct {
+	ID               int               `json:"id"`
+	Type             string            `json:"type"`
+	Labels           map[string]string `json:"labels,omitempty"`
+	InUse            int               `json:"in_use"`
+	MaxConcurrency   int               `json:"max_concurrency"`
+	Score            float64           `json:"score"`
+	Requests         int64             `json:"requests"`
+	Failures         int64             `json:"failures"`
+	Blocked          int64             `json:"blocked"`
+	ErrorRate        float64           `json:"error_rate"`
+	BlockRate        float64           `json:"block_rate"`
+	LatencyMs        float64           `json:"latency_ms"`
+	Quarantined      bool              `json:"quarantined"`
+	QuarantinedUntil *time.Time        `json:"quarantined_until,omitempty"`
+	ExitIP           string            `json:"exit_ip,omitempty"`
+	LastCheck        *time.Time        `json:"last_check,omitempty"`
+	LastCheckError   string            `json:"last_check_error,omitempty"`
+}
+
+// health tracks how a proxy has been doing, from the requests sent through it and
+// from probes against the check URL.
+type health struct {
+	requests int64
+	failures int64
+	blocked  int64
+	// errorRate, blockRate and latency are moving averages
+	errorRate float64
+	blockRate float64
+	latency   time.Duration
+	// consecutive counts the failures since the last success
+	consecutive int
+	// quarantines counts the quarantines since the last success, each one doubling
+	// the cooldown
+	quarantines      int
+	quarantinedUntil time.Time
+	exitIP           string
+	lastCheck        time.Time
+	lastCheckError   string
+}
+
+func (h *health) score() float64 {
+	s := (1 - h.errorRate) * (1 - h.blockRate)
+	s /= 1 + float64(h.latency)/float64(latencyScale)
+	return math.Max(s, minScore)
+}
+
+func (h *health) quarantined(now time.Time) bool {
+	return now.Before(h.quarantinedUntil)
+}
+
+// healthTracker keeps the health of every proxy and quarantines those that keep
+// failing, for a cooldown doubling with every quarantine in a row.
+type healthTracker struct {
+	failureThreshold int
+	cooldown         time.Duration
+	maxCooldown      time.Duration
+
+	mu      sync.Mutex
+	proxies map[int]*health
+}
+
+func newHealthTracker(cfg config.ProxyHealthConfig, proxies []config.Proxy) *healthTracker {
+	t := &healthTracker{
+		failureThreshold: cfg.FailureThreshold,
+		cooldown:         time.Duration(cfg.Cooldown) * time.Second,
+		maxCooldown:      time.Duration(cfg.MaxCooldown) * time.Second,
+		proxies:          make(map[int]*health),
+	}
+	if t.failureThreshold <= 0 {
+		t.failureThreshold = defaultFailureThreshold
+	}
+	if t.cooldown <= 0 {
+		t.cooldown = defaultCooldown
+	}
+	if t.maxCooldown <= 0 {
+		t.maxCooldown = defaultMaxCooldown
+	}
+	for _, p := range proxies {
+		t.proxies[p.ID] = &health{}
+	}
+	return t
+}
+
+// record accounts the outcome of a request through proxy id.
+func (t *healthTracker) record(id int, o outcome, latency time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h, ok := t.proxies[id]
+	if !ok {
+		return
+	}
+
+	h.requests++
+	h.errorRate = average(h.errorRate, o == outcomeFailure)
+	h.blockRate = average(h.blockRate, o == outcomeBlocked)
+	switch o {
+	case outcomeFailure:
+		h.failures++
+		h.consecutive++
+		if h.consecutive >= t.failureThreshold {
+			t.quarantine(id, h, "too many failures")
+		}
+		return
+	case outcomeBlocked:
+		h.blocked++
+	}
+	h.consecutive = 0
+	h.quarantines = 0
+	if h.latency == 0 {
+		h.latency = latency
+	} else {
+		h.latency = time.Duration((1-healthDecay)*float64(h.latency) + healthDecay*float64(latency))
+	}
+}
+
+// recordCheck accounts a probe of proxy id. A successful probe lifts its quarantine,
+// a failed one quarantines it.
+func (t *healthTracker) recordCheck(id int, exitIP string, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h, ok := t.proxies[id]
+	if !ok {
+		return
+	}
+
+	h.lastCheck = time.Now()
+	if err != nil {
+		h.lastCheckError = err.Error()
+		t.quarantine(id, h, err.Error())
+		return
+	}
+	h.lastCheckError = ""
+	h.exitIP = exitIP
+	h.consecutive = 0
+	h.quarantines = 0
+	h.quarantinedUntil = time.Time{}
+}
+
+func (t *healthTracker) quarantine(id int, h *health, reason string) {
+	cooldown := t.cooldown << min(h.quarantines, 16)
+	if cooldown > t.maxCooldown || cooldown <= 0 {
+		cooldown = t.maxCooldown
+	}
+	h.quarantines++
+	h.consecutive = 0
+	h.quarantinedUntil = time.Now().Add(cooldown)
+	logger.GetSugaredLogger().Warnf("Quarantining proxy %d for %s: %s", id, cooldown, reason)
+}
+
+// healthState is what proxy selection needs of the health of a proxy.
+type healthState struct {
+	quarantined bool
+	score       float64
+	latency     time.Duration
+}
+
+func (t *healthTracker) state(id int, now time.Time) healthState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h, ok := t.proxies[id]
+	if !ok {
+		return healthState{score: 1}
+	}
+	return healthState{quarantined: h.quarantined(now), score: h.score(), latency: h.latency}
+}
+
+// due returns the proxies to probe. Quarantined proxies are left alone until their
+// cooldown is over, the probe then tells whether they are back.
+func (t *healthTracker) due(now time.Time) []int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var ids []int
+	for id, h := range t.proxies {
+		if !h.quarantined(now) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func (t *healthTracker) stats() []Stats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	stats := make([]Stats, 0, len(t.proxies))
+	for id, h := range t.proxies {
+		hs := Stats{
+			ID:             id,
+			Score:          h.score(),
+			Requests:       h.requests,
+			Failures:       h.failures,
+			Blocked:        h.blocked,
+			ErrorRate:      h.errorRate,
+			BlockRate:      h.blockRate,
+			LatencyMs:      float64(h.latency.Microseconds()) / 1000,
+			Quarantined:    h.quarantined(now),
+			ExitIP:         h.exitIP,
+			LastCheckError: h.lastCheckError,
+		}
+		if hs.Quarantined {
+			until := h.quarantinedUntil
+			hs.QuarantinedUntil = &until
+		}
+		if !h.lastCheck.IsZero() {
+			lastCheck := h.lastCheck
+			hs.LastCheck = &lastCheck
+		}
+		stats = append(stats, hs)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].ID < stats[j].ID })
+	return stats
+}
+
+func average(avg float64, hit bool) float64 {
+	v := 0.0
+	if hit {
+		v = 1
+	}
+	return (1-healthDecay)*avg + healthDecay*v
+}
+
+// healthTransport reports the outcome of every request through a proxy to the
+// tracker.
+type healthTransport struct {
+	base    http.RoundTripper
+	id      int
+	tracker *healthTracker
+}
+
+func (t *healthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		// Failures of the target say nothing about the proxy, every proxy would be
+		// charged for a dead site otherwise
+		if isProxyFailure(err) {
+			t.tracker.record(t.id, outcomeFailure, 0)
+		}
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusProxyAuthRequired:
+		t.tracker.record(t.id, outcomeFailure, 0)
+	case http.StatusForbidden, http.StatusTooManyRequests:
+		t.tracker.record(t.id, outcomeBlocked, time.Since(start))
+	default:
+		t.tracker.record(t.id, outcomeSuccess, time.Since(start))
+	}
+	return resp, nil
+}
+
+// connectError is a proxy refusing to open a tunnel to the target.
+type connectError struct {
+	statusCode int
+	status     string
+}
+
+func (e *connectError) Error() string {
+	return "proxy refused to connect: " + e.status
+}
+
+// checkConnect fails tunnels the proxy refused with a connectError, which tells its
+// status.
+func checkConnect(_ context.Context, _ *url.URL, _ *http.Request, resp *http.Response) error {
+	if resp.StatusCode != http.StatusOK {
+		return &connectError{statusCode: resp.StatusCode, status: resp.Status}
+	}
+	return nil
+}
+
+// isProxyFailure reports whether err is about the proxy rather than the target: the
+// proxy could not be reached, refused the credentials, or answered the tunnel with a
+// gateway error of its own. Timeouts, TLS errors and refusals of the target are not,
+// and neither are requests the caller gave up.
+func isProxyFailure(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	// net/http wraps the errors of the connection to a proxy this way
+	var op *net.OpError
+	if errors.As(err, &op) && op.Op == "proxyconnect" {
+		return true
+	}
+	var refused *connectError
+	if errors.As(err, &refused) {
+		switch refused.statusCode {
+		case http.StatusProxyAuthRequired, http.StatusBadGateway, http.StatusGatewayTimeout:
+			return true
+		}
+	}
+	return false
+}
@@ -1,14 +1,20 @@
 package proxy
 
 import (
-	"math/rand"
+	"context"
+	"fmt"
 	"net"
 	"net/http"
-	"net/url"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/lufeed/feed-parser-api/internal/breaker"
 	"github.com/lufeed/feed-parser-api/internal/config"
+	"github.com/lufeed/feed-parser-api/internal/robots"
+	"github.com/lufeed/feed-parser-api/internal/scheduler"
+	"github.com/lufeed/feed-parser-api/internal/ssrf"
 
 	"github.com/lufeed/feed-parser-api/internal/logger"
 )
@@ -18,50 +24,142 @@ var (
 	manager *Manager
 )
 
+// Manager hands out proxied clients. Each proxy is a counting semaphore serving up
+// to its max_concurrency requests at once.
 type Manager struct {
-	proxies     []config.Proxy
-	occupiedMap map[int]bool
-	clientPool  map[int]*http.Client
+	proxies       []*endpoint
+	clientPool    map[int]*http.Client
 	transportPool map[int]*http.Transport
-	mu          sync.RWMutex
-	baseTimeout time.Duration
+	health        *healthTracker
+	selector      *selector
+	queue         queue
+	// direct is the policy of direct connections
+	direct         string
+	acquireTimeout time.Duration
+	mu             sync.RWMutex
+	baseTimeout    time.Duration
 }
 
 func NewManager(cfg *config.AppConfig) *Manager {
 	once.Do(func() {
-		occupiedMap := make(map[int]bool)
-		for _, p := range cfg.Proxy.Proxies {
-			occupiedMap[p.ID] = false
-		}
-		manager = &Manager{
-			proxies:       cfg.Proxy.Proxies,
-			occupiedMap:   occupiedMap,
-			clientPool:    make(map[int]*http.Client),
-			transportPool: make(map[int]*http.Transport),
-			baseTimeout:   60 * time.Second,
-		}
+		manager = newManager(cfg)
 	})
 	return manager
 }
 
-func (m *Manager) GetProxiedClient() (*http.Client, int) {
+// newManager builds a manager of the proxies of cfg, NewManager keeps one per process.
+func newManager(cfg *config.AppConfig) *Manager {
+	var proxies []*endpoint
+	var valid []config.Proxy
+	for _, p := range cfg.Proxy.Proxies {
+		e, err := newEndpoint(p)
+		if err != nil {
+			logger.GetSugaredLogger().Errorf("Ignoring proxy %d: %s", p.ID, err.Error())
+			continue
+		}
+		e.position = len(proxies)
+		proxies = append(proxies, e)
+		valid = append(valid, p)
+	}
+	m := &Manager{
+		proxies:        proxies,
+		clientPool:     make(map[int]*http.Client),
+		transportPool:  make(map[int]*http.Transport),
+		health:         newHealthTracker(cfg.Proxy.Health, valid),
+		selector:       newSelector(cfg.Proxy),
+		direct:         strings.ToLower(cfg.Proxy.Direct),
+		acquireTimeout: time.Duration(cfg.Proxy.AcquireTimeout) * time.Second,
+		baseTimeout:    60 * time.Second,
+	}
+	switch m.direct {
+	case DirectNever, DirectNoProxies, DirectOverflow:
+	case "":
+		m.direct = DirectNoProxies
+	default:
+		logger.GetSugaredLogger().Errorf("Unknown direct connection policy %q, using %s", cfg.Proxy.Direct, DirectNoProxies)
+		m.direct = DirectNoProxies
+	}
+	if m.acquireTimeout <= 0 {
+		m.acquireTimeout = defaultAcquireTimeout
+	}
+	if hc := cfg.Proxy.Health; hc.CheckURL != "" && len(proxies) > 0 {
+		interval := time.Duration(hc.Interval) * time.Second
+		if interval <= 0 {
+			interval = defaultCheckInterval
+		}
+		timeout := time.Duration(hc.Timeout) * time.Second
+		if timeout <= 0 {
+			timeout = defaultCheckTimeout
+		}
+		go m.runHealthChecks(hc.CheckURL, interval, timeout)
+	}
+	return m
+}
+
+// Acquire returns a client for requests to targetURL and the ID of its proxy, which
+// must be released. The proxy is picked by the selection of ctx, or else the one
+// configured for the domain of targetURL. When every suitable proxy is busy the
+// request waits in line until one is free, ctx is done or the acquire timeout is
+// over. The direct connection, ID 0, is only used as the direct policy allows. The
+// crawl delay of the site of targetURL is waited out first, without holding a proxy.
+func (m *Manager) Acquire(ctx context.Context, targetURL string) (*http.Client, int, error) {
+	if err := robots.WaitCrawlDelay(ctx, targetURL); err != nil {
+		return nil, 0, err
+	}
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	if len(m.proxies) == 0 {
+		if m.direct == DirectNever {
+			return nil, 0, &UnavailableError{Reason: "no proxies are configured and direct connections are disabled"}
+		}
 		logger.GetSugaredLogger().Warn("No proxies available, using direct connection")
-		return m.getOrCreateClient(0), 0
+		return m.getOrCreateClient(0), 0, nil
 	}
 
-	proxy := m.getNextWorkingProxy()
-	if proxy == nil {
-		logger.GetSugaredLogger().Warn("No working proxies available, using direct connection")
-		return m.getOrCreateClient(0), 0
+	host := targetHost(targetURL)
+	sel := m.selector.selection(ctx, host)
+	if !slices.ContainsFunc(m.proxies, func(e *endpoint) bool { return e.hasLabels(sel.Labels) }) {
+		if m.direct != DirectOverflow {
+			return nil, 0, &UnavailableError{Reason: fmt.Sprintf("no proxy carries the labels %v", sel.Labels)}
+		}
+		return m.directFallback(fmt.Sprintf("No proxy carries the labels %v", sel.Labels)), 0, nil
 	}
-	m.HoldProxy(proxy.ID)
 
-	logger.GetSugaredLogger().Infof("Using proxy: %d", proxy.ID)
-	return m.getOrCreateClient(proxy.ID), proxy.ID
+	if m.direct == DirectOverflow && len(m.queue.waiters) == 0 {
+		proxy := m.getNextWorkingProxy(sel, host)
+		if proxy == nil {
+			return m.directFallback("No working proxies available, all busy or quarantined"), 0, nil
+		}
+		m.take(proxy, time.Now())
+		m.queue.acquired(0)
+		logger.GetSugaredLogger().Infof("Using proxy: %d", proxy.ID)
+		return m.getOrCreateClient(proxy.ID), proxy.ID, nil
+	}
+
+	id, err := m.wait(ctx, sel, host)
+	if err != nil {
+		return nil, 0, err
+	}
+	logger.GetSugaredLogger().Infof("Using proxy: %d", id)
+	return m.getOrCreateClient(id), id, nil
+}
+
+func (m *Manager) directFallback(reason string) *http.Client {
+	m.queue.stats.DirectFallbacks++
+	logger.GetSugaredLogger().Warnf("%s, using direct connection", reason)
+	return m.getOrCreateClient(0)
+}
+
+// take holds a slot of proxy, starting a new session first if the current one is
+// over.
+func (m *Manager) take(proxy *endpoint, now time.Time) {
+	if proxy.rotateSession(now) {
+		// The username changed, connections of the previous session are of no use
+		m.dropClient(proxy.ID)
+	}
+	m.HoldProxy(proxy.ID)
 }
 
 func (m *Manager) getOrCreateClient(proxyID int) *http.Client {
@@ -69,10 +167,14 @@ func (m *Manager) getOrCreateClient(proxyID int) *http.Client {
 		return client
 	}
 
-	transport := m.getOrCreateTransport(proxyID)
+	var transport http.RoundTripper = m.getOrCreateTransport(proxyID)
+	proxied := proxyID > 0
+	if proxied {
+		transport = &healthTransport{base: transport, id: proxyID, tracker: m.health}
+	}
 	client := &http.Client{
 		Timeout:   m.baseTimeout,
-		Transport: transport,
+		Transport: ssrf.NewTransport(robots.NewTransport(breaker.NewTransport(scheduler.NewTransport(transport)), proxied), proxied),
 	}
 	m.clientPool[proxyID] = client
 	return client
@@ -85,57 +187,118 @@ func (m *Manager) getOrCreateTransport(proxyID int) *http.Transport {
 
 	transport := m.getBaseTransport()
 
-	if proxyID > 0 {
-		for _, proxy := range m.proxies {
-			if proxy.ID == proxyID {
-				proxyURL, err := url.Parse(proxy.URL)
-				if err != nil {
-					logger.GetSugaredLogger().Errorf("Failed to parse proxy URL for ID %d: %v", proxyID, err)
-					break
-				}
-				transport.Proxy = http.ProxyURL(proxyURL)
-				break
-			}
-		}
+	if proxy := m.endpoint(proxyID); proxy != nil {
+		// HTTP, HTTPS and SOCKS5 proxies alike, with their credentials in the URL
+		transport.Proxy = http.ProxyURL(proxy.proxyURL())
+		transport.OnProxyConnectResponse = checkConnect
+		// Connections go to the configured proxy, which may well be internal. Targets,
+		// redirects included, are resolved and checked by the SSRF transport before the
+		// request is sent
+		transport.DialContext = baseDialer().DialContext
 	}
 
 	m.transportPool[proxyID] = transport
 	return transport
 }
 
+// dropClient forgets the client of proxyID, so that the next one is built afresh.
+func (m *Manager) dropClient(proxyID int) {
+	if transport, exists := m.transportPool[proxyID]; exists {
+		transport.CloseIdleConnections()
+	}
+	delete(m.transportPool, proxyID)
+	delete(m.clientPool, proxyID)
+}
+
+func (m *Manager) endpoint(id int) *endpoint {
+	for _, e := range m.proxies {
+		if e.ID == id {
+			return e
+		}
+	}
+	return nil
+}
+
+// ReleaseProxy frees the slot of proxy id for the next request in line.
 func (m *Manager) ReleaseProxy(id int) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	m.occupiedMap[id] = false
+	if e := m.endpoint(id); e != nil && e.inUse > 0 {
+		e.inUse--
+		m.dispatch()
+	}
 }
 
+// HoldProxy takes one of the slots of proxy id, the caller holds the lock.
 func (m *Manager) HoldProxy(id int) {
-	m.occupiedMap[id] = true
+	if e := m.endpoint(id); e != nil {
+		e.inUse++
+		e.lastUsed = time.Now()
+	}
+}
+
+// Capacity returns the number of requests the proxies serve at once, 1 for the
+// direct connection when there are none.
+func (m *Manager) Capacity() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	capacity := 0
+	for _, e := range m.proxies {
+		capacity += e.maxConcurrency
+	}
+	return max(capacity, 1)
+}
+
+// getNextWorkingProxy picks a proxy carrying the labels of sel with a free slot out of
+// quarantine, by the strategy of sel.
+func (m *Manager) getNextWorkingProxy(sel Selection, host string) *endpoint {
+	now := time.Now()
+	candidates := make([]candidate, 0, len(m.proxies))
+	for _, e := range m.proxies {
+		if !e.hasLabels(sel.Labels) {
+			continue
+		}
+		state := m.health.state(e.ID, now)
+		if state.quarantined {
+			continue
+		}
+		candidates = append(candidates, candidate{
+			endpoint: e,
+			free:     e.inUse < e.maxConcurrency,
+			score:    state.score,
+			latency:  state.latency,
+		})
+	}
+	return m.selector.pick(sel.Strategy, candidates, host, now)
 }
 
-func (m *Manager) ProxyCount() int {
+// QueueStats returns the metrics of the wait for proxies.
+func (m *Manager) QueueStats() QueueStats {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-	return max(len(m.proxies), 1)
+	return m.queueStats()
 }
 
-func (m *Manager) getNextWorkingProxy() *config.Proxy {
-	pList := m.proxies
-	rand.Shuffle(len(pList), func(i, j int) { pList[i], pList[j] = pList[j], pList[i] })
-	for i := range len(m.proxies) {
-		if !m.occupiedMap[m.proxies[i].ID] {
-			return &m.proxies[i]
+// Stats returns the health and usage metrics of the proxies.
+func (m *Manager) Stats() []Stats {
+	stats := m.health.stats()
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for i := range stats {
+		if e := m.endpoint(stats[i].ID); e != nil {
+			stats[i].Type = e.Type
+			stats[i].Labels = e.Labels
+			stats[i].InUse = e.inUse
+			stats[i].MaxConcurrency = e.maxConcurrency
 		}
 	}
-	return nil
+	return stats
 }
 
 func (m *Manager) getBaseTransport() *http.Transport {
 	return &http.Transport{
-		DialContext: (&net.Dialer{
-			Timeout:   60 * time.Second,
-			KeepAlive: 60 * time.Second,
-		}).DialContext,
+		// Every connection is checked against the SSRF guard once DNS is resolved
+		DialContext:           ssrf.Get().Dialer(baseDialer()).DialContext,
 		TLSHandshakeTimeout:   60 * time.Second,
 		ResponseHeaderTimeout: 60 * time.Second,
 		ExpectContinueTimeout: 1 * time.Second,
@@ -146,6 +309,13 @@ func (m *Manager) getBaseTransport() *http.Transport {
 	}
 }
 
+func baseDialer() *net.Dialer {
+	return &net.Dialer{
+		Timeout:   60 * time.Second,
+		KeepAlive: 60 * time.Second,
+	}
+}
+
 func (m *Manager) CleanupIdleConnections() {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
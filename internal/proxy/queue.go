@@ -0,0 +1,200 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Policies of direct connections
+const (
+	// DirectNever always goes through a proxy
+	DirectNever = "never"
+	// DirectNoProxies goes direct only when no proxy is configured
+	DirectNoProxies = "no_proxies"
+	// DirectOverflow also goes direct when every proxy is busy
+	DirectOverflow = "overflow"
+)
+
+const (
+	defaultAcquireTimeout = 30 * time.Second
+	// recheckInterval is how often waiters look for proxies back from quarantine
+	recheckInterval = time.Second
+)
+
+type priorityKey struct{}
+
+// UnavailableError is returned when no proxy could be acquired and the direct
+// connection is not allowed.
+type UnavailableError struct {
+	Reason string
+	Waited time.Duration
+}
+
+func (e *UnavailableError) Error() string {
+	if e.Waited > 0 {
+		return fmt.Sprintf("no proxy available after %s: %s", e.Waited.Round(time.Millisecond), e.Reason)
+	}
+	return "no proxy available: " + e.Reason
+}
+
+// WithPriority returns a copy of ctx whose requests wait for a proxy with priority
+// p. Waiters of higher priority are served first, those of equal priority in order
+// of arrival. The default priority is 0.
+func WithPriority(ctx context.Context, p int) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+func priorityFromContext(ctx context.Context) int {
+	p, _ := ctx.Value(priorityKey{}).(int)
+	return p
+}
+
+// QueueStats are the metrics of the wait for proxies.
+type QueueStats struct {
+	Direct string `json:"direct"`
+	// Waiting is the number of requests waiting for a proxy
+	Waiting    int   `json:"waiting"`
+	MaxWaiting int   `json:"max_waiting"`
+	Acquired   int64 `json:"acquired"`
+	// Waited counts the acquisitions that had to wait, AvgWaitMs and MaxWaitMs are
+	// about them
+	Waited    int64   `json:"waited"`
+	AvgWaitMs float64 `json:"avg_wait_ms"`
+	MaxWaitMs float64 `json:"max_wait_ms"`
+	TimedOut  int64   `json:"timed_out"`
+	// DirectFallbacks counts the requests sent without a proxy although proxies are
+	// configured
+	DirectFallbacks int64 `json:"direct_fallbacks"`
+}
+
+// waiter is a request waiting for a proxy. ready receives the ID of the proxy whose
+// slot it was given.
+type waiter struct {
+	sel      Selection
+	host     string
+	priority int
+	seq      uint64
+	ready    chan int
+}
+
+// queue keeps the waiters in the order they are served.
+type queue struct {
+	waiters []*waiter
+	seq     uint64
+
+	stats      QueueStats
+	waitedTime time.Duration
+}
+
+func (q *queue) push(w *waiter) {
+	q.seq++
+	w.seq = q.seq
+	i, _ := slices.BinarySearchFunc(q.waiters, w, func(a, b *waiter) int {
+		if a.priority != b.priority {
+			return b.priority - a.priority
+		}
+		return int(a.seq - b.seq)
+	})
+	q.waiters = slices.Insert(q.waiters, i, w)
+	q.stats.MaxWaiting = max(q.stats.MaxWaiting, len(q.waiters))
+}
+
+// remove takes w out of the queue, and reports whether it was still waiting.
+func (q *queue) remove(w *waiter) bool {
+	i := slices.Index(q.waiters, w)
+	if i < 0 {
+		return false
+	}
+	q.waiters = slices.Delete(q.waiters, i, i+1)
+	return true
+}
+
+func (q *queue) acquired(waited time.Duration) {
+	q.stats.Acquired++
+	if waited <= 0 {
+		return
+	}
+	q.stats.Waited++
+	q.waitedTime += waited
+	q.stats.MaxWaitMs = max(q.stats.MaxWaitMs, float64(waited.Microseconds())/1000)
+}
+
+// dispatch hands the free proxies to the waiters in queue order. Waiters no free
+// proxy suits are skipped, so that they do not hold up those behind them.
+func (m *Manager) dispatch() {
+	now := time.Now()
+	for _, w := range slices.Clone(m.queue.waiters) {
+		proxy := m.getNextWorkingProxy(w.sel, w.host)
+		if proxy == nil {
+			continue
+		}
+		m.take(proxy, now)
+		m.queue.remove(w)
+		w.ready <- proxy.ID
+	}
+}
+
+// wait queues a request for a proxy until one is free, ctx is done or the acquire
+// timeout is over. The caller holds the lock, which is released while waiting.
+func (m *Manager) wait(ctx context.Context, sel Selection, host string) (int, error) {
+	w := &waiter{sel: sel, host: host, priority: priorityFromContext(ctx), ready: make(chan int, 1)}
+	m.queue.push(w)
+	m.dispatch()
+	if len(w.ready) > 0 {
+		m.queue.acquired(0)
+		return <-w.ready, nil
+	}
+
+	start := time.Now()
+	timer := time.NewTimer(m.acquireTimeout)
+	defer timer.Stop()
+	recheck := time.NewTicker(recheckInterval)
+	defer recheck.Stop()
+	for {
+		m.mu.Unlock()
+		var err error
+		id, granted := 0, false
+		select {
+		case id = <-w.ready:
+			granted = true
+		case <-recheck.C:
+		case <-timer.C:
+			err = &UnavailableError{Reason: "all proxies are busy or quarantined", Waited: time.Since(start)}
+		case <-ctx.Done():
+			err = &UnavailableError{Reason: ctx.Err().Error(), Waited: time.Since(start)}
+		}
+		m.mu.Lock()
+
+		if !granted && err != nil && !m.queue.remove(w) {
+			// Given a proxy in the meantime
+			id, granted = <-w.ready, true
+		}
+		if granted {
+			m.queue.acquired(time.Since(start))
+			return id, nil
+		}
+		if err != nil {
+			m.queue.stats.TimedOut++
+			return 0, err
+		}
+
+		// Proxies may be back from quarantine
+		m.dispatch()
+		if len(w.ready) > 0 {
+			m.queue.acquired(time.Since(start))
+			return <-w.ready, nil
+		}
+	}
+}
+
+func (m *Manager) queueStats() QueueStats {
+	stats := m.queue.stats
+	stats.Direct = m.direct
+	stats.Waiting = len(m.queue.waiters)
+	if stats.Waited > 0 {
+		stats.AvgWaitMs = float64(m.queue.waitedTime.Microseconds()) / 1000 / float64(stats.Waited)
+	}
+	return stats
+}
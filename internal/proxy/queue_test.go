@@ -0,0 +1,197 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lufeed/feed-parser-api/internal/config"
+	"github.com/lufeed/feed-parser-api/internal/logger"
+)
+
+const targetURL = "https://example.com/news"
+
+// newTestManager returns a manager of a single proxy serving one request at once,
+// which is never connected to.
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+	logger.Initialize(&config.AppConfig{})
+	return newManager(&config.AppConfig{Proxy: config.ProxyConfig{
+		Proxies: []config.Proxy{{ID: 1, URL: "http://127.0.0.1:3128", MaxConcurrency: 1}},
+		Health:  config.ProxyHealthConfig{Cooldown: 1},
+		Direct:  DirectNever,
+	}})
+}
+
+// acquire takes the proxy of m, failing the test when it cannot.
+func acquire(t *testing.T, ctx context.Context, m *Manager) int {
+	t.Helper()
+	_, id, err := m.Acquire(ctx, targetURL)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	return id
+}
+
+type grant struct {
+	name string
+	id   int
+	err  error
+}
+
+// waitInLine starts an Acquire in the background and returns once it is queued.
+func waitInLine(t *testing.T, ctx context.Context, m *Manager, name string, grants chan<- grant) {
+	t.Helper()
+	m.mu.RLock()
+	queued := len(m.queue.waiters)
+	m.mu.RUnlock()
+
+	go func() {
+		_, id, err := m.Acquire(ctx, targetURL)
+		grants <- grant{name: name, id: id, err: err}
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		m.mu.RLock()
+		n := len(m.queue.waiters)
+		m.mu.RUnlock()
+		if n > queued {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s did not wait in line", name)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestQueueOrder(t *testing.T) {
+	m := newTestManager(t)
+	ctx := context.Background()
+	holder := acquire(t, ctx, m)
+
+	grants := make(chan grant)
+	waiters := []struct {
+		name     string
+		priority int
+	}{
+		{"first", 0},
+		{"item", -1},
+		{"second", 0},
+		{"urgent", 1},
+		{"third", 0},
+	}
+	for _, w := range waiters {
+		waitInLine(t, WithPriority(ctx, w.priority), m, w.name, grants)
+	}
+
+	// Higher priorities first, equal ones in order of arrival
+	want := []string{"urgent", "first", "second", "third", "item"}
+	m.ReleaseProxy(holder)
+	for _, name := range want {
+		select {
+		case g := <-grants:
+			if g.err != nil {
+				t.Fatalf("%s: %v", g.name, g.err)
+			}
+			if g.name != name {
+				t.Fatalf("served %s, want %s", g.name, name)
+			}
+			m.ReleaseProxy(g.id)
+		case <-time.After(time.Second):
+			t.Fatalf("%s was not served", name)
+		}
+	}
+
+	stats := m.QueueStats()
+	if stats.Acquired != 6 || stats.Waited != 5 || stats.MaxWaiting != 5 || stats.Waiting != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestQueueTimeout(t *testing.T) {
+	m := newTestManager(t)
+	m.acquireTimeout = 50 * time.Millisecond
+	holder := acquire(t, context.Background(), m)
+
+	start := time.Now()
+	_, _, err := m.Acquire(context.Background(), targetURL)
+	var unavailable *UnavailableError
+	if !errors.As(err, &unavailable) {
+		t.Fatalf("Acquire = %v, want an UnavailableError", err)
+	}
+	if waited := time.Since(start); waited < m.acquireTimeout || unavailable.Waited < m.acquireTimeout {
+		t.Errorf("gave up after %v (%v reported), before the acquire timeout", waited, unavailable.Waited)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	grants := make(chan grant, 1)
+	waitInLine(t, ctx, m, "canceled", grants)
+	cancel()
+	if g := <-grants; !errors.As(g.err, &unavailable) {
+		t.Fatalf("Acquire with a canceled context = %v, want an UnavailableError", g.err)
+	}
+
+	stats := m.QueueStats()
+	if stats.TimedOut != 2 || stats.Waiting != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	// The waiters gave up without taking the slot
+	m.ReleaseProxy(holder)
+	if id := acquire(t, context.Background(), m); id != holder {
+		t.Errorf("acquired proxy %d, want %d", id, holder)
+	}
+}
+
+func TestQueueGrantedWhileGivingUp(t *testing.T) {
+	m := newTestManager(t)
+	holder := acquire(t, context.Background(), m)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	grants := make(chan grant, 1)
+	waitInLine(t, ctx, m, "waiter", grants)
+
+	// The waiter sees its context done, and is handed the proxy before it gets the
+	// lock back
+	m.mu.Lock()
+	cancel()
+	time.Sleep(20 * time.Millisecond)
+	m.endpoint(holder).inUse--
+	m.dispatch()
+	m.mu.Unlock()
+
+	g := <-grants
+	if g.err != nil || g.id != holder {
+		t.Fatalf("Acquire = %d, %v, want proxy %d", g.id, g.err, holder)
+	}
+	m.mu.RLock()
+	inUse := m.endpoint(holder).inUse
+	m.mu.RUnlock()
+	if inUse != 1 {
+		t.Errorf("proxy has %d requests, want 1", inUse)
+	}
+	if stats := m.QueueStats(); stats.TimedOut != 0 || stats.Waiting != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestQueueQuarantine(t *testing.T) {
+	m := newTestManager(t)
+	m.acquireTimeout = 5 * time.Second
+	m.health.recordCheck(1, "", errors.New("proxy is down"))
+
+	// Nothing releases the proxy, the waiter finds it when its quarantine is over
+	start := time.Now()
+	id := acquire(t, context.Background(), m)
+	if id != 1 {
+		t.Fatalf("acquired proxy %d, want 1", id)
+	}
+	if waited := time.Since(start); waited < 900*time.Millisecond {
+		t.Errorf("acquired after %v, before the quarantine was over", waited)
+	}
+	if stats := m.QueueStats(); stats.Waited != 1 || stats.TimedOut != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
@@ -0,0 +1,274 @@
+package proxy
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lufeed/feed-parser-api/internal/config"
+	"github.com/lufeed/feed-parser-api/internal/logger"
+)
+
+// Strategy picks a proxy among those with a free slot.
+type Strategy string
+
+const (
+	// StrategyWeighted picks at random weighted by health score
+	StrategyWeighted Strategy = "weighted"
+	// StrategyRoundRobin takes the proxies in turn
+	StrategyRoundRobin Strategy = "round_robin"
+	// StrategyLeastRecent takes the proxy unused for the longest time
+	StrategyLeastRecent Strategy = "least_recent"
+	// StrategyLeastLoaded takes the proxy with the smallest share of its slots in use
+	StrategyLeastLoaded Strategy = "least_loaded"
+	// StrategyLeastLatency takes the fastest proxy
+	StrategyLeastLatency Strategy = "least_latency"
+	// StrategySticky keeps sending a host through the same proxy, so that cookies and
+	// sessions stay consistent
+	StrategySticky Strategy = "sticky"
+)
+
+const (
+	defaultStickyTTL = 30 * time.Minute
+	// maxStickyHosts is the number of sticky hosts kept before expired ones are pruned
+	maxStickyHosts = 1024
+)
+
+var strategies = []Strategy{StrategyWeighted, StrategyRoundRobin, StrategyLeastRecent, StrategyLeastLoaded, StrategyLeastLatency, StrategySticky}
+
+type selectionKey struct{}
+
+// Selection narrows down the proxies a request may go through and how one is picked.
+type Selection struct {
+	Strategy Strategy `json:"strategy,omitempty"`
+	// Labels the proxy must carry, such as a country for region-locked sites or a group
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// Validate checks the strategy of s.
+func (s Selection) Validate() error {
+	if s.Strategy != "" && !slices.Contains(strategies, s.Strategy) {
+		return fmt.Errorf("unknown proxy strategy %q", s.Strategy)
+	}
+	return nil
+}
+
+// WithSelection returns a copy of ctx carrying s, which overrides the configured
+// selection of the requests made with it.
+func WithSelection(ctx context.Context, s Selection) context.Context {
+	return context.WithValue(ctx, selectionKey{}, s)
+}
+
+// WithRequestSelection returns a copy of ctx carrying the selection a request asked
+// for, once validated. Requests without one get ctx itself.
+func WithRequestSelection(ctx context.Context, sel *Selection) (context.Context, error) {
+	if sel == nil {
+		return ctx, nil
+	}
+	if err := sel.Validate(); err != nil {
+		return ctx, err
+	}
+	return WithSelection(ctx, *sel), nil
+}
+
+func selectionFromContext(ctx context.Context) (Selection, bool) {
+	s, ok := ctx.Value(selectionKey{}).(Selection)
+	return s, ok
+}
+
+type stickyHost struct {
+	id      int
+	expires time.Time
+}
+
+// selector holds the configured selections and the state of the strategies.
+type selector struct {
+	defaults  Selection
+	domains   map[string]Selection
+	stickyTTL time.Duration
+
+	// last is the position of the proxy round_robin took last
+	last   int
+	sticky map[string]stickyHost
+}
+
+func newSelector(cfg config.ProxyConfig) *selector {
+	s := &selector{
+		defaults:  Selection{Strategy: Strategy(strings.ToLower(cfg.Strategy))},
+		domains:   make(map[string]Selection),
+		stickyTTL: time.Duration(cfg.StickyTTL) * time.Second,
+		last:      -1,
+		sticky:    make(map[string]stickyHost),
+	}
+	if err := s.defaults.Validate(); err != nil {
+		logger.GetSugaredLogger().Errorf("%s, using %s", err.Error(), StrategyWeighted)
+		s.defaults.Strategy = ""
+	}
+	if s.defaults.Strategy == "" {
+		s.defaults.Strategy = StrategyWeighted
+	}
+	if s.stickyTTL <= 0 {
+		s.stickyTTL = defaultStickyTTL
+	}
+	for _, d := range cfg.Domains {
+		sel := Selection{Strategy: Strategy(strings.ToLower(d.Strategy)), Labels: d.Labels}
+		if err := sel.Validate(); err != nil {
+			logger.GetSugaredLogger().Errorf("Ignoring proxy strategy of %s: %s", d.Domain, err.Error())
+			sel.Strategy = ""
+		}
+		s.domains[normalizeHost(d.Domain)] = sel
+	}
+	return s
+}
+
+// selection returns the selection of a request to host: the one of ctx, else the one
+// of the domain, completed by the defaults.
+func (s *selector) selection(ctx context.Context, host string) Selection {
+	sel, ok := selectionFromContext(ctx)
+	if !ok {
+		sel = s.domain(host)
+	}
+	if sel.Strategy == "" {
+		sel.Strategy = s.defaults.Strategy
+	}
+	return sel
+}
+
+// domain returns the selection of host, those of a parent domain apply to its
+// subdomains.
+func (s *selector) domain(host string) Selection {
+	for d := host; d != ""; {
+		if sel, ok := s.domains[d]; ok {
+			return sel
+		}
+		_, parent, found := strings.Cut(d, ".")
+		if !found {
+			break
+		}
+		d = parent
+	}
+	return Selection{}
+}
+
+// candidate is a proxy out of quarantine carrying the labels of a selection.
+type candidate struct {
+	*endpoint
+	free    bool
+	score   float64
+	latency time.Duration
+}
+
+// pick chooses among the free candidates for a request to host, nil if none is free.
+func (s *selector) pick(strategy Strategy, candidates []candidate, host string, now time.Time) *endpoint {
+	var free []candidate
+	for _, c := range candidates {
+		if c.free {
+			free = append(free, c)
+		}
+	}
+
+	if strategy == StrategySticky && host != "" {
+		if sh, ok := s.sticky[host]; ok && now.Before(sh.expires) {
+			i := slices.IndexFunc(candidates, func(c candidate) bool { return c.ID == sh.id })
+			switch {
+			case i >= 0 && candidates[i].free:
+				s.sticky[host] = stickyHost{id: sh.id, expires: now.Add(s.stickyTTL)}
+				return candidates[i].endpoint
+			case i >= 0:
+				// Busy for now, the host stays with it
+				if len(free) == 0 {
+					return nil
+				}
+				return pickWeighted(free)
+			}
+		}
+		if len(free) == 0 {
+			return nil
+		}
+		e := pickWeighted(free)
+		s.stick(host, e.ID, now)
+		return e
+	}
+
+	if len(free) == 0 {
+		return nil
+	}
+	switch strategy {
+	case StrategyRoundRobin:
+		// Turns go by configuration order, from the proxy after the one taken last
+		i := slices.IndexFunc(free, func(c candidate) bool { return c.position > s.last })
+		if i < 0 {
+			i = 0
+		}
+		s.last = free[i].position
+		return free[i].endpoint
+	case StrategyLeastRecent:
+		return slices.MinFunc(free, func(a, b candidate) int {
+			return a.lastUsed.Compare(b.lastUsed)
+		}).endpoint
+	case StrategyLeastLoaded:
+		return slices.MinFunc(free, func(a, b candidate) int {
+			// Compares the shares of slots in use without dividing
+			return cmp.Compare(a.inUse*b.maxConcurrency, b.inUse*a.maxConcurrency)
+		}).endpoint
+	case StrategyLeastLatency:
+		return slices.MinFunc(free, func(a, b candidate) int {
+			return cmp.Compare(a.latency, b.latency)
+		}).endpoint
+	}
+	return pickWeighted(free)
+}
+
+// stick keeps host on proxy id. Expired hosts are pruned once there are many.
+func (s *selector) stick(host string, id int, now time.Time) {
+	if _, ok := s.sticky[host]; !ok && len(s.sticky) >= maxStickyHosts {
+		for h, sh := range s.sticky {
+			if !now.Before(sh.expires) {
+				delete(s.sticky, h)
+			}
+		}
+	}
+	s.sticky[host] = stickyHost{id: id, expires: now.Add(s.stickyTTL)}
+}
+
+func pickWeighted(candidates []candidate) *endpoint {
+	total := 0.0
+	for _, c := range candidates {
+		total += c.score
+	}
+	r := rand.Float64() * total
+	for _, c := range candidates {
+		r -= c.score
+		if r < 0 {
+			return c.endpoint
+		}
+	}
+	return candidates[len(candidates)-1].endpoint
+}
+
+// hasLabels reports whether e carries all of labels.
+func (e *endpoint) hasLabels(labels map[string]string) bool {
+	for name, value := range labels {
+		if !strings.EqualFold(e.Labels[name], value) {
+			return false
+		}
+	}
+	return true
+}
+
+func targetHost(targetURL string) string {
+	u, err := url.Parse(targetURL)
+	if err != nil {
+		return ""
+	}
+	return normalizeHost(u.Hostname())
+}
+
+func normalizeHost(hostname string) string {
+	return strings.TrimPrefix(strings.ToLower(strings.TrimSpace(hostname)), "www.")
+}
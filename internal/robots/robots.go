@@ -0,0 +1,172 @@
+package robots
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRobotsSize is how much of a robots.txt is read, RFC 9309 asks for at least 500 KiB
+const maxRobotsSize = 512 * 1024
+
+// Rules are the rules of a robots.txt that apply to one agent.
+type Rules struct {
+	rules      []rule
+	crawlDelay time.Duration
+	// disallowAll is set when robots.txt could not be fetched because of a server
+	// error, which RFC 9309 treats as a complete disallow
+	disallowAll bool
+}
+
+type rule struct {
+	allow   bool
+	pattern string
+	re      *regexp.Regexp
+}
+
+type group struct {
+	agents     []string
+	rules      []rule
+	crawlDelay time.Duration
+	hasDelay   bool
+}
+
+// AllowAll are the rules of a site without robots.txt.
+func AllowAll() *Rules {
+	return &Rules{}
+}
+
+// DisallowAll are the rules of a site whose robots.txt is unreachable.
+func DisallowAll() *Rules {
+	return &Rules{disallowAll: true}
+}
+
+// Parse reads a robots.txt and keeps the groups that apply to agent, or the groups
+// for * when none names it.
+func Parse(r io.Reader, agent string) *Rules {
+	agent = strings.ToLower(agent)
+
+	var groups []*group
+	var current *group
+	// Consecutive user-agent lines share a group
+	inAgents := false
+
+	scanner := bufio.NewScanner(io.LimitReader(r, maxRobotsSize))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if !inAgents {
+				current = &group{}
+				groups = append(groups, current)
+				inAgents = true
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+			continue
+		case "allow", "disallow":
+			// An empty disallow allows everything, which is the default anyway
+			if current != nil && value != "" {
+				current.rules = append(current.rules, newRule(key == "allow", value))
+			}
+		case "crawl-delay":
+			if current != nil {
+				if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+					current.crawlDelay = time.Duration(seconds * float64(time.Second))
+					current.hasDelay = true
+				}
+			}
+		}
+		inAgents = false
+	}
+
+	rules := &Rules{}
+	for _, wildcard := range []bool{false, true} {
+		for _, g := range groups {
+			if !g.matches(agent, wildcard) {
+				continue
+			}
+			rules.rules = append(rules.rules, g.rules...)
+			if g.hasDelay && g.crawlDelay > rules.crawlDelay {
+				rules.crawlDelay = g.crawlDelay
+			}
+		}
+		if len(rules.rules) > 0 || rules.crawlDelay > 0 || hasAgentGroup(groups, agent) {
+			break
+		}
+	}
+	return rules
+}
+
+func hasAgentGroup(groups []*group, agent string) bool {
+	for _, g := range groups {
+		if g.matches(agent, false) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether the group names agent, or is a * group when wildcard is set.
+func (g *group) matches(agent string, wildcard bool) bool {
+	for _, a := range g.agents {
+		if wildcard {
+			if a == "*" {
+				return true
+			}
+			continue
+		}
+		// Product tokens may come with a version, as in Agent/1.0
+		if name, _, _ := strings.Cut(a, "/"); name == agent {
+			return true
+		}
+	}
+	return false
+}
+
+func newRule(allow bool, pattern string) rule {
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.ReplaceAll(expr, `\*`, ".*")
+	if strings.HasSuffix(expr, `\$`) {
+		expr = strings.TrimSuffix(expr, `\$`) + "$"
+	}
+	return rule{allow: allow, pattern: pattern, re: regexp.MustCompile("^" + expr)}
+}
+
+// Allowed reports whether path, including its query, may be fetched. The longest
+// matching rule wins and allow wins ties.
+func (r *Rules) Allowed(path string) bool {
+	if path == "/robots.txt" {
+		return true
+	}
+	if r.disallowAll {
+		return false
+	}
+	allowed, length := true, -1
+	for _, rl := range r.rules {
+		if !rl.re.MatchString(path) {
+			continue
+		}
+		if len(rl.pattern) > length || (len(rl.pattern) == length && rl.allow) {
+			allowed, length = rl.allow, len(rl.pattern)
+		}
+	}
+	return allowed
+}
+
+// CrawlDelay is the time to leave between two requests to the site.
+func (r *Rules) CrawlDelay() time.Duration {
+	return r.crawlDelay
+}
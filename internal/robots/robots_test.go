@@ -0,0 +1,140 @@
+package robots
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// parseFixture reads testdata/robots.txt for agent.
+func parseFixture(t *testing.T, agent string) *Rules {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", "robots.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	return Parse(f, agent)
+}
+
+func TestAllowed(t *testing.T) {
+	tests := []struct {
+		agent   string
+		path    string
+		allowed bool
+	}{
+		// Groups for *
+		{"SomeBot", "/", true},
+		{"SomeBot", "/news/today", true},
+		{"SomeBot", "/private/", false},
+		{"SomeBot", "/private/notes", false},
+		// The longest match wins
+		{"SomeBot", "/private/press/release", true},
+		{"SomeBot", "/private/press", false},
+		{"SomeBot", "/search", false},
+		{"SomeBot", "/search?q=go", false},
+		{"SomeBot", "/searching", false},
+		// Allow wins ties
+		{"SomeBot", "/archive", true},
+		{"SomeBot", "/archive/2026", true},
+		// $ anchors the end of the path, * matches anything
+		{"SomeBot", "/files/report.pdf", false},
+		{"SomeBot", "/report.pdf", false},
+		{"SomeBot", "/files/report.pdf?download=1", true},
+		{"SomeBot", "/files/report.pdfx", true},
+		{"SomeBot", "/page?session=abc", false},
+		{"SomeBot", "/page?session=abc&lang=en", false},
+		{"SomeBot", "/page?lang=en&session=abc", true},
+		{"SomeBot", "/page?lang=en", true},
+		// robots.txt itself is always allowed
+		{"BlockedBot", "/robots.txt", true},
+		{"BlockedBot", "/", false},
+		{"BlockedBot", "/news", false},
+		// Groups naming the agent replace those for *
+		{"FeedParser", "/private/notes", true},
+		{"FeedParser", "/search", true},
+		{"FeedParser", "/drafts/wip", false},
+		{"FeedParser", "/drafts/public/post", true},
+		{"FeedParser", "/tmp/file", false},
+		{"feedparser", "/drafts/wip", false},
+		{"OtherBot", "/drafts/wip", false},
+		{"OtherBot", "/tmp/file", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.agent+tt.path, func(t *testing.T) {
+			rules := parseFixture(t, tt.agent)
+			if got := rules.Allowed(tt.path); got != tt.allowed {
+				t.Errorf("Allowed(%q) for %s = %v, want %v", tt.path, tt.agent, got, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestCrawlDelay(t *testing.T) {
+	tests := []struct {
+		agent string
+		delay time.Duration
+	}{
+		{"SomeBot", 2 * time.Second},
+		// The longest delay of the groups naming the agent
+		{"FeedParser", 5 * time.Second},
+		{"OtherBot", 5 * time.Second},
+		// Named groups without a delay do not take that of *
+		{"BlockedBot", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.agent, func(t *testing.T) {
+			if got := parseFixture(t, tt.agent).CrawlDelay(); got != tt.delay {
+				t.Errorf("CrawlDelay() for %s = %v, want %v", tt.agent, got, tt.delay)
+			}
+		})
+	}
+}
+
+func TestParseGroups(t *testing.T) {
+	tests := []struct {
+		name    string
+		robots  string
+		path    string
+		allowed bool
+	}{
+		{"empty", "", "/anything", true},
+		{"rules before any group", "Disallow: /\n", "/anything", true},
+		{"empty disallow", "User-agent: *\nDisallow:\n", "/anything", true},
+		{"comments", "User-agent: * # everyone\nDisallow: /secret # keep out\n", "/secret", false},
+		{"case of keys", "USER-AGENT: *\nDISALLOW: /secret\n", "/secret", false},
+		{"paths keep their case", "User-agent: *\nDisallow: /Secret\n", "/secret", true},
+		{"agent with version", "User-agent: FeedParser/2.3\nDisallow: /x\n", "/x", false},
+		{"agent prefix only", "User-agent: FeedParserPlus\nDisallow: /x\n", "/x", true},
+		{"named group without rules", "User-agent: *\nDisallow: /\n\nUser-agent: FeedParser\nAllow: /\n", "/x", true},
+		{"split groups of the agent", "User-agent: FeedParser\nDisallow: /a\n\nUser-agent: *\nDisallow: /b\n\nUser-agent: FeedParser\nDisallow: /c\n", "/c", false},
+		{"star in the middle", "User-agent: *\nDisallow: /*/edit\n", "/posts/1/edit", false},
+		{"escaped characters", "User-agent: *\nDisallow: /a.b(c)\n", "/aXb(c)", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := Parse(strings.NewReader(tt.robots), "FeedParser")
+			if got := rules.Allowed(tt.path); got != tt.allowed {
+				t.Errorf("Allowed(%q) = %v, want %v", tt.path, got, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestDisallowAll(t *testing.T) {
+	rules := DisallowAll()
+	if rules.Allowed("/") || rules.Allowed("/news") {
+		t.Error("DisallowAll allows pages")
+	}
+	if !rules.Allowed("/robots.txt") {
+		t.Error("DisallowAll disallows /robots.txt")
+	}
+	if !AllowAll().Allowed("/private/") {
+		t.Error("AllowAll disallows pages")
+	}
+}
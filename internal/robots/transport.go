@@ -0,0 +1,411 @@
+package robots
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lufeed/feed-parser-api/internal/breaker"
+	"github.com/lufeed/feed-parser-api/internal/browser"
+	"github.com/lufeed/feed-parser-api/internal/config"
+	"github.com/lufeed/feed-parser-api/internal/logger"
+	"github.com/lufeed/feed-parser-api/internal/ssrf"
+)
+
+// Enforcement modes
+const (
+	// ModeEnforce refuses disallowed fetches and waits out crawl delays
+	ModeEnforce = "enforce"
+	// ModeReportOnly logs disallowed fetches but lets them through
+	ModeReportOnly = "report-only"
+	// ModeOff does not look at robots.txt
+	ModeOff = "off"
+)
+
+const (
+	defaultAgent         = "feed-parser-api"
+	defaultMaxCrawlDelay = 30 * time.Second
+	rulesTTL             = 24 * time.Hour
+	// Unreachable robots.txt are retried sooner
+	failureTTL   = 10 * time.Minute
+	fetchTimeout = 15 * time.Second
+	// maxSites is the number of sites kept before expired ones are pruned
+	maxSites = 4096
+	// reservationTTL is how long a crawl delay slot waited for stays reserved for the
+	// request it was waited for
+	reservationTTL = time.Minute
+)
+
+// DisallowedError is returned for fetches robots.txt does not allow in enforce mode.
+type DisallowedError struct {
+	URL   string
+	Agent string
+}
+
+func (e *DisallowedError) Error() string {
+	return fmt.Sprintf("fetching %s is disallowed by robots.txt for %s", e.URL, e.Agent)
+}
+
+// TooEarlyError is returned in enforce mode for requests sent before the crawl delay of
+// their site is over, which WaitCrawlDelay did not reserve a slot for. They may be sent
+// again at RetryAt.
+type TooEarlyError struct {
+	URL     string
+	RetryAt time.Time
+}
+
+func (e *TooEarlyError) Error() string {
+	return fmt.Sprintf("fetching %s before the crawl delay of its site is over, retry at %s", e.URL, e.RetryAt.UTC().Format(time.RFC3339))
+}
+
+type entry struct {
+	ready   chan struct{}
+	rules   *Rules
+	expires time.Time
+}
+
+// crawlSlots are the crawl delay reservations of a site.
+type crawlSlots struct {
+	// next is the earliest time the next request may start
+	next time.Time
+	// reserved counts the slots waited for by WaitCrawlDelay and not taken yet
+	reserved   int
+	reservedAt time.Time
+}
+
+// Rules and crawl delay reservations are shared by the transports of all proxies
+var (
+	mu      sync.Mutex
+	entries = make(map[string]*entry)
+	slots   = make(map[string]*crawlSlots)
+
+	loaded       robotsSettings
+	settingsOnce sync.Once
+)
+
+// Transport checks requests against the robots.txt of their site before passing them
+// to the underlying transport, which robots.txt itself is fetched through.
+type Transport struct {
+	base   http.RoundTripper
+	client *http.Client
+	// proxied transports cannot tell failures of the proxy from those of the site
+	proxied bool
+}
+
+// NewTransport wraps base with robots.txt checks. robots.txt is fetched through the
+// SSRF guard, so that its redirects are checked as well, proxied tells whether base
+// goes through a proxy.
+func NewTransport(base http.RoundTripper, proxied bool) *Transport {
+	return &Transport{
+		base:    base,
+		client:  &http.Client{Transport: ssrf.NewTransport(base, proxied), Timeout: fetchTimeout},
+		proxied: proxied,
+	}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	cfg := settings()
+	mode := cfg.modeFor(req.URL.Hostname())
+	if mode == ModeOff || req.URL.Path == "/robots.txt" {
+		return t.base.RoundTrip(req)
+	}
+
+	origin := req.URL.Scheme + "://" + req.URL.Host
+	rules := t.rules(req.Context(), origin, cfg.agent)
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if req.URL.RawQuery != "" {
+		path += "?" + req.URL.RawQuery
+	}
+	if !rules.Allowed(path) {
+		if mode == ModeEnforce {
+			logger.GetSugaredLogger().Infof("robots.txt: refused %s for %s", req.URL.String(), cfg.agent)
+			if req.Body != nil {
+				req.Body.Close()
+			}
+			return nil, &DisallowedError{URL: req.URL.String(), Agent: cfg.agent}
+		}
+		logger.GetSugaredLogger().Warnf("robots.txt: fetching disallowed %s for %s (report-only)", req.URL.String(), cfg.agent)
+	}
+
+	if mode == ModeEnforce {
+		// Waiting here would hold the proxy of the request, WaitCrawlDelay waits before
+		// one is acquired
+		if retryAt, ok := takeSlot(origin, min(rules.CrawlDelay(), cfg.maxCrawlDelay), time.Now()); !ok {
+			if req.Body != nil {
+				req.Body.Close()
+			}
+			return nil, &TooEarlyError{URL: req.URL.String(), RetryAt: retryAt}
+		}
+	}
+	return t.base.RoundTrip(req)
+}
+
+// rules returns the rules of the site at origin, fetching robots.txt once per TTL no
+// matter how many requests ask at the same time.
+func (t *Transport) rules(ctx context.Context, origin, agent string) *Rules {
+	mu.Lock()
+	e, ok := entries[origin]
+	if ok {
+		select {
+		case <-e.ready:
+			if time.Now().After(e.expires) {
+				ok = false
+			}
+		default:
+		}
+	}
+	if !ok {
+		if _, known := entries[origin]; !known && len(entries) >= maxSites {
+			pruneEntries(time.Now())
+		}
+		e = &entry{ready: make(chan struct{})}
+		entries[origin] = e
+		mu.Unlock()
+
+		var ttl time.Duration
+		e.rules, ttl = t.fetch(ctx, origin, agent)
+		e.expires = time.Now().Add(ttl)
+		close(e.ready)
+		return e.rules
+	}
+	mu.Unlock()
+
+	<-e.ready
+	return e.rules
+}
+
+// pruneEntries drops the expired rules, and others at random while there are still
+// too many. Rules being fetched are kept. The caller holds mu.
+func pruneEntries(now time.Time) {
+	for origin, e := range entries {
+		select {
+		case <-e.ready:
+			if now.After(e.expires) {
+				delete(entries, origin)
+			}
+		default:
+		}
+	}
+	for origin, e := range entries {
+		if len(entries) < maxSites {
+			break
+		}
+		select {
+		case <-e.ready:
+			delete(entries, origin)
+		default:
+		}
+	}
+}
+
+// fetch downloads and parses the robots.txt of origin. Following RFC 9309, a missing
+// robots.txt allows everything and an unreachable one disallows everything. Failures
+// that say nothing about the site, such as those of a proxy, a wait in the scheduler
+// or the caller giving up, allow everything without being kept, so that robots.txt
+// is fetched again by the next request.
+func (t *Transport) fetch(ctx context.Context, origin, agent string) (*Rules, time.Duration) {
+	req, err := http.NewRequestWithContext(ctx, "GET", origin+"/robots.txt", nil)
+	if err != nil {
+		return AllowAll(), rulesTTL
+	}
+	req.Header.Set("User-Agent", browser.GetUserAgent())
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		// Through a proxy, the request itself tells whether the site is reachable
+		if ctx.Err() != nil || !breaker.IsFailure(err) || t.proxied {
+			logger.GetSugaredLogger().Debugf("Cannot fetch robots.txt of %s: %s", origin, err.Error())
+			return AllowAll(), 0
+		}
+		logger.GetSugaredLogger().Warnf("robots.txt of %s is unreachable: %s", origin, err.Error())
+		return DisallowAll(), failureTTL
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusProxyAuthRequired:
+		// Answered by the proxy rather than the site
+		return AllowAll(), 0
+	case resp.StatusCode >= 500:
+		logger.GetSugaredLogger().Warnf("robots.txt of %s is unavailable: status %d", origin, resp.StatusCode)
+		return DisallowAll(), failureTTL
+	case resp.StatusCode >= 400:
+		return AllowAll(), rulesTTL
+	case resp.StatusCode != http.StatusOK:
+		return AllowAll(), failureTTL
+	}
+	return Parse(resp.Body, agent), rulesTTL
+}
+
+// WaitCrawlDelay reserves the next crawl delay slot of the site of rawURL and waits for
+// it, so that the request to rawURL can be sent right away. It is called before a proxy
+// is acquired for the request, rather than having the request wait while holding one.
+// Sites whose robots.txt is not known yet are not waited for.
+func WaitCrawlDelay(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return nil
+	}
+	cfg := settings()
+	if cfg.modeFor(u.Hostname()) != ModeEnforce {
+		return nil
+	}
+	origin := u.Scheme + "://" + u.Host
+
+	mu.Lock()
+	e, ok := entries[origin]
+	mu.Unlock()
+	if !ok {
+		return nil
+	}
+	// robots.txt may be on its way for another request
+	select {
+	case <-e.ready:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	delay := min(e.rules.CrawlDelay(), cfg.maxCrawlDelay)
+	if delay <= 0 {
+		return nil
+	}
+
+	mu.Lock()
+	now := time.Now()
+	sl := siteSlots(origin, now)
+	slot := sl.next
+	if slot.Before(now) {
+		slot = now
+	}
+	sl.next = slot.Add(delay)
+	mu.Unlock()
+
+	if wait := time.Until(slot); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	mu.Lock()
+	now = time.Now()
+	sl = siteSlots(origin, now)
+	sl.reserved++
+	sl.reservedAt = now
+	mu.Unlock()
+	return nil
+}
+
+// takeSlot lets a request to the site at origin start at now, taking a slot reserved
+// by WaitCrawlDelay or else the next one when it is due. It returns when the next slot
+// is due otherwise.
+func takeSlot(origin string, delay time.Duration, now time.Time) (time.Time, bool) {
+	if delay <= 0 {
+		return time.Time{}, true
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	sl := siteSlots(origin, now)
+	if sl.reserved > 0 && now.Sub(sl.reservedAt) < reservationTTL {
+		sl.reserved--
+		return time.Time{}, true
+	}
+	// Reservations of requests that never came are given up
+	sl.reserved = 0
+	if sl.next.After(now) {
+		return sl.next, false
+	}
+	sl.next = now.Add(delay)
+	return time.Time{}, true
+}
+
+// siteSlots returns the slots of the site at origin. Sites with neither a slot to come
+// nor a reservation are as good as new and pruned once there are many. The caller
+// holds mu.
+func siteSlots(origin string, now time.Time) *crawlSlots {
+	sl, ok := slots[origin]
+	if ok {
+		return sl
+	}
+	if len(slots) >= maxSites {
+		for o, other := range slots {
+			if other.next.Before(now) && (other.reserved == 0 || now.Sub(other.reservedAt) >= reservationTTL) {
+				delete(slots, o)
+			}
+		}
+	}
+	sl = &crawlSlots{}
+	slots[origin] = sl
+	return sl
+}
+
+type robotsSettings struct {
+	mode          string
+	agent         string
+	maxCrawlDelay time.Duration
+	overrides     map[string]string
+}
+
+func settings() robotsSettings {
+	settingsOnce.Do(func() {
+		loaded = loadSettings(config.GetConfig())
+	})
+	return loaded
+}
+
+func loadSettings(cfg *config.AppConfig) robotsSettings {
+	s := robotsSettings{mode: ModeOff, agent: defaultAgent, maxCrawlDelay: defaultMaxCrawlDelay}
+	if cfg == nil {
+		return s
+	}
+	if IsValidMode(cfg.Robots.Mode) {
+		s.mode = cfg.Robots.Mode
+	}
+	if cfg.Robots.Agent != "" {
+		s.agent = cfg.Robots.Agent
+	}
+	if cfg.Robots.MaxCrawlDelay > 0 {
+		s.maxCrawlDelay = time.Duration(cfg.Robots.MaxCrawlDelay) * time.Second
+	}
+	s.overrides = make(map[string]string, len(cfg.Robots.Overrides))
+	for _, o := range cfg.Robots.Overrides {
+		s.overrides[strings.TrimPrefix(strings.ToLower(o.Domain), "www.")] = o.Mode
+	}
+	return s
+}
+
+// modeFor returns the mode for host, taking overrides of the host or one of its parent
+// domains into account.
+func (s robotsSettings) modeFor(host string) string {
+	host = strings.TrimPrefix(strings.ToLower(host), "www.")
+	for host != "" {
+		if mode, ok := s.overrides[host]; ok && IsValidMode(mode) {
+			return mode
+		}
+		_, parent, found := strings.Cut(host, ".")
+		if !found {
+			break
+		}
+		host = parent
+	}
+	return s.mode
+}
+
+// IsValidMode reports whether mode is one of the enforcement modes.
+func IsValidMode(mode string) bool {
+	switch mode {
+	case ModeEnforce, ModeReportOnly, ModeOff:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,321 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lufeed/feed-parser-api/internal/cache"
+	"github.com/lufeed/feed-parser-api/internal/config"
+	"github.com/lufeed/feed-parser-api/internal/logger"
+	"golang.org/x/time/rate"
+)
+
+const (
+	defaultMaxInFlight     = 100
+	defaultHostRate        = 2
+	defaultHostBurst       = 5
+	defaultHostConcurrency = 4
+	// Hosts without requests for this long are forgotten
+	idleHostTTL = 10 * time.Minute
+)
+
+var (
+	scheduler     *Scheduler
+	schedulerOnce sync.Once
+)
+
+// Limits of the requests to one host.
+type Limits struct {
+	// Rate is the number of requests per second, Burst how many may start at once
+	Rate        float64
+	Burst       int
+	Concurrency int
+}
+
+// WaitError is returned for requests that gave up waiting for their turn, because
+// their context ended first. It says nothing about the host.
+type WaitError struct {
+	Host string
+	Err  error
+}
+
+func (e *WaitError) Error() string {
+	return fmt.Sprintf("waiting for a slot of %s: %s", e.Host, e.Err.Error())
+}
+
+func (e *WaitError) Unwrap() error {
+	return e.Err
+}
+
+// Scheduler admits outbound requests under per-host token buckets, per-host
+// concurrency limits and a global cap on requests in flight.
+type Scheduler struct {
+	global    chan struct{}
+	defaults  Limits
+	overrides map[string]Limits
+	// shared coordinates host rates with the other instances through the cache
+	shared bool
+
+	mu        sync.Mutex
+	hosts     map[string]*host
+	queued    int
+	lastPrune time.Time
+}
+
+type host struct {
+	limits   Limits
+	limiter  *rate.Limiter
+	slots    chan struct{}
+	queued   int
+	inFlight int
+	requests int64
+	// throttled counts the requests that had to wait, waited sums their waits
+	throttled int64
+	waited    time.Duration
+	lastUsed  time.Time
+}
+
+// HostStats are the queue metrics of one host.
+type HostStats struct {
+	Host        string  `json:"host"`
+	Queued      int     `json:"queued"`
+	InFlight    int     `json:"in_flight"`
+	Requests    int64   `json:"requests"`
+	Throttled   int64   `json:"throttled"`
+	AvgWaitMs   float64 `json:"avg_wait_ms"`
+	Rate        float64 `json:"rate"`
+	Concurrency int     `json:"concurrency"`
+}
+
+// Stats are the queue metrics of the scheduler.
+type Stats struct {
+	InFlight    int         `json:"in_flight"`
+	MaxInFlight int         `json:"max_in_flight"`
+	Queued      int         `json:"queued"`
+	Hosts       []HostStats `json:"hosts"`
+}
+
+// Get returns the scheduler configured by the scheduler section of the configuration.
+func Get() *Scheduler {
+	schedulerOnce.Do(func() {
+		cfg := config.GetConfig()
+		if cfg == nil {
+			cfg = &config.AppConfig{}
+		}
+		scheduler = New(cfg.Scheduler)
+	})
+	return scheduler
+}
+
+// New builds a scheduler, unset limits take the defaults.
+func New(cfg config.SchedulerConfig) *Scheduler {
+	maxInFlight := cfg.MaxInFlight
+	if maxInFlight <= 0 {
+		maxInFlight = defaultMaxInFlight
+	}
+	s := &Scheduler{
+		global:    make(chan struct{}, maxInFlight),
+		defaults:  withDefaults(Limits{Rate: cfg.HostRate, Burst: cfg.HostBurst, Concurrency: cfg.HostConcurrency}, Limits{Rate: defaultHostRate, Burst: defaultHostBurst, Concurrency: defaultHostConcurrency}),
+		overrides: make(map[string]Limits),
+		shared:    cfg.Shared,
+		hosts:     make(map[string]*host),
+	}
+	for _, o := range cfg.Hosts {
+		name := normalizeHost(o.Host)
+		s.overrides[name] = withDefaults(Limits{Rate: o.Rate, Burst: o.Burst, Concurrency: o.Concurrency}, s.defaults)
+	}
+	return s
+}
+
+func withDefaults(l, defaults Limits) Limits {
+	if l.Rate <= 0 {
+		l.Rate = defaults.Rate
+	}
+	if l.Burst <= 0 {
+		l.Burst = defaults.Burst
+	}
+	if l.Concurrency <= 0 {
+		l.Concurrency = defaults.Concurrency
+	}
+	return l
+}
+
+// Acquire waits until a request to hostname may start, or fails with a WaitError when
+// ctx ends first. The returned function must be called once the request is done,
+// calling it again has no effect.
+func (s *Scheduler) Acquire(ctx context.Context, hostname string) (func(), error) {
+	name := normalizeHost(hostname)
+	start := time.Now()
+
+	s.mu.Lock()
+	h := s.host(name, start)
+	h.queued++
+	s.queued++
+	s.mu.Unlock()
+
+	dequeue := func() {
+		s.mu.Lock()
+		h.queued--
+		s.queued--
+		s.mu.Unlock()
+	}
+
+	select {
+	case h.slots <- struct{}{}:
+	case <-ctx.Done():
+		dequeue()
+		return nil, &WaitError{Host: name, Err: ctx.Err()}
+	}
+	releaseHost := func() { <-h.slots }
+
+	if err := h.limiter.Wait(ctx); err != nil {
+		dequeue()
+		releaseHost()
+		return nil, &WaitError{Host: name, Err: err}
+	}
+	if s.shared {
+		if err := s.waitShared(ctx, name, h.limits); err != nil {
+			dequeue()
+			releaseHost()
+			return nil, &WaitError{Host: name, Err: err}
+		}
+	}
+
+	select {
+	case s.global <- struct{}{}:
+	case <-ctx.Done():
+		dequeue()
+		releaseHost()
+		return nil, &WaitError{Host: name, Err: ctx.Err()}
+	}
+
+	waited := time.Since(start)
+	s.mu.Lock()
+	h.queued--
+	s.queued--
+	h.inFlight++
+	h.requests++
+	if waited >= time.Millisecond {
+		h.throttled++
+		h.waited += waited
+	}
+	s.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			<-s.global
+			releaseHost()
+			s.mu.Lock()
+			h.inFlight--
+			h.lastUsed = time.Now()
+			s.mu.Unlock()
+		})
+	}, nil
+}
+
+// host returns the state of name, creating it on first use. s.mu must be held.
+func (s *Scheduler) host(name string, now time.Time) *host {
+	if now.Sub(s.lastPrune) > idleHostTTL {
+		s.lastPrune = now
+		for n, h := range s.hosts {
+			if h.queued == 0 && h.inFlight == 0 && now.Sub(h.lastUsed) > idleHostTTL {
+				delete(s.hosts, n)
+			}
+		}
+	}
+
+	h, ok := s.hosts[name]
+	if !ok {
+		limits := s.limits(name)
+		h = &host{
+			limits:  limits,
+			limiter: rate.NewLimiter(rate.Limit(limits.Rate), limits.Burst),
+			slots:   make(chan struct{}, limits.Concurrency),
+		}
+		s.hosts[name] = h
+	}
+	h.lastUsed = now
+	return h
+}
+
+// limits returns the limits of name, overrides of a parent domain apply to its
+// subdomains.
+func (s *Scheduler) limits(name string) Limits {
+	for d := name; d != ""; {
+		if l, ok := s.overrides[d]; ok {
+			return l
+		}
+		_, parent, found := strings.Cut(d, ".")
+		if !found {
+			break
+		}
+		d = parent
+	}
+	return s.defaults
+}
+
+// waitShared holds the request until the host's rate over all instances allows it,
+// counting requests per host and second in the cache. Cache failures let it through.
+func (s *Scheduler) waitShared(ctx context.Context, name string, limits Limits) error {
+	perSecond := int64(math.Max(1, math.Ceil(limits.Rate)))
+	for {
+		now := time.Now()
+		count, err := cache.Incr(fmt.Sprintf("scheduler:%s:%d", name, now.Unix()), 2*time.Second)
+		if err != nil {
+			logger.GetSugaredLogger().Debugf("Cannot coordinate rate of %s: %s", name, err.Error())
+			return nil
+		}
+		if count <= perSecond {
+			return nil
+		}
+		timer := time.NewTimer(now.Truncate(time.Second).Add(time.Second).Sub(now))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// Stats returns the queue metrics, busiest hosts first.
+func (s *Scheduler) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := Stats{MaxInFlight: cap(s.global), Queued: s.queued}
+	for name, h := range s.hosts {
+		hs := HostStats{
+			Host:        name,
+			Queued:      h.queued,
+			InFlight:    h.inFlight,
+			Requests:    h.requests,
+			Throttled:   h.throttled,
+			Rate:        h.limits.Rate,
+			Concurrency: h.limits.Concurrency,
+		}
+		if h.throttled > 0 {
+			hs.AvgWaitMs = float64(h.waited.Milliseconds()) / float64(h.throttled)
+		}
+		stats.InFlight += h.inFlight
+		stats.Hosts = append(stats.Hosts, hs)
+	}
+	sort.Slice(stats.Hosts, func(i, j int) bool {
+		a, b := stats.Hosts[i], stats.Hosts[j]
+		if a.Queued+a.InFlight != b.Queued+b.InFlight {
+			return a.Queued+a.InFlight > b.Queued+b.InFlight
+		}
+		return a.Requests > b.Requests
+	})
+	return stats
+}
+
+func normalizeHost(hostname string) string {
+	return strings.TrimPrefix(strings.ToLower(strings.TrimSpace(hostname)), "www.")
+}
@@ -0,0 +1,58 @@
+package scheduler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+)
+
+// Transport passes requests to the underlying transport once the scheduler admits
+// them. A request counts as in flight until its response body is read or closed.
+type Transport struct {
+	base      http.RoundTripper
+	scheduler *Scheduler
+}
+
+// NewTransport wraps base with the shared scheduler.
+func NewTransport(base http.RoundTripper) *Transport {
+	return &Transport{base: base, scheduler: Get()}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	release, err := t.scheduler.Acquire(req.Context(), req.URL.Hostname())
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	resp.Body = &releaseBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// releaseBody releases the request slot at the end of the body. release may be
+// called any number of times.
+type releaseBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releaseBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if errors.Is(err, io.EOF) {
+		b.release()
+	}
+	return n, err
+}
+
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
@@ -0,0 +1,263 @@
+package scrape
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/lufeed/feed-parser-api/internal/dates"
+	"github.com/lufeed/feed-parser-api/internal/fetch"
+	"github.com/lufeed/feed-parser-api/internal/models"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+var attrNameRegex = regexp.MustCompile(`^\s*[\w:-]+\s*$`)
+
+// Item is a single entry extracted from a list page.
+type Item struct {
+	Title       string     `json:"title"`
+	Link        string     `json:"link"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+	RawDate     string     `json:"raw_date,omitempty"`
+	ImageURL    string     `json:"image_url,omitempty"`
+	Summary     string     `json:"summary,omitempty"`
+}
+
+type Extractor struct {
+	cl     *http.Client
+	recipe models.ScrapeRecipe
+}
+
+func NewExtractor(cl *http.Client, recipe models.ScrapeRecipe) *Extractor {
+	return &Extractor{cl: cl, recipe: recipe}
+}
+
+// Validate checks that the recipe has a list URL and that all selectors compile.
+func Validate(recipe models.ScrapeRecipe) error {
+	parsedURL, err := url.Parse(recipe.ListURL)
+	if err != nil || parsedURL.Host == "" || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") {
+		return fmt.Errorf("invalid list_url: %s", recipe.ListURL)
+	}
+	if strings.TrimSpace(recipe.ItemSelector) == "" {
+		return fmt.Errorf("item_selector is required")
+	}
+
+	selectors := map[string]string{
+		"item_selector":    recipe.ItemSelector,
+		"title_selector":   recipe.TitleSelector,
+		"link_selector":    recipe.LinkSelector,
+		"date_selector":    recipe.DateSelector,
+		"image_selector":   recipe.ImageSelector,
+		"summary_selector": recipe.SummarySelector,
+	}
+	for name, sel := range selectors {
+		if sel == "" {
+			continue
+		}
+		css, _ := splitSelector(sel)
+		if css == "" {
+			continue
+		}
+		if _, err := cascadia.Compile(css); err != nil {
+			return fmt.Errorf("invalid %s: %s", name, err.Error())
+		}
+	}
+	return nil
+}
+
+// Exec downloads the list page and extracts the items described by the recipe.
+func (e *Extractor) Exec() ([]Item, error) {
+	if err := Validate(e.recipe); err != nil {
+		return nil, err
+	}
+
+	doc, err := e.getDoc()
+	if err != nil {
+		return nil, err
+	}
+	return e.Extract(doc)
+}
+
+// Extract applies the recipe to an already parsed list page.
+func (e *Extractor) Extract(doc *html.Node) ([]Item, error) {
+	itemSel, err := cascadia.Compile(e.recipe.ItemSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []Item
+	seen := make(map[string]bool)
+	for _, n := range itemSel.MatchAll(doc) {
+		item := Item{
+			Title:   collapseSpace(e.value(n, e.recipe.TitleSelector, "")),
+			Link:    e.resolve(e.value(n, e.recipe.LinkSelector, "href")),
+			Summary: collapseSpace(e.value(n, e.recipe.SummarySelector, "")),
+		}
+
+		if item.Link == "" {
+			item.Link = e.resolve(e.value(n, "a[href]@href", ""))
+		}
+		if item.Link == "" || seen[item.Link] {
+			continue
+		}
+		seen[item.Link] = true
+
+		if item.Title == "" {
+			item.Title = collapseSpace(e.value(n, "a[href]", ""))
+		}
+
+		if e.recipe.ImageSelector != "" {
+			image := e.value(n, e.recipe.ImageSelector, "src")
+			if image == "" {
+				image = e.value(n, e.recipe.ImageSelector, "data-src")
+			}
+			item.ImageURL = e.resolve(image)
+		}
+
+		if e.recipe.DateSelector != "" {
+			item.RawDate = strings.TrimSpace(e.value(n, e.recipe.DateSelector, "datetime"))
+			if item.RawDate == "" {
+				item.RawDate = collapseSpace(e.value(n, e.recipe.DateSelector, ""))
+			}
+			if t, ok := e.parseDate(item.RawDate); ok {
+				item.PublishedAt = &t
+			}
+		}
+
+		items = append(items, item)
+	}
+
+	if len(items) == 0 {
+		return nil, fmt.Errorf("no items matched %q on %s", e.recipe.ItemSelector, e.recipe.ListURL)
+	}
+	return items, nil
+}
+
+func (e *Extractor) getDoc() (*html.Node, error) {
+	resp, err := fetch.With(e.cl).Do(context.Background(), fetch.Request{URL: e.recipe.ListURL, Kind: fetch.KindPage, RequireHTML: true})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("received non-200 status code: %d", resp.StatusCode)
+	}
+
+	reader, err := charset.NewReader(resp.Body, resp.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+	return html.Parse(reader)
+}
+
+// value evaluates selector relative to n. A trailing "@attr" selects an attribute, an
+// empty CSS part refers to n itself. Without "@attr" the element text is returned, or
+// defaultAttr of the element (or its first descendant carrying it) when set.
+func (e *Extractor) value(n *html.Node, selector string, defaultAttr string) string {
+	if selector == "" {
+		return ""
+	}
+	css, attr := splitSelector(selector)
+
+	target := n
+	if css != "" {
+		sel, err := cascadia.Compile(css)
+		if err != nil {
+			return ""
+		}
+		target = sel.MatchFirst(n)
+		if target == nil {
+			return ""
+		}
+	}
+
+	if attr != "" {
+		return getAttr(target, attr)
+	}
+	if defaultAttr != "" {
+		if v := getAttr(target, defaultAttr); v != "" {
+			return v
+		}
+		// The attribute may live on a descendant, e.g. an <a> inside an <h2>
+		if child := cascadia.MustCompile("[" + defaultAttr + "]").MatchFirst(target); child != nil {
+			return getAttr(child, defaultAttr)
+		}
+		return ""
+	}
+	return textContent(target)
+}
+
+func (e *Extractor) parseDate(raw string) (time.Time, bool) {
+	if raw == "" {
+		return time.Time{}, false
+	}
+	if e.recipe.DateFormat != "" {
+		if t, err := time.Parse(e.recipe.DateFormat, raw); err == nil {
+			return t, true
+		}
+	}
+	return dates.Parse(raw)
+}
+
+func (e *Extractor) resolve(href string) string {
+	href = strings.TrimSpace(href)
+	if href == "" || strings.HasPrefix(href, "javascript:") || strings.HasPrefix(href, "#") {
+		return ""
+	}
+	base, err := url.Parse(e.recipe.ListURL)
+	if err != nil {
+		return ""
+	}
+	u, err := url.Parse(href)
+	if err != nil {
+		return ""
+	}
+	return base.ResolveReference(u).String()
+}
+
+func splitSelector(selector string) (string, string) {
+	idx := strings.LastIndex(selector, "@")
+	if idx < 0 || !attrNameRegex.MatchString(selector[idx+1:]) {
+		return strings.TrimSpace(selector), ""
+	}
+	return strings.TrimSpace(selector[:idx]), strings.TrimSpace(selector[idx+1:])
+}
+
+func getAttr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if strings.EqualFold(a.Key, key) {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func textContent(n *html.Node) string {
+	var sb strings.Builder
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode && (n.Data == "script" || n.Data == "style") {
+			return
+		}
+		if n.Type == html.TextNode {
+			sb.WriteString(n.Data)
+			sb.WriteString(" ")
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(n)
+	return sb.String()
+}
+
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,105 @@
+package scrape
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/lufeed/feed-parser-api/internal/cache"
+	"github.com/lufeed/feed-parser-api/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	recipesKey      = "scrape_recipes"
+	recipesByURLKey = "scrape_recipes_by_url"
+)
+
+var ErrRecipeNotFound = errors.New("scrape recipe not found")
+
+// SaveRecipe stores the recipe and indexes it by its list URL, replacing any recipe
+// previously registered for the same URL.
+func SaveRecipe(recipe models.ScrapeRecipe) error {
+	b, err := json.Marshal(recipe)
+	if err != nil {
+		return err
+	}
+
+	urlKey := normalizeURL(recipe.ListURL)
+	previousID, err := cache.GetHashField(recipesByURLKey, urlKey)
+	if err == nil && previousID != "" && previousID != recipe.ID.String() {
+		cache.DeleteHashField(recipesKey, previousID)
+	}
+
+	if err := cache.SetHashField(recipesKey, recipe.ID.String(), b); err != nil {
+		return err
+	}
+	return cache.SetHashField(recipesByURLKey, urlKey, recipe.ID.String())
+}
+
+func GetRecipe(id uuid.UUID) (models.ScrapeRecipe, error) {
+	var recipe models.ScrapeRecipe
+	data, err := cache.GetHashField(recipesKey, id.String())
+	if errors.Is(err, redis.Nil) {
+		return recipe, ErrRecipeNotFound
+	}
+	if err != nil {
+		return recipe, err
+	}
+	err = json.Unmarshal([]byte(data), &recipe)
+	return recipe, err
+}
+
+// GetRecipeByURL returns the recipe registered for the list page at listURL.
+func GetRecipeByURL(listURL string) (models.ScrapeRecipe, bool) {
+	id, err := cache.GetHashField(recipesByURLKey, normalizeURL(listURL))
+	if err != nil || id == "" {
+		return models.ScrapeRecipe{}, false
+	}
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return models.ScrapeRecipe{}, false
+	}
+	recipe, err := GetRecipe(parsedID)
+	if err != nil {
+		return models.ScrapeRecipe{}, false
+	}
+	return recipe, true
+}
+
+func ListRecipes() ([]models.ScrapeRecipe, error) {
+	all, err := cache.GetHash(recipesKey)
+	if err != nil {
+		return nil, err
+	}
+
+	recipes := make([]models.ScrapeRecipe, 0, len(all))
+	for _, data := range all {
+		var recipe models.ScrapeRecipe
+		if err := json.Unmarshal([]byte(data), &recipe); err != nil {
+			continue
+		}
+		recipes = append(recipes, recipe)
+	}
+	sort.Slice(recipes, func(i, j int) bool {
+		return recipes[i].CreatedAt.Before(recipes[j].CreatedAt)
+	})
+	return recipes, nil
+}
+
+func DeleteRecipe(id uuid.UUID) error {
+	recipe, err := GetRecipe(id)
+	if err != nil {
+		return err
+	}
+	if err := cache.DeleteHashField(recipesKey, id.String()); err != nil {
+		return err
+	}
+	return cache.DeleteHashField(recipesByURLKey, normalizeURL(recipe.ListURL))
+}
+
+func normalizeURL(u string) string {
+	return strings.TrimSuffix(strings.TrimSpace(u), "/")
+}
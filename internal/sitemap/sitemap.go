@@ -0,0 +1,347 @@
+package sitemap
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lufeed/feed-parser-api/internal/config"
+	"github.com/lufeed/feed-parser-api/internal/dates"
+	"github.com/lufeed/feed-parser-api/internal/fetch"
+	"github.com/lufeed/feed-parser-api/internal/logger"
+)
+
+const (
+	defaultMaxDepth    = 2
+	defaultMaxEntries  = 1000
+	defaultMaxChildren = 50
+	defaultMaxSitemaps = 100
+	// maxSitemapSize is the limit set by the sitemap protocol for uncompressed files.
+	maxSitemapSize = 50 * 1024 * 1024
+)
+
+// Entry is a single page listed in a sitemap, including the Google News and image
+// extensions when present.
+type Entry struct {
+	URL             string
+	LastMod         *time.Time
+	Title           string
+	PublishedAt     *time.Time
+	PublicationName string
+	Language        string
+	Images          []string
+}
+
+// Date returns the most precise date known for the entry.
+func (e Entry) Date() *time.Time {
+	if e.PublishedAt != nil {
+		return e.PublishedAt
+	}
+	return e.LastMod
+}
+
+type urlSet struct {
+	URLs []struct {
+		Loc     string `xml:"loc"`
+		LastMod string `xml:"lastmod"`
+		News    *struct {
+			Title           string `xml:"title"`
+			PublicationDate string `xml:"publication_date"`
+			Publication     struct {
+				Name     string `xml:"name"`
+				Language string `xml:"language"`
+			} `xml:"publication"`
+		} `xml:"news"`
+		Images []struct {
+			Loc string `xml:"loc"`
+		} `xml:"image"`
+	} `xml:"url"`
+}
+
+type sitemapIndex struct {
+	Sitemaps []struct {
+		Loc     string `xml:"loc"`
+		LastMod string `xml:"lastmod"`
+	} `xml:"sitemap"`
+}
+
+type Parser struct {
+	cl          *http.Client
+	maxDepth    int
+	maxEntries  int
+	maxChildren int
+	maxSitemaps int
+
+	// fetched is the number of sitemaps loaded by the current Exec
+	fetched int
+}
+
+// NewParser creates a sitemap parser that follows sitemap indexes up to MaxDepth levels,
+// visits at most MaxChildren sitemaps of an index and MaxSitemaps in total, and stops
+// collecting once MaxEntries pages are known. Zero values select the defaults.
+func NewParser(cl *http.Client, cfg config.SitemapConfig) *Parser {
+	p := &Parser{
+		cl:          cl,
+		maxDepth:    cfg.MaxDepth,
+		maxEntries:  cfg.MaxEntries,
+		maxChildren: cfg.MaxChildren,
+		maxSitemaps: cfg.MaxSitemaps,
+	}
+	if p.maxDepth <= 0 {
+		p.maxDepth = defaultMaxDepth
+	}
+	if p.maxEntries <= 0 {
+		p.maxEntries = defaultMaxEntries
+	}
+	if p.maxChildren <= 0 {
+		p.maxChildren = defaultMaxChildren
+	}
+	if p.maxSitemaps <= 0 {
+		p.maxSitemaps = defaultMaxSitemaps
+	}
+	return p
+}
+
+// Exec loads the sitemap or sitemap index at sitemapURL and returns its entries sorted
+// from most to least recent. Entries without any date are placed last.
+func (p *Parser) Exec(ctx context.Context, sitemapURL string) ([]Entry, error) {
+	p.fetched = 0
+	entries, err := p.load(ctx, sitemapURL, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("sitemap has no entries: %s", sitemapURL)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		di, dj := entries[i].Date(), entries[j].Date()
+		if di == nil {
+			return false
+		}
+		if dj == nil {
+			return true
+		}
+		return di.After(*dj)
+	})
+
+	if len(entries) > p.maxEntries {
+		entries = entries[:p.maxEntries]
+	}
+	return entries, nil
+}
+
+func (p *Parser) load(ctx context.Context, sitemapURL string, depth int) ([]Entry, error) {
+	p.fetched++
+	body, err := p.fetch(ctx, sitemapURL)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := rootElement(body)
+	if err != nil {
+		return nil, err
+	}
+
+	switch root {
+	case "urlset":
+		return parseURLSet(body)
+	case "sitemapindex":
+		if depth >= p.maxDepth {
+			logger.GetSugaredLogger().Debugf("Sitemap index depth limit reached at %s", sitemapURL)
+			return nil, nil
+		}
+		var index sitemapIndex
+		if err := xml.Unmarshal(body, &index); err != nil {
+			return nil, err
+		}
+
+		// Visit the most recently modified child sitemaps first so the entry limit keeps
+		// the freshest content.
+		children := index.Sitemaps
+		sort.SliceStable(children, func(i, j int) bool {
+			ti, oki := dates.Parse(children[i].LastMod)
+			tj, okj := dates.Parse(children[j].LastMod)
+			if !oki {
+				return false
+			}
+			if !okj {
+				return true
+			}
+			return ti.After(tj)
+		})
+
+		// Failing and empty children count too, so that an index of bad locations
+		// cannot keep the parser fetching without end
+		var entries []Entry
+		visited := 0
+		for _, child := range children {
+			loc := strings.TrimSpace(child.Loc)
+			if loc == "" {
+				continue
+			}
+			if visited >= p.maxChildren || p.fetched >= p.maxSitemaps {
+				logger.GetSugaredLogger().Debugf("Sitemap limit reached at %s", sitemapURL)
+				break
+			}
+			if err := ctx.Err(); err != nil {
+				return entries, err
+			}
+			visited++
+			childEntries, err := p.load(ctx, loc, depth+1)
+			if err != nil {
+				logger.GetSugaredLogger().Warnf("Cannot load child sitemap %s: %s", loc, err.Error())
+				continue
+			}
+			entries = append(entries, childEntries...)
+			if len(entries) >= p.maxEntries {
+				break
+			}
+		}
+		return entries, nil
+	default:
+		return nil, fmt.Errorf("not a sitemap: %s", sitemapURL)
+	}
+}
+
+func (p *Parser) fetch(ctx context.Context, sitemapURL string) ([]byte, error) {
+	resp, err := fetch.With(p.cl).Do(ctx, fetch.Request{
+		URL:      sitemapURL,
+		Header:   http.Header{"Accept": {"application/xml,text/xml;q=0.9,*/*;q=0.8"}},
+		Kind:     fetch.KindFeed,
+		MaxBytes: maxSitemapSize,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("received non-200 status code: %d", resp.StatusCode)
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	// Sitemaps are frequently served gzipped without a Content-Encoding header.
+	magic, _ := reader.Peek(2)
+	var body io.Reader = reader
+	if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		body = gz
+	}
+
+	return io.ReadAll(io.LimitReader(body, maxSitemapSize))
+}
+
+func rootElement(body []byte) (string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", err
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name.Local, nil
+		}
+	}
+}
+
+func parseURLSet(body []byte) ([]Entry, error) {
+	var set urlSet
+	if err := xml.Unmarshal(body, &set); err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(set.URLs))
+	for _, u := range set.URLs {
+		loc := strings.TrimSpace(u.Loc)
+		if loc == "" {
+			continue
+		}
+		entry := Entry{URL: loc}
+		if t, ok := dates.Parse(u.LastMod); ok {
+			entry.LastMod = &t
+		}
+		if u.News != nil {
+			entry.Title = strings.TrimSpace(u.News.Title)
+			entry.PublicationName = strings.TrimSpace(u.News.Publication.Name)
+			entry.Language = strings.TrimSpace(u.News.Publication.Language)
+			if t, ok := dates.Parse(u.News.PublicationDate); ok {
+				entry.PublishedAt = &t
+			}
+		}
+		for _, img := range u.Images {
+			if loc := strings.TrimSpace(img.Loc); loc != "" {
+				entry.Images = append(entry.Images, loc)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// Discover reads the robots.txt of the site behind siteURL and returns the sitemaps it
+// announces. Google News sitemaps are listed first.
+func Discover(ctx context.Context, cl *http.Client, siteURL string) ([]string, error) {
+	parsed, err := url.Parse(siteURL)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Host == "" {
+		return nil, fmt.Errorf("URL missing host: %s", siteURL)
+	}
+	scheme := parsed.Scheme
+	if scheme == "" {
+		scheme = "https"
+	}
+
+	resp, err := fetch.With(cl).Do(ctx, fetch.Request{
+		URL:      fmt.Sprintf("%s://%s/robots.txt", scheme, parsed.Host),
+		Kind:     fetch.KindFeed,
+		MaxBytes: 512 * 1024,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("received non-200 status code: %d", resp.StatusCode)
+	}
+
+	var news, others []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) < 8 || !strings.EqualFold(line[:8], "sitemap:") {
+			continue
+		}
+		loc := strings.TrimSpace(line[8:])
+		if loc == "" {
+			continue
+		}
+		if strings.Contains(strings.ToLower(loc), "news") {
+			news = append(news, loc)
+		} else {
+			others = append(others, loc)
+		}
+	}
+
+	sitemaps := append(news, others...)
+	if len(sitemaps) == 0 {
+		return nil, fmt.Errorf("no sitemaps announced in robots.txt of %s", parsed.Host)
+	}
+	return sitemaps, nil
+}
@@ -0,0 +1,246 @@
+package ssrf
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/lufeed/feed-parser-api/internal/config"
+	"github.com/lufeed/feed-parser-api/internal/logger"
+)
+
+var (
+	defaultSchemes = []string{"http", "https"}
+	defaultPorts   = []int{80, 443, 8080, 8443}
+
+	// reservedPrefixes are blocked on top of the private, loopback, link-local,
+	// multicast and unspecified addresses
+	reservedPrefixes = []netip.Prefix{
+		netip.MustParsePrefix("0.0.0.0/8"),
+		netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT, also cloud metadata
+		netip.MustParsePrefix("192.0.0.0/24"),
+		netip.MustParsePrefix("192.0.2.0/24"),
+		netip.MustParsePrefix("198.18.0.0/15"),
+		netip.MustParsePrefix("198.51.100.0/24"),
+		netip.MustParsePrefix("203.0.113.0/24"),
+		netip.MustParsePrefix("240.0.0.0/4"),
+		netip.MustParsePrefix("64:ff9b::/96"), // NAT64, may reach any IPv4 address
+		netip.MustParsePrefix("2001:db8::/32"),
+	}
+)
+
+var (
+	guard     *Guard
+	guardOnce sync.Once
+)
+
+// BlockedError is returned for requests to a URL the guard does not let through.
+type BlockedError struct {
+	URL    string
+	Reason string
+}
+
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("blocked URL %s: %s", e.URL, e.Reason)
+}
+
+// Guard keeps outbound requests away from internal addresses. Allowed prefixes are
+// reachable even when internal, denied prefixes are blocked even when public.
+type Guard struct {
+	allow    []netip.Prefix
+	deny     []netip.Prefix
+	schemes  []string
+	ports    []int
+	resolver *net.Resolver
+}
+
+// Get returns the guard configured by the ssrf section of the configuration.
+func Get() *Guard {
+	guardOnce.Do(func() {
+		cfg := config.GetConfig()
+		if cfg == nil {
+			cfg = &config.AppConfig{}
+		}
+		guard = New(cfg.SSRF)
+	})
+	return guard
+}
+
+// New builds a guard, schemes and ports default to the web ones. Invalid prefixes are
+// logged and skipped.
+func New(cfg config.SSRFConfig) *Guard {
+	g := &Guard{
+		allow:    parsePrefixes(cfg.AllowCIDRs),
+		deny:     parsePrefixes(cfg.DenyCIDRs),
+		schemes:  defaultSchemes,
+		ports:    defaultPorts,
+		resolver: net.DefaultResolver,
+	}
+	if len(cfg.Schemes) > 0 {
+		g.schemes = nil
+		for _, s := range cfg.Schemes {
+			g.schemes = append(g.schemes, strings.ToLower(strings.TrimSpace(s)))
+		}
+	}
+	if len(cfg.Ports) > 0 {
+		g.ports = cfg.Ports
+	}
+	return g
+}
+
+func parsePrefixes(cidrs []string) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if !strings.Contains(cidr, "/") {
+			// A single address
+			if addr, err := netip.ParseAddr(cidr); err == nil {
+				prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+				continue
+			}
+		}
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			logger.GetSugaredLogger().Warnf("Ignoring invalid SSRF CIDR %q: %s", cidr, err.Error())
+			continue
+		}
+		if prefix.Addr().Is4In6() {
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), max(prefix.Bits()-96, 0))
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes
+}
+
+// CheckURL checks the scheme, host address and port of u. Host names are left to
+// Resolve.
+func (g *Guard) CheckURL(u *url.URL) error {
+	scheme := strings.ToLower(u.Scheme)
+	if !slices.Contains(g.schemes, scheme) {
+		return &BlockedError{URL: u.String(), Reason: fmt.Sprintf("scheme %q is not allowed", u.Scheme)}
+	}
+	if addr, err := netip.ParseAddr(u.Hostname()); err == nil {
+		if reason := g.blocked(addr); reason != "" {
+			return &BlockedError{URL: u.String(), Reason: reason}
+		}
+	}
+	port, err := urlPort(u)
+	if err != nil {
+		return &BlockedError{URL: u.String(), Reason: err.Error()}
+	}
+	if !slices.Contains(g.ports, port) {
+		return &BlockedError{URL: u.String(), Reason: fmt.Sprintf("port %d is not allowed", port)}
+	}
+	return nil
+}
+
+// Resolve checks u and every address its host resolves to.
+func (g *Guard) Resolve(ctx context.Context, u *url.URL) error {
+	return g.resolve(ctx, u, false)
+}
+
+// resolve checks u like Resolve. Strict checks refuse hosts that cannot be resolved
+// rather than leaving them to the dial.
+func (g *Guard) resolve(ctx context.Context, u *url.URL, strict bool) error {
+	if err := g.CheckURL(u); err != nil {
+		return err
+	}
+	host := u.Hostname()
+	if _, err := netip.ParseAddr(host); err == nil {
+		return nil
+	}
+	addrs, err := g.resolver.LookupNetIP(ctx, "ip", host)
+	if err != nil && strict {
+		return &BlockedError{URL: u.String(), Reason: fmt.Sprintf("%s cannot be resolved to be checked", host)}
+	}
+	if err != nil {
+		// Left to the dial, which fails the same way
+		return nil
+	}
+	for _, addr := range addrs {
+		if reason := g.blocked(addr); reason != "" {
+			return &BlockedError{URL: u.String(), Reason: fmt.Sprintf("%s resolves to %s", host, reason)}
+		}
+	}
+	return nil
+}
+
+// Control checks the address a connection is about to be made to, after DNS
+// resolution, so that names resolving differently between the checks and the dial
+// are caught as well. It is meant for net.Dialer.Control.
+func (g *Guard) Control(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return &BlockedError{URL: address, Reason: "cannot parse dial address"}
+	}
+	if reason := g.blocked(addrPort.Addr()); reason != "" {
+		return &BlockedError{URL: address, Reason: reason}
+	}
+	if !slices.Contains(g.ports, int(addrPort.Port())) {
+		return &BlockedError{URL: address, Reason: fmt.Sprintf("port %d is not allowed", addrPort.Port())}
+	}
+	return nil
+}
+
+// Dialer returns a dialer checking every address it connects to.
+func (g *Guard) Dialer(d *net.Dialer) *net.Dialer {
+	guarded := *d
+	guarded.Control = g.Control
+	return &guarded
+}
+
+// blocked returns why addr may not be reached, or an empty string.
+func (g *Guard) blocked(addr netip.Addr) string {
+	addr = addr.Unmap()
+	for _, p := range g.deny {
+		if p.Contains(addr) {
+			return fmt.Sprintf("address %s is denied", addr)
+		}
+	}
+	for _, p := range g.allow {
+		if p.Contains(addr) {
+			return ""
+		}
+	}
+
+	switch {
+	case addr.IsLoopback():
+		return fmt.Sprintf("loopback address %s", addr)
+	case addr.IsPrivate():
+		return fmt.Sprintf("private address %s", addr)
+	case addr.IsLinkLocalUnicast(), addr.IsLinkLocalMulticast():
+		return fmt.Sprintf("link-local address %s", addr)
+	case addr.IsUnspecified(), addr.IsMulticast(), addr.IsInterfaceLocalMulticast():
+		return fmt.Sprintf("non-unicast address %s", addr)
+	}
+	for _, p := range reservedPrefixes {
+		if p.Contains(addr) {
+			return fmt.Sprintf("reserved address %s", addr)
+		}
+	}
+	return ""
+}
+
+func urlPort(u *url.URL) (int, error) {
+	if p := u.Port(); p != "" {
+		port, err := strconv.Atoi(p)
+		if err != nil || port <= 0 || port > 65535 {
+			return 0, fmt.Errorf("invalid port %q", p)
+		}
+		return port, nil
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http":
+		return 80, nil
+	case "https":
+		return 443, nil
+	}
+	return 0, fmt.Errorf("no port for scheme %q", u.Scheme)
+}
@@ -0,0 +1,30 @@
+package ssrf
+
+import (
+	"net/http"
+)
+
+// Transport refuses requests to URLs the guard blocks. Redirects go through the
+// transport again, so every hop is checked.
+type Transport struct {
+	base    http.RoundTripper
+	guard   *Guard
+	proxied bool
+}
+
+// NewTransport wraps base with the shared guard. A proxy resolves the hosts of the
+// requests sent through it, out of reach of the check when connecting, so proxied
+// transports also refuse hosts that cannot be resolved here.
+func NewTransport(base http.RoundTripper, proxied bool) *Transport {
+	return &Transport{base: base, guard: Get(), proxied: proxied}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.guard.resolve(req.Context(), req.URL, t.proxied); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
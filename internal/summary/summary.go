@@ -0,0 +1,247 @@
+package summary
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	defaultMaxSentences = 3
+	defaultMaxChars     = 600
+
+	// Sentences outside these bounds are usually navigation, captions or run-on
+	// boilerplate rather than article text
+	minSentenceChars = 40
+	maxSentenceChars = 500
+	// Ranking is quadratic in the number of sentences, long pages are cut
+	maxSentences = 150
+
+	damping       = 0.85
+	maxIterations = 50
+	convergence   = 1e-4
+)
+
+// Summarizer builds extractive summaries by ranking the sentences of a text with
+// TextRank and keeping the best ones in their original order.
+type Summarizer struct {
+	maxSentences int
+	maxChars     int
+}
+
+func NewSummarizer(maxSentences int, maxChars int) *Summarizer {
+	if maxSentences <= 0 {
+		maxSentences = defaultMaxSentences
+	}
+	if maxChars <= 0 {
+		maxChars = defaultMaxChars
+	}
+	return &Summarizer{maxSentences: maxSentences, maxChars: maxChars}
+}
+
+type sentence struct {
+	text  string
+	index int
+	words map[string]struct{}
+	score float64
+}
+
+// Summarize returns a summary of text within the sentence and character budget, or an
+// empty string when the text has too few usable sentences.
+func (s *Summarizer) Summarize(text string) string {
+	sentences := splitSentences(text)
+	if len(sentences) == 0 {
+		return ""
+	}
+	if len(sentences) > maxSentences {
+		sentences = sentences[:maxSentences]
+	}
+
+	rank(sentences)
+
+	ranked := make([]*sentence, len(sentences))
+	copy(ranked, sentences)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].score > ranked[j].score
+	})
+
+	var selected []*sentence
+	chars := 0
+	for _, sent := range ranked {
+		if len(selected) == s.maxSentences {
+			break
+		}
+		length := utf8.RuneCountInString(sent.text)
+		if chars > 0 && chars+length+1 > s.maxChars {
+			continue
+		}
+		selected = append(selected, sent)
+		chars += length + 1
+	}
+
+	sort.Slice(selected, func(i, j int) bool {
+		return selected[i].index < selected[j].index
+	})
+	parts := make([]string, len(selected))
+	for i, sent := range selected {
+		parts[i] = sent.text
+	}
+	return truncate(strings.Join(parts, " "), s.maxChars)
+}
+
+// rank scores the sentences with PageRank over a graph weighted by word overlap.
+func rank(sentences []*sentence) {
+	n := len(sentences)
+	weights := make([][]float64, n)
+	totals := make([]float64, n)
+	for i := range sentences {
+		weights[i] = make([]float64, n)
+	}
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			w := similarity(sentences[i], sentences[j])
+			weights[i][j] = w
+			weights[j][i] = w
+			totals[i] += w
+			totals[j] += w
+		}
+	}
+
+	scores := make([]float64, n)
+	for i := range scores {
+		scores[i] = 1
+	}
+	for iteration := 0; iteration < maxIterations; iteration++ {
+		delta := 0.0
+		next := make([]float64, n)
+		for i := 0; i < n; i++ {
+			sum := 0.0
+			for j := 0; j < n; j++ {
+				if weights[j][i] > 0 && totals[j] > 0 {
+					sum += weights[j][i] / totals[j] * scores[j]
+				}
+			}
+			next[i] = (1 - damping) + damping*sum
+			delta += math.Abs(next[i] - scores[i])
+		}
+		scores = next
+		if delta < convergence {
+			break
+		}
+	}
+
+	for i, sent := range sentences {
+		sent.score = scores[i]
+	}
+}
+
+func similarity(a, b *sentence) float64 {
+	if len(a.words) < 2 || len(b.words) < 2 {
+		return 0
+	}
+	common := 0
+	for w := range a.words {
+		if _, ok := b.words[w]; ok {
+			common++
+		}
+	}
+	if common == 0 {
+		return 0
+	}
+	return float64(common) / (math.Log(float64(len(a.words))) + math.Log(float64(len(b.words))))
+}
+
+// splitSentences splits text at sentence-ending punctuation followed by whitespace and
+// an upper case letter or digit, which keeps abbreviations like "e.g. the" together.
+func splitSentences(text string) []*sentence {
+	text = strings.Join(strings.Fields(text), " ")
+	runes := []rune(text)
+
+	var sentences []*sentence
+	start := 0
+	add := func(end int) {
+		candidate := strings.TrimSpace(string(runes[start:end]))
+		start = end
+		length := utf8.RuneCountInString(candidate)
+		if length < minSentenceChars || length > maxSentenceChars {
+			return
+		}
+		words := tokenize(candidate)
+		if len(words) < 4 {
+			return
+		}
+		sentences = append(sentences, &sentence{text: candidate, index: len(sentences), words: words})
+	}
+
+	for i := 0; i < len(runes); i++ {
+		if !isTerminal(runes[i]) {
+			continue
+		}
+		end := i + 1
+		// Keep closing quotes and brackets with the sentence
+		for end < len(runes) && strings.ContainsRune(`"'”’)]»`, runes[end]) {
+			end++
+		}
+		if end < len(runes)-1 && runes[end] == ' ' {
+			next := runes[end+1]
+			if unicode.IsUpper(next) || unicode.IsDigit(next) || strings.ContainsRune(`"“‘«¿¡`, next) {
+				add(end)
+				i = end
+			}
+		}
+	}
+	if start < len(runes) {
+		add(len(runes))
+	}
+	return sentences
+}
+
+func isTerminal(r rune) bool {
+	switch r {
+	case '.', '!', '?', '…', '。', '！', '？':
+		return true
+	}
+	return false
+}
+
+func tokenize(text string) map[string]struct{} {
+	words := make(map[string]struct{})
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if utf8.RuneCountInString(w) < 3 || stopwords[w] {
+			continue
+		}
+		words[w] = struct{}{}
+	}
+	return words
+}
+
+func truncate(text string, maxChars int) string {
+	if utf8.RuneCountInString(text) <= maxChars {
+		return text
+	}
+	runes := []rune(text)[:maxChars]
+	if i := strings.LastIndex(string(runes), " "); i > 0 {
+		return strings.TrimRight(string(runes)[:i], ",;:") + "…"
+	}
+	return string(runes) + "…"
+}
+
+var stopwords = map[string]bool{
+	"the": true, "and": true, "for": true, "are": true, "but": true, "not": true, "you": true,
+	"all": true, "any": true, "can": true, "had": true, "her": true, "was": true, "one": true,
+	"our": true, "out": true, "has": true, "have": true, "his": true, "how": true, "its": true,
+	"may": true, "new": true, "now": true, "see": true, "who": true, "did": true, "get": true,
+	"this": true, "that": true, "with": true, "from": true, "they": true, "will": true,
+	"would": true, "there": true, "their": true, "what": true, "about": true, "which": true,
+	"when": true, "were": true, "been": true, "also": true, "into": true, "than": true,
+	"then": true, "them": true, "these": true, "some": true, "could": true, "other": true,
+	"more": true, "most": true, "such": true, "only": true, "over": true, "very": true,
+	"just": true, "said": true, "says": true, "after": true, "before": true, "where": true,
+	"while": true, "your": true, "being": true, "because": true, "should": true, "those": true,
+	"der": true, "die": true, "das": true, "und": true, "ist": true, "les": true, "des": true,
+	"est": true, "una": true, "del": true, "los": true, "las": true, "con": true, "por": true,
+}
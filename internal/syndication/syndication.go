@@ -0,0 +1,399 @@
+package syndication
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lufeed/feed-parser-api/internal/models"
+)
+
+// Output formats
+const (
+	FormatRSS  = "rss"
+	FormatAtom = "atom"
+	FormatJSON = "json"
+)
+
+// Channel describes the document being written.
+type Channel struct {
+	Title       string
+	Description string
+	HomeURL     string
+	SelfURL     string
+	ImageURL    string
+	Updated     time.Time
+}
+
+// Item is a parsed feed item together with the source it was taken from, which is
+// written out when several sources are merged into one document.
+type Item struct {
+	models.Feed
+	SourceName string
+	SourceURL  string
+}
+
+// image returns the URL of the item image, empty for placeholders which mean nothing
+// to feed readers.
+func (i Item) image() string {
+	if i.ImageURL == nil || i.ImageIsPlaceholder {
+		return ""
+	}
+	return *i.ImageURL
+}
+
+// ContentType returns the media type of documents in the given format.
+func ContentType(format string) string {
+	switch format {
+	case FormatAtom:
+		return "application/atom+xml; charset=utf-8"
+	case FormatJSON:
+		return "application/feed+json; charset=utf-8"
+	default:
+		return "application/rss+xml; charset=utf-8"
+	}
+}
+
+// acceptFormats are the media types of Accept headers the formats answer.
+var acceptFormats = map[string]string{
+	"application/rss+xml":   FormatRSS,
+	"application/atom+xml":  FormatAtom,
+	"application/feed+json": FormatJSON,
+	"application/json":      FormatJSON,
+}
+
+// FormatFromAccept picks an output format from an Accept header: the one of the media
+// type with the highest quality, the first listed among equals. Headers naming none of
+// them, or only with q=0, get RSS.
+func FormatFromAccept(accept string) string {
+	format, best := FormatRSS, 0.0
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, params, _ := strings.Cut(mediaRange, ";")
+		f, ok := acceptFormats[strings.ToLower(strings.TrimSpace(mediaType))]
+		if !ok {
+			continue
+		}
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			name, value, _ := strings.Cut(param, "=")
+			if strings.EqualFold(strings.TrimSpace(name), "q") {
+				if v, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && v >= 0 && v <= 1 {
+					q = v
+				}
+			}
+		}
+		if q > best {
+			format, best = f, q
+		}
+	}
+	return format
+}
+
+// SortByDate orders items from newest to oldest and drops items without a link.
+func SortByDate(items []Item) []Item {
+	sorted := make([]Item, 0, len(items))
+	for _, item := range items {
+		if item.URL != "" {
+			sorted = append(sorted, item)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].PublishedAt.After(sorted[j].PublishedAt)
+	})
+	return sorted
+}
+
+// Write renders the channel and its items in the given format. Item HTML, when set,
+// is written as the full content of the item.
+func Write(format string, channel Channel, items []Item) ([]byte, error) {
+	if channel.Updated.IsZero() {
+		channel.Updated = time.Now().UTC()
+		if len(items) > 0 && !items[0].PublishedAt.IsZero() {
+			channel.Updated = items[0].PublishedAt
+		}
+	}
+
+	switch format {
+	case FormatRSS:
+		return writeRSS(channel, items)
+	case FormatAtom:
+		return writeAtom(channel, items)
+	case FormatJSON:
+		return writeJSON(channel, items)
+	}
+	return nil, fmt.Errorf("unsupported format: %s", format)
+}
+
+type rssDocument struct {
+	XMLName   xml.Name   `xml:"rss"`
+	Version   string     `xml:"version,attr"`
+	ContentNS string     `xml:"xmlns:content,attr"`
+	AtomNS    string     `xml:"xmlns:atom,attr"`
+	MediaNS   string     `xml:"xmlns:media,attr"`
+	Channel   rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	AtomLink      *atomLink `xml:"atom:link,omitempty"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Generator     string    `xml:"generator"`
+	Image         *rssImage `xml:"image,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssImage struct {
+	URL   string `xml:"url"`
+	Title string `xml:"title"`
+	Link  string `xml:"link"`
+}
+
+type rssItem struct {
+	Title       string        `xml:"title"`
+	Link        string        `xml:"link"`
+	GUID        rssGUID       `xml:"guid"`
+	Description string        `xml:"description"`
+	Content     *cdata        `xml:"content:encoded,omitempty"`
+	PubDate     string        `xml:"pubDate"`
+	Source      *rssSource    `xml:"source,omitempty"`
+	Media       *mediaContent `xml:"media:content,omitempty"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssSource struct {
+	URL  string `xml:"url,attr"`
+	Name string `xml:",chardata"`
+}
+
+type mediaContent struct {
+	URL    string `xml:"url,attr"`
+	Medium string `xml:"medium,attr"`
+}
+
+type cdata struct {
+	Value string `xml:",cdata"`
+}
+
+func writeRSS(channel Channel, items []Item) ([]byte, error) {
+	doc := rssDocument{
+		Version:   "2.0",
+		ContentNS: "http://purl.org/rss/1.0/modules/content/",
+		AtomNS:    "http://www.w3.org/2005/Atom",
+		MediaNS:   "http://search.yahoo.com/mrss/",
+		Channel: rssChannel{
+			Title:         channel.Title,
+			Link:          channel.HomeURL,
+			Description:   channel.Description,
+			LastBuildDate: channel.Updated.Format(time.RFC1123Z),
+			Generator:     "lufeed-feed-parser-api",
+		},
+	}
+	if channel.SelfURL != "" {
+		doc.Channel.AtomLink = &atomLink{Href: channel.SelfURL, Rel: "self", Type: "application/rss+xml"}
+	}
+	if channel.ImageURL != "" {
+		doc.Channel.Image = &rssImage{URL: channel.ImageURL, Title: channel.Title, Link: channel.HomeURL}
+	}
+
+	for _, item := range items {
+		ri := rssItem{
+			Title:       item.Title,
+			Link:        item.URL,
+			GUID:        rssGUID{IsPermaLink: true, Value: item.URL},
+			Description: item.Description,
+			PubDate:     item.PublishedAt.Format(time.RFC1123Z),
+		}
+		if item.HTML != nil && *item.HTML != "" {
+			// The extracted content is plain text, content:encoded is HTML
+			ri.Content = &cdata{Value: html.EscapeString(*item.HTML)}
+		}
+		if item.SourceURL != "" {
+			ri.Source = &rssSource{URL: item.SourceURL, Name: item.SourceName}
+		}
+		if image := item.image(); image != "" {
+			ri.Media = &mediaContent{URL: image, Medium: "image"}
+		}
+		doc.Channel.Items = append(doc.Channel.Items, ri)
+	}
+
+	return marshalXML(doc)
+}
+
+type atomDocument struct {
+	XMLName  xml.Name    `xml:"feed"`
+	Xmlns    string      `xml:"xmlns,attr"`
+	ID       string      `xml:"id"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	Updated  string      `xml:"updated"`
+	Links    []atomLink  `xml:"link"`
+	Logo     string      `xml:"logo,omitempty"`
+	Author   atomPerson  `xml:"author"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	ID        string      `xml:"id"`
+	Title     string      `xml:"title"`
+	Links     []atomLink  `xml:"link"`
+	Published string      `xml:"published"`
+	Updated   string      `xml:"updated"`
+	Summary   *atomText   `xml:"summary,omitempty"`
+	Content   *atomText   `xml:"content,omitempty"`
+	Source    *atomSource `xml:"source,omitempty"`
+}
+
+type atomSource struct {
+	Title string `xml:"title"`
+}
+
+func writeAtom(channel Channel, items []Item) ([]byte, error) {
+	id := channel.SelfURL
+	if id == "" {
+		id = channel.HomeURL
+	}
+	doc := atomDocument{
+		Xmlns:    "http://www.w3.org/2005/Atom",
+		ID:       id,
+		Title:    channel.Title,
+		Subtitle: channel.Description,
+		Updated:  channel.Updated.Format(time.RFC3339),
+		Logo:     channel.ImageURL,
+		// Atom requires an author at feed level when entries carry none
+		Author: atomPerson{Name: channel.Title},
+	}
+	if channel.HomeURL != "" {
+		doc.Links = append(doc.Links, atomLink{Href: channel.HomeURL, Rel: "alternate", Type: "text/html"})
+	}
+	if channel.SelfURL != "" {
+		doc.Links = append(doc.Links, atomLink{Href: channel.SelfURL, Rel: "self", Type: "application/atom+xml"})
+	}
+
+	for _, item := range items {
+		entry := atomEntry{
+			ID:        item.URL,
+			Title:     item.Title,
+			Links:     []atomLink{{Href: item.URL, Rel: "alternate", Type: "text/html"}},
+			Published: item.PublishedAt.Format(time.RFC3339),
+			Updated:   item.PublishedAt.Format(time.RFC3339),
+		}
+		if image := item.image(); image != "" {
+			entry.Links = append(entry.Links, atomLink{Href: image, Rel: "enclosure", Type: imageType(image)})
+		}
+		if item.Description != "" {
+			entry.Summary = &atomText{Type: "text", Value: item.Description}
+		}
+		if item.HTML != nil && *item.HTML != "" {
+			entry.Content = &atomText{Type: "text", Value: *item.HTML}
+		}
+		if item.SourceName != "" {
+			entry.Source = &atomSource{Title: item.SourceName}
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+
+	return marshalXML(doc)
+}
+
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url,omitempty"`
+	FeedURL     string         `json:"feed_url,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Icon        string         `json:"icon,omitempty"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string `json:"id"`
+	URL           string `json:"url"`
+	Title         string `json:"title,omitempty"`
+	ContentText   string `json:"content_text"`
+	Summary       string `json:"summary,omitempty"`
+	Image         string `json:"image,omitempty"`
+	DatePublished string `json:"date_published,omitempty"`
+}
+
+func writeJSON(channel Channel, items []Item) ([]byte, error) {
+	doc := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       channel.Title,
+		HomePageURL: channel.HomeURL,
+		FeedURL:     channel.SelfURL,
+		Description: channel.Description,
+		Icon:        channel.ImageURL,
+		Items:       make([]jsonFeedItem, 0, len(items)),
+	}
+
+	for _, item := range items {
+		ji := jsonFeedItem{
+			ID:            item.URL,
+			URL:           item.URL,
+			Title:         item.Title,
+			Summary:       item.Description,
+			ContentText:   item.Description,
+			Image:         item.image(),
+			DatePublished: item.PublishedAt.Format(time.RFC3339),
+		}
+		if item.Summary != "" {
+			ji.Summary = item.Summary
+		}
+		if item.HTML != nil && *item.HTML != "" {
+			ji.ContentText = *item.HTML
+		}
+		doc.Items = append(doc.Items, ji)
+	}
+
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+func marshalXML(doc interface{}) ([]byte, error) {
+	b, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), b...), nil
+}
+
+func imageType(imageURL string) string {
+	lower := strings.ToLower(strings.Split(imageURL, "?")[0])
+	switch {
+	case strings.HasSuffix(lower, ".png"):
+		return "image/png"
+	case strings.HasSuffix(lower, ".gif"):
+		return "image/gif"
+	case strings.HasSuffix(lower, ".webp"):
+		return "image/webp"
+	case strings.HasSuffix(lower, ".svg"):
+		return "image/svg+xml"
+	default:
+		return "image/jpeg"
+	}
+}
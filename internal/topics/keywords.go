@@ -0,0 +1,217 @@
+package topics
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// Only the beginning of long pages is analyzed
+	maxTextChars    = 20000
+	maxPhraseWords  = 3
+	minWordChars    = 3
+	titleBoost      = 1.5
+	defaultIDFScore = 1.0
+)
+
+type candidate struct {
+	words []string
+	count int
+	score float64
+}
+
+func (c *candidate) phrase() string {
+	return strings.Join(c.words, " ")
+}
+
+// segments splits text into runs of words that are not interrupted by punctuation.
+func segments(text string) [][]string {
+	var result [][]string
+	var current []string
+	var word strings.Builder
+
+	flushWord := func() {
+		if word.Len() > 0 {
+			current = append(current, strings.ToLower(word.String()))
+			word.Reset()
+		}
+	}
+	flushSegment := func() {
+		flushWord()
+		if len(current) > 0 {
+			result = append(result, current)
+			current = nil
+		}
+	}
+
+	for _, r := range text {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word.WriteRune(r)
+		case r == '-' || r == '\'' || r == '’':
+			// Keep hyphenated words and contractions together
+			if word.Len() > 0 {
+				word.WriteRune(r)
+			}
+		case unicode.IsSpace(r):
+			flushWord()
+		default:
+			flushSegment()
+		}
+	}
+	flushSegment()
+	return result
+}
+
+// candidates returns the RAKE candidate phrases of text: maximal runs of content words
+// between stopwords and punctuation.
+func candidates(text string, stop map[string]bool) map[string]*candidate {
+	found := make(map[string]*candidate)
+	add := func(words []string) {
+		for len(words) > 0 {
+			n := min(len(words), maxPhraseWords)
+			phrase := strings.Join(words[:n], " ")
+			if c, ok := found[phrase]; ok {
+				c.count++
+			} else {
+				found[phrase] = &candidate{words: words[:n:n], count: 1}
+			}
+			words = words[n:]
+		}
+	}
+
+	for _, segment := range segments(text) {
+		var run []string
+		for _, w := range segment {
+			w = strings.Trim(w, "-'’")
+			if stop[w] || utf8.RuneCountInString(w) < minWordChars || isNumber(w) {
+				add(run)
+				run = nil
+				continue
+			}
+			run = append(run, w)
+		}
+		add(run)
+	}
+	return found
+}
+
+// rakeScores scores the candidates with the RAKE degree/frequency ratio of their words.
+func rakeScores(found map[string]*candidate) {
+	frequency := make(map[string]int)
+	degree := make(map[string]int)
+	for _, c := range found {
+		for _, w := range c.words {
+			frequency[w] += c.count
+			degree[w] += c.count * len(c.words)
+		}
+	}
+	for _, c := range found {
+		score := 0.0
+		for _, w := range c.words {
+			score += float64(degree[w]) / float64(frequency[w])
+		}
+		c.score = score
+	}
+}
+
+// rankKeywords combines the RAKE score of each candidate with the TF-IDF weight of its
+// words and returns the best maxKeywords phrases.
+func rankKeywords(found map[string]*candidate, idf map[string]float64, title string, maxKeywords int) []string {
+	rakeScores(found)
+	loweredTitle := " " + normalizePhrase(title) + " "
+
+	ranked := make([]*candidate, 0, len(found))
+	for _, c := range found {
+		weight := 0.0
+		for _, w := range c.words {
+			if v, ok := idf[w]; ok {
+				weight += v
+			} else {
+				weight += defaultIDFScore
+			}
+		}
+		weight /= float64(len(c.words))
+		c.score *= weight * (1 + math.Log(float64(c.count)))
+		if strings.Contains(loweredTitle, " "+c.phrase()+" ") {
+			c.score *= titleBoost
+		}
+		ranked = append(ranked, c)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].score != ranked[j].score {
+			return ranked[i].score > ranked[j].score
+		}
+		return ranked[i].phrase() < ranked[j].phrase()
+	})
+
+	var keywords []string
+	for _, c := range ranked {
+		if len(keywords) == maxKeywords {
+			break
+		}
+		phrase := c.phrase()
+		if coveredBy(phrase, keywords) {
+			continue
+		}
+		keywords = append(keywords, phrase)
+	}
+	return keywords
+}
+
+// coveredBy reports whether phrase is a word-wise part of one of the selected keywords.
+func coveredBy(phrase string, selected []string) bool {
+	for _, k := range selected {
+		if strings.Contains(" "+k+" ", " "+phrase+" ") || strings.Contains(" "+phrase+" ", " "+k+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// terms returns the distinct content words of text, the units IDF is kept for.
+func terms(found map[string]*candidate) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, c := range found {
+		for _, w := range c.words {
+			if !seen[w] {
+				seen[w] = true
+				result = append(result, w)
+			}
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+func normalizePhrase(text string) string {
+	var words []string
+	for _, segment := range segments(text) {
+		words = append(words, segment...)
+	}
+	return strings.Join(words, " ")
+}
+
+func isNumber(w string) bool {
+	for _, r := range w {
+		if !unicode.IsDigit(r) && r != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+func truncateText(text string) string {
+	if len(text) <= maxTextChars {
+		return text
+	}
+	cut := maxTextChars
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut]
+}
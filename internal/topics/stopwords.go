@@ -0,0 +1,56 @@
+package topics
+
+import "strings"
+
+// stopwords per language, used to delimit candidate phrases. Languages without a list
+// fall back to English.
+var stopwords = map[string]map[string]bool{
+	"en": wordSet(`a about above after again against all also am an and any are as at be because been
+		before being below between both but by can could did do does doing down during each few for
+		from further had has have having he her here hers him his how i if in into is it its itself
+		just me more most my no nor not now of off on once only or other our ours out over own same
+		she should so some such than that the their theirs them then there these they this those
+		through to too under until up very was we were what when where which while who whom why will
+		with would you your yours said says new one two also like get got make made many much may
+		might must us per via`),
+	"de": wordSet(`aber alle allem allen aller alles als also am an ander andere anderem anderen
+		anderer anderes auch auf aus bei bin bis bist da damit dann das dass dein deine dem den der
+		des dessen dich die dies diese diesem diesen dieser dieses dir doch dort du durch ein eine
+		einem einen einer eines er es etwas euer für gegen hat hatte hier hin hinter ich ihr ihre im
+		in ist jede jedem jeden jeder jetzt kann kein keine man mehr mein mit muss nach nicht noch nun
+		nur ob oder ohne sehr sein seine sich sie sind so soll über um und uns unter viel vom von vor
+		war waren was weil wenn wer wie wieder will wir wird wurde zu zum zur zwischen`),
+	"fr": wordSet(`à au aux avec ce ces cette dans de des du elle en et eux il ils je la le les leur
+		lui ma mais me même mes moi mon ne nos notre nous on ou où par pas pour qu que qui sa se ses
+		son sur ta te tes toi ton tu un une vos votre vous est sont été être avoir a ont fait plus
+		comme tout tous aussi après avant entre sans sous`),
+	"es": wordSet(`a al algo como con de del el ella ellas ellos en entre era es esta este esto
+		estos fue ha hay la las le les lo los más me mi muy no nos o para pero por que se sin sobre
+		su sus también te tu un una uno unos y ya son ser está están han desde hasta cuando donde`),
+	"it": wordSet(`a al alla alle anche che chi ci come con da dal dalla dei del della delle di e è
+		gli ha hanno il in io la le lo ma mi ne nel nella non per più quando questa questo se si sono
+		su sua suo tra un una uno sul sulla dopo prima ancora essere stato`),
+	"pt": wordSet(`a ao aos as com como da das de do dos e é ela ele em entre era essa esse esta
+		este eu foi há isso já mais mas na nas no nos o os ou para pela pelo por que se sem ser seu
+		sua são também um uma uns umas está estão foram`),
+	"nl": wordSet(`aan al alles als bij dan dat de der deze die dit door een en er had heb heeft
+		het hij hoe ik in is je kan maar me meer met mij na naar niet nog nu of om onder ons ook op
+		over te tot u uit van veel voor was wat we wel werd wie wij worden zal ze zich zij zijn zo`),
+	"tr": wordSet(`ama bir biz bu çok da daha de değil diye en gibi hem her için ile ise kadar ki
+		mi mu ne o olan olarak ona onu sonra şu ve veya ya yani bunu olduğu oldu göre`),
+}
+
+func wordSet(words string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.Fields(words) {
+		set[w] = true
+	}
+	return set
+}
+
+func stopwordsFor(language string) map[string]bool {
+	if set, ok := stopwords[language]; ok {
+		return set
+	}
+	return stopwords["en"]
+}
@@ -0,0 +1,112 @@
+package topics
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/lufeed/feed-parser-api/internal/cache"
+	"github.com/lufeed/feed-parser-api/internal/models"
+)
+
+const (
+	idfKeyPrefix           = "topics_idf:"
+	sourceTopicsKeyPrefix  = "source_topics:"
+	documentsField         = "__documents"
+	sourceItemsField       = "__items"
+	maxSourceProfileTopics = 10
+)
+
+// observeDocument adds the terms of one document to the document frequencies of the
+// language.
+func observeDocument(language string, documentTerms []string) error {
+	fields := append([]string{documentsField}, documentTerms...)
+	return cache.IncrHashFields(idfKeyPrefix+language, fields, 1)
+}
+
+// inverseDocumentFrequencies returns the smoothed IDF of the terms in the language.
+// Terms are missing from the result while too few documents have been seen.
+func inverseDocumentFrequencies(language string, documentTerms []string) map[string]float64 {
+	idf := make(map[string]float64, len(documentTerms))
+	if len(documentTerms) == 0 {
+		return idf
+	}
+
+	values, err := cache.GetHashFields(idfKeyPrefix+language, append([]string{documentsField}, documentTerms...)...)
+	if err != nil || len(values) == 0 {
+		return idf
+	}
+	documents := hashInt(values[0])
+	if documents < minDocumentsForIDF {
+		return idf
+	}
+
+	for i, term := range documentTerms {
+		frequency := hashInt(values[i+1])
+		idf[term] = math.Log(float64(documents+1)/float64(frequency+1)) + 1
+	}
+	return idf
+}
+
+// RecordSourceTopics adds the topics of one item to the topic profile of the source.
+func RecordSourceTopics(feedURL string, itemTopics []string) error {
+	if feedURL == "" {
+		return nil
+	}
+	return cache.IncrHashFields(sourceTopicsKeyPrefix+feedURL, append([]string{sourceItemsField}, itemTopics...), 1)
+}
+
+// SourceProfile returns the share of the items of the source seen so far that carry
+// each topic, most frequent first.
+func SourceProfile(feedURL string) []models.TopicScore {
+	counts, err := cache.GetHash(sourceTopicsKeyPrefix + feedURL)
+	if err != nil || len(counts) == 0 {
+		return nil
+	}
+	items, _ := strconv.ParseInt(counts[sourceItemsField], 10, 64)
+	delete(counts, sourceItemsField)
+
+	perTopic := make(map[string]int, len(counts))
+	for topic, count := range counts {
+		n, err := strconv.Atoi(count)
+		if err == nil {
+			perTopic[topic] = n
+		}
+	}
+	return profile(perTopic, int(items))
+}
+
+// profile turns per-topic item counts into shares of all items.
+func profile(perTopic map[string]int, items int) []models.TopicScore {
+	if items <= 0 {
+		return nil
+	}
+
+	var scores []models.TopicScore
+	for topic, count := range perTopic {
+		if count <= 0 {
+			continue
+		}
+		share := math.Min(float64(count)/float64(items), 1)
+		scores = append(scores, models.TopicScore{Topic: topic, Score: math.Round(share*1000) / 1000})
+	}
+	sort.Slice(scores, func(i, j int) bool {
+		if scores[i].Score != scores[j].Score {
+			return scores[i].Score > scores[j].Score
+		}
+		return scores[i].Topic < scores[j].Topic
+	})
+	if len(scores) > maxSourceProfileTopics {
+		scores = scores[:maxSourceProfileTopics]
+	}
+	return scores
+}
+
+func hashInt(v interface{}) int64 {
+	s, ok := v.(string)
+	if !ok {
+		return 0
+	}
+	n, _ := strconv.ParseInt(s, 10, 64)
+	return n
+}
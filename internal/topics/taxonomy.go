@@ -0,0 +1,92 @@
+package topics
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Taxonomy maps topic names to the terms that indicate them. Terms may be phrases and
+// are matched on whole words, case-insensitively.
+type Taxonomy map[string][]string
+
+// LoadTaxonomy reads a YAML taxonomy file of the form
+//
+//	technology: [software, programming, artificial intelligence]
+//	sports: [football, tennis]
+func LoadTaxonomy(path string) (Taxonomy, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string][]string
+	if err := yaml.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("invalid taxonomy file %s: %w", path, err)
+	}
+
+	taxonomy := make(Taxonomy, len(raw))
+	for topic, terms := range raw {
+		topic = strings.TrimSpace(topic)
+		if topic == "" {
+			continue
+		}
+		for _, term := range terms {
+			if normalized := normalizePhrase(term); normalized != "" {
+				taxonomy[topic] = append(taxonomy[topic], normalized)
+			}
+		}
+	}
+	return taxonomy, nil
+}
+
+// classify scores every topic by how often its terms occur in the title, the keywords
+// and the text, and returns the best topics scoring at least minTopicScore.
+func (t Taxonomy) classify(title string, keywords []string, text string, maxTopics int) []string {
+	paddedTitle := " " + normalizePhrase(title) + " "
+	paddedText := " " + normalizePhrase(text) + " "
+	normalizedKeywords := make([]string, len(keywords))
+	for i, k := range keywords {
+		normalizedKeywords[i] = normalizePhrase(k)
+	}
+	// Keywords are kept apart so terms cannot match across two of them
+	paddedKeywords := " " + strings.Join(normalizedKeywords, " | ") + " "
+
+	type scored struct {
+		topic string
+		score int
+	}
+	var matched []scored
+	for topic, terms := range t {
+		score := 0
+		for _, term := range terms {
+			needle := " " + term + " "
+			if strings.Contains(paddedTitle, needle) {
+				score += 3
+			}
+			if strings.Contains(paddedKeywords, needle) {
+				score += 2
+			}
+			score += min(strings.Count(paddedText, needle), 5)
+		}
+		if score >= minTopicScore {
+			matched = append(matched, scored{topic: topic, score: score})
+		}
+	}
+
+	sort.Slice(matched, func(i, j int) bool {
+		if matched[i].score != matched[j].score {
+			return matched[i].score > matched[j].score
+		}
+		return matched[i].topic < matched[j].topic
+	})
+
+	var result []string
+	for i := 0; i < len(matched) && i < maxTopics; i++ {
+		result = append(result, matched[i].topic)
+	}
+	return result
+}
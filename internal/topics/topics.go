@@ -0,0 +1,123 @@
+package topics
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/lufeed/feed-parser-api/internal/config"
+	"github.com/lufeed/feed-parser-api/internal/logger"
+	"github.com/lufeed/feed-parser-api/internal/models"
+	"github.com/mmcdole/gofeed"
+)
+
+const (
+	defaultMaxKeywords = 8
+	defaultMaxTopics   = 3
+	defaultLanguage    = "en"
+	minTopicScore      = 2
+	// IDF is unreliable until enough documents of a language have been seen
+	minDocumentsForIDF = 20
+)
+
+// Result is what the analyzer found in one item.
+type Result struct {
+	Language string
+	Keywords []string
+	Topics   []string
+}
+
+// Analyzer extracts keywords with RAKE weighted by TF-IDF and maps items to the topics
+// of a taxonomy.
+type Analyzer struct {
+	taxonomy    Taxonomy
+	maxKeywords int
+	maxTopics   int
+}
+
+var (
+	analyzer     *Analyzer
+	analyzerOnce sync.Once
+)
+
+// GetAnalyzer returns the analyzer configured in the topics section of the config, or
+// nil when topic extraction is disabled.
+func GetAnalyzer() *Analyzer {
+	analyzerOnce.Do(func() {
+		cfg := config.GetConfig().Topics
+		if !cfg.Enabled {
+			return
+		}
+
+		var taxonomy Taxonomy
+		if cfg.TaxonomyFile != "" {
+			var err error
+			taxonomy, err = LoadTaxonomy(cfg.TaxonomyFile)
+			if err != nil {
+				logger.GetSugaredLogger().Errorf("Cannot load topic taxonomy: %s", err.Error())
+			}
+		}
+		analyzer = NewAnalyzer(taxonomy, cfg.MaxKeywords, cfg.MaxTopics)
+	})
+	return analyzer
+}
+
+func NewAnalyzer(taxonomy Taxonomy, maxKeywords int, maxTopics int) *Analyzer {
+	if maxKeywords <= 0 {
+		maxKeywords = defaultMaxKeywords
+	}
+	if maxTopics <= 0 {
+		maxTopics = defaultMaxTopics
+	}
+	return &Analyzer{taxonomy: taxonomy, maxKeywords: maxKeywords, maxTopics: maxTopics}
+}
+
+// Analyze extracts the keywords and topics of an item and adds its terms to the
+// document frequencies of its language.
+func (a *Analyzer) Analyze(language, title, text string) Result {
+	language = NormalizeLanguage(language)
+	text = truncateText(text)
+
+	found := candidates(title+". "+text, stopwordsFor(language))
+	documentTerms := terms(found)
+	idf := inverseDocumentFrequencies(language, documentTerms)
+	if err := observeDocument(language, documentTerms); err != nil {
+		logger.GetSugaredLogger().Debugf("Cannot update document frequencies: %s", err.Error())
+	}
+
+	keywords := rankKeywords(found, idf, title, a.maxKeywords)
+	return Result{
+		Language: language,
+		Keywords: keywords,
+		Topics:   a.taxonomy.classify(title, keywords, text, a.maxTopics),
+	}
+}
+
+// Classify maps a text to topics using the taxonomy only. It is meant for cheap
+// estimates, e.g. from feed item titles, and does not touch the IDF statistics.
+func (a *Analyzer) Classify(title, text string) []string {
+	return a.taxonomy.classify(title, nil, truncateText(text), a.maxTopics)
+}
+
+// NormalizeLanguage reduces a language tag such as "de-AT" to its primary subtag.
+func NormalizeLanguage(language string) string {
+	language = strings.ToLower(strings.TrimSpace(language))
+	if i := strings.IndexAny(language, "-_"); i > 0 {
+		language = language[:i]
+	}
+	if len(language) < 2 || len(language) > 3 {
+		return defaultLanguage
+	}
+	return language
+}
+
+// EstimateProfile builds a topic profile from the titles and descriptions of feed
+// items, for sources whose items have not been analyzed yet.
+func (a *Analyzer) EstimateProfile(items []*gofeed.Item) []models.TopicScore {
+	perTopic := make(map[string]int)
+	for _, item := range items {
+		for _, topic := range a.Classify(item.Title, item.Description) {
+			perTopic[topic]++
+		}
+	}
+	return profile(perTopic, len(items))
+}